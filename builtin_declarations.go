@@ -0,0 +1,119 @@
+package main
+
+/*
+ * Driver implementation (generated declarations for built-in variables)
+ * ---------------------------------------------------------------------
+ *
+ * Newer versions of Terraform warn (or fail) if tfvars.json contains variables that the configuration does not declare,
+ * so the driver generates dm_variables.tf.json to declare any built-in variables that the configuration does not declare itself.
+ */
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+
+	"github.com/docker/machine/libmachine/log"
+	"github.com/tintoy/docker-machine-driver-terraform/terraform"
+	"github.com/tintoy/docker-machine-driver-terraform/terraform/config"
+)
+
+// The name of the generated file (in the Terraform configuration directory) that declares built-in variables.
+const builtInDeclarationsFileName = "dm_variables.tf.json"
+
+// The description used for generated declarations of built-in variables.
+const builtInDeclarationDescription = "Built-in variable supplied by docker-machine-driver-terraform (declaration generated automatically)."
+
+// A generated variable declaration (in Terraform's JSON syntax).
+type generatedVariableDeclaration struct {
+	Description string `json:"description"`
+}
+
+// Generate declarations for the built-in variables that will be passed to Terraform but are not declared by the configuration.
+func (driver *Driver) declareBuiltInVariables(variables terraform.ConfigVariables) error {
+	localConfigDir, err := driver.getConfigDir()
+	if err != nil {
+		return err
+	}
+
+	// Start from scratch (we don't want previously-generated declarations to count as declared by the configuration).
+	declarationsFileName := path.Join(localConfigDir, builtInDeclarationsFileName)
+	err = os.Remove(declarationsFileName)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if driver.SkipBuiltInDeclarations {
+		return nil
+	}
+
+	module, err := config.LoadModule(localConfigDir)
+	if err != nil {
+		log.Warnf("Unable to read variable declarations from Terraform configuration (built-in variables will not be declared automatically): %s", err.Error())
+
+		return nil
+	}
+
+	declarations := make(map[string]generatedVariableDeclaration)
+	for variableName := range variables {
+		if !isBuiltInVariable(variableName) {
+			continue
+		}
+		if _, ok := module.Variables[variableName]; ok {
+			continue
+		}
+
+		declarations[variableName] = generatedVariableDeclaration{
+			Description: builtInDeclarationDescription,
+		}
+	}
+	if len(declarations) == 0 {
+		return nil
+	}
+
+	declaredNames := make([]string, 0, len(declarations))
+	for variableName := range declarations {
+		declaredNames = append(declaredNames, variableName)
+	}
+	sort.Strings(declaredNames)
+	log.Debugf("Generating declarations for built-in variables %v in '%s'...", declaredNames, declarationsFileName)
+
+	declarationsJSON, err := json.MarshalIndent(map[string]interface{}{
+		"variable": declarations,
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(declarationsFileName, declarationsJSON, 0600 /* u=rw,g=,o= */)
+}
+
+// Remove variables that are not declared by the Terraform configuration.
+func (driver *Driver) stripUndeclaredVariables(variables terraform.ConfigVariables) (terraform.ConfigVariables, error) {
+	localConfigDir, err := driver.getConfigDir()
+	if err != nil {
+		return nil, err
+	}
+
+	module, err := config.LoadModule(localConfigDir)
+	if err != nil {
+		log.Warnf("Unable to read variable declarations from Terraform configuration (undeclared variables will not be removed): %s", err.Error())
+
+		return variables, nil
+	}
+
+	declaredVariables := make(terraform.ConfigVariables)
+	for variableName, variableValue := range variables {
+		if _, ok := module.Variables[variableName]; !ok {
+			log.Debugf("Variable '%s' is not declared by the Terraform configuration, and will not be passed to Terraform.", variableName)
+
+			continue
+		}
+
+		declaredVariables[variableName] = variableValue
+	}
+
+	return declaredVariables, nil
+}
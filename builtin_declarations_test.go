@@ -0,0 +1,94 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+
+	"github.com/tintoy/docker-machine-driver-terraform/terraform"
+	"github.com/tintoy/docker-machine-driver-terraform/terraform/config"
+)
+
+func TestDeclareBuiltInVariables(t *testing.T) {
+	configDir, err := ioutil.TempDir("", "declarations-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(configDir)
+
+	err = ioutil.WriteFile(path.Join(configDir, "main.tf"), []byte(`
+variable "dm_machine_name" {}
+variable "region" {}
+`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	driver := &Driver{ConfigDir: configDir}
+	variables := terraform.ConfigVariables{
+		"dm_machine_name": "web1",
+		"dm_client_ip":    "1.2.3.4",
+		"region":          "syd",
+		"size":            "large", // Not a built-in variable, so never declared automatically
+	}
+
+	// Generate twice; previously-generated declarations must not count as declared by the configuration.
+	for iteration := 0; iteration < 2; iteration++ {
+		err = driver.declareBuiltInVariables(variables)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		module, err := config.LoadModule(configDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expectedNames := []string{"dm_client_ip", "dm_machine_name", "region"}
+		if !reflect.DeepEqual(module.VariableNames(), expectedNames) {
+			t.Fatalf("Expected declared variables %v (got %v)", expectedNames, module.VariableNames())
+		}
+		if module.Variables["dm_client_ip"].FileName != builtInDeclarationsFileName {
+			t.Errorf("Expected 'dm_client_ip' to be declared in '%s' (got '%s')", builtInDeclarationsFileName, module.Variables["dm_client_ip"].FileName)
+		}
+	}
+
+	driver.SkipBuiltInDeclarations = true
+	err = driver.declareBuiltInVariables(variables)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = os.Stat(path.Join(configDir, builtInDeclarationsFileName))
+	if !os.IsNotExist(err) {
+		t.Errorf("Expected '%s' to be removed when declarations are skipped", builtInDeclarationsFileName)
+	}
+}
+
+func TestStripUndeclaredVariables(t *testing.T) {
+	configDir, err := ioutil.TempDir("", "declarations-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(configDir)
+
+	err = ioutil.WriteFile(path.Join(configDir, "main.tf"), []byte(`variable "region" {}`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	driver := &Driver{ConfigDir: configDir}
+	variables, err := driver.stripUndeclaredVariables(terraform.ConfigVariables{
+		"region":       "syd",
+		"dm_client_ip": "1.2.3.4",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedVariables := terraform.ConfigVariables{"region": "syd"}
+	if !reflect.DeepEqual(variables, expectedVariables) {
+		t.Errorf("Expected %v (got %v)", expectedVariables, variables)
+	}
+}
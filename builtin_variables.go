@@ -0,0 +1,226 @@
+package main
+
+/*
+ * Driver implementation (built-in Terraform variables and outputs)
+ * ----------------------------------------------------------------
+ */
+
+import (
+	"crypto/rand"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/docker/machine/libmachine/log"
+	"github.com/tintoy/docker-machine-driver-terraform/terraform"
+)
+
+// The prefix for the names of built-in variables (which configurations are not required to declare).
+const builtInVariablePrefix = "dm_"
+
+// The port on which the Docker daemon listens (TLS).
+const dockerPort = 2376
+
+const (
+	// Supplying a value for a built-in variable is an error (unless the variable is in the allow-list).
+	builtInOverrideDeny = "deny"
+
+	// Values supplied for built-in variables are ignored, with a warning (unless the variable is in the allow-list).
+	builtInOverrideWarn = "warn"
+
+	// Values supplied for built-in variables replace the built-in values.
+	builtInOverrideAllow = "allow"
+)
+
+// The names of the built-in variables supplied by the driver.
+var builtInVariableNames = []string{
+	"dm_onetime_password",
+	"dm_client_ip",
+	"dm_machine_name",
+	"dm_machine_name_dns",
+	"dm_machine_name_short",
+	"dm_machine_name_slug",
+	"dm_machine_id",
+	"dm_ssh_private_key_file",
+	"dm_ssh_public_key_file",
+	"dm_ssh_user",
+	"dm_ssh_port",
+	"dm_docker_port",
+	"dm_swarm_master",
+	"dm_swarm_host",
+	"dm_swarm_discovery",
+	"dm_store_path",
+	"dm_driver_version",
+}
+
+// An output that the driver reads from the Terraform configuration.
+type contractOutput struct {
+	// The output name.
+	Name string
+
+	// Must the configuration supply the output?
+	Required bool
+}
+
+// The outputs that the driver reads from the Terraform configuration.
+var contractOutputs = []contractOutput{
+	{Name: "dm_machine_ip", Required: true},
+	{Name: "dm_ssh_user"},
+}
+
+// Generate the built-in variables supplied by the driver.
+//
+// Values that must remain stable for the lifetime of the machine (e.g. dm_machine_id) are generated once, and persisted with the driver.
+func (driver *Driver) getBuiltInVariables() (terraform.ConfigVariables, error) {
+	var err error
+
+	if driver.MachineID == "" {
+		driver.MachineID, err = generateMachineID()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	builtInVariables := make(terraform.ConfigVariables)
+
+	log.Debugf("Generating one-time password...")
+	builtInVariables["dm_onetime_password"], err = driver.generateOneTimePassword()
+	if err != nil {
+		return nil, err
+	}
+
+	builtInVariables["dm_client_ip"] = driver.ClientIP
+	builtInVariables["dm_machine_name"] = driver.MachineName
+	builtInVariables["dm_machine_name_dns"] = machineNameDNSLabel(driver.MachineName)
+	builtInVariables["dm_machine_name_short"] = machineNameShort(driver.MachineName)
+	builtInVariables["dm_machine_name_slug"] = machineNameSlug(driver.MachineName)
+	builtInVariables["dm_machine_id"] = driver.MachineID
+	builtInVariables["dm_ssh_private_key_file"] = driver.SSHKeyPath
+	builtInVariables["dm_ssh_public_key_file"] = driver.SSHKeyPath + ".pub"
+	builtInVariables["dm_ssh_user"] = driver.SSHUser
+	builtInVariables["dm_ssh_port"] = driver.SSHPort
+	builtInVariables["dm_docker_port"] = dockerPort
+	builtInVariables["dm_swarm_master"] = driver.SwarmMaster
+	builtInVariables["dm_swarm_host"] = driver.SwarmHost
+	builtInVariables["dm_swarm_discovery"] = driver.SwarmDiscovery
+	builtInVariables["dm_store_path"] = driver.StorePath
+	builtInVariables["dm_driver_version"] = DriverVersion
+
+	return builtInVariables, nil
+}
+
+// Generate a new (random, version 4) UUID to identify a machine.
+func generateMachineID() (string, error) {
+	data := make([]byte, 16)
+	_, err := rand.Read(data)
+	if err != nil {
+		return "", err
+	}
+
+	data[6] = (data[6] & 0x0f) | 0x40 // Version 4
+	data[8] = (data[8] & 0x3f) | 0x80 // RFC 4122 variant
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", data[0:4], data[4:6], data[6:8], data[8:10], data[10:16]), nil
+}
+
+// Apply the built-in override policy to values supplied (by the user) for built-in variables.
+//
+// Returns the built-in variables that should be used; those for which the user-supplied value is permitted to take precedence are removed.
+func (driver *Driver) applyBuiltInOverrides(userLayers []terraform.VariableLayer, builtInVariables terraform.ConfigVariables) (terraform.ConfigVariables, error) {
+	overriddenSources := make(map[string][]string)
+	for _, layer := range userLayers {
+		for variableName := range layer.Variables {
+			if _, ok := builtInVariables[variableName]; ok {
+				overriddenSources[variableName] = append(overriddenSources[variableName], layer.Source)
+			}
+		}
+	}
+	if len(overriddenSources) == 0 {
+		return builtInVariables, nil
+	}
+
+	overriddenNames := make([]string, 0, len(overriddenSources))
+	for variableName := range overriddenSources {
+		overriddenNames = append(overriddenNames, variableName)
+	}
+	sort.Strings(overriddenNames)
+
+	effectiveVariables := make(terraform.ConfigVariables)
+	for variableName, variableValue := range builtInVariables {
+		effectiveVariables[variableName] = variableValue
+	}
+
+	var deniedNames []string
+	for _, variableName := range overriddenNames {
+		sources := strings.Join(overriddenSources[variableName], ", ")
+
+		if driver.BuiltInOverridePolicy == builtInOverrideAllow || driver.isBuiltInOverrideAllowed(variableName) {
+			log.Infof("Built-in variable '%s' is overridden by the value from %s.", variableName, sources)
+			delete(effectiveVariables, variableName)
+
+			continue
+		}
+
+		switch driver.BuiltInOverridePolicy {
+		case builtInOverrideWarn:
+			log.Warnf("Ignoring value for built-in variable '%s' from %s (specify --terraform-builtin-override-allow %s to permit it).",
+				variableName,
+				sources,
+				variableName,
+			)
+		default:
+			log.Errorf("A value for built-in variable '%s' was supplied by %s.", variableName, sources)
+			deniedNames = append(deniedNames, variableName)
+		}
+	}
+	if len(deniedNames) > 0 {
+		return nil, fmt.Errorf("Values cannot be supplied for built-in variables (%s) unless they are permitted by --terraform-builtin-override or --terraform-builtin-override-allow",
+			strings.Join(deniedNames, ", "),
+		)
+	}
+
+	return effectiveVariables, nil
+}
+
+// Determine whether the user is permitted to override the specified built-in variable (regardless of the override policy).
+func (driver *Driver) isBuiltInOverrideAllowed(variableName string) bool {
+	for _, allowedName := range driver.BuiltInOverrideAllowed {
+		if allowedName == variableName {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Validate the built-in override policy and allow-list.
+func validateBuiltInOverrides(policy string, allowedNames []string) error {
+	switch policy {
+	case builtInOverrideDeny, builtInOverrideWarn, builtInOverrideAllow:
+	default:
+		return fmt.Errorf("Invalid argument: --terraform-builtin-override must be '%s', '%s', or '%s'",
+			builtInOverrideDeny,
+			builtInOverrideWarn,
+			builtInOverrideAllow,
+		)
+	}
+
+	for _, allowedName := range allowedNames {
+		if !isBuiltInVariable(allowedName) {
+			return fmt.Errorf("Invalid argument: --terraform-builtin-override-allow '%s' is not a built-in variable", allowedName)
+		}
+	}
+
+	return nil
+}
+
+// Determine whether the specified variable is one of the driver's built-in variables.
+func isBuiltInVariable(variableName string) bool {
+	for _, builtInVariableName := range builtInVariableNames {
+		if builtInVariableName == variableName {
+			return true
+		}
+	}
+
+	return false
+}
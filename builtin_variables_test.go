@@ -0,0 +1,193 @@
+package main
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/tintoy/docker-machine-driver-terraform/terraform"
+)
+
+// Matches a version 4 (random) UUID.
+var uuidV4Pattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+func TestGenerateMachineID(t *testing.T) {
+	firstID, err := generateMachineID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	secondID, err := generateMachineID()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, machineID := range []string{firstID, secondID} {
+		if !uuidV4Pattern.MatchString(machineID) {
+			t.Errorf("Expected '%s' to be a version 4 UUID", machineID)
+		}
+	}
+	if firstID == secondID {
+		t.Errorf("Expected machine Ids to be unique (got '%s' twice)", firstID)
+	}
+}
+
+func TestGetBuiltInVariables(t *testing.T) {
+	driver := &Driver{
+		BaseDriver: &drivers.BaseDriver{
+			MachineName:    "Web Server 1",
+			SSHKeyPath:     "/store/machines/web/id_rsa",
+			SSHUser:        "ubuntu",
+			SSHPort:        2222,
+			SwarmMaster:    true,
+			SwarmHost:      "tcp://0.0.0.0:3376",
+			SwarmDiscovery: "token://1234",
+			StorePath:      "/store",
+		},
+	}
+
+	builtInVariables, err := driver.getBuiltInVariables()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, variableName := range builtInVariableNames {
+		if _, ok := builtInVariables[variableName]; !ok {
+			t.Errorf("Expected a value for built-in variable '%s'", variableName)
+		}
+	}
+	if len(builtInVariables) != len(builtInVariableNames) {
+		t.Errorf("Expected %d built-in variables (got %d)", len(builtInVariableNames), len(builtInVariables))
+	}
+
+	machineID := driver.MachineID
+	if builtInVariables["dm_machine_id"] != machineID || !uuidV4Pattern.MatchString(machineID) {
+		t.Errorf("Expected dm_machine_id to be a version 4 UUID (got '%v')", builtInVariables["dm_machine_id"])
+	}
+	expectedValues := map[string]interface{}{
+		"dm_ssh_private_key_file": "/store/machines/web/id_rsa",
+		"dm_ssh_public_key_file":  "/store/machines/web/id_rsa.pub",
+		"dm_ssh_user":             "ubuntu",
+		"dm_ssh_port":             2222,
+		"dm_docker_port":          dockerPort,
+		"dm_swarm_master":         true,
+		"dm_swarm_host":           "tcp://0.0.0.0:3376",
+		"dm_swarm_discovery":      "token://1234",
+		"dm_store_path":           "/store",
+		"dm_machine_name_dns":     "web-server-1",
+		"dm_machine_name_slug":    "web-server-1",
+		"dm_machine_name_short":   "web-server-1",
+	}
+	for variableName, expectedValue := range expectedValues {
+		if builtInVariables[variableName] != expectedValue {
+			t.Errorf("Expected %s to be '%v' (got '%v')", variableName, expectedValue, builtInVariables[variableName])
+		}
+	}
+
+	// The machine Id must remain stable for the lifetime of the machine.
+	builtInVariables, err = driver.getBuiltInVariables()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if builtInVariables["dm_machine_id"] != machineID {
+		t.Errorf("Expected dm_machine_id to remain '%s' (got '%v')", machineID, builtInVariables["dm_machine_id"])
+	}
+}
+
+func TestApplyBuiltInOverrides(t *testing.T) {
+	builtInVariables := terraform.ConfigVariables{
+		"dm_machine_name": "web1",
+		"dm_client_ip":    "1.2.3.4",
+		"dm_ssh_user":     "root",
+	}
+	userLayers := []terraform.VariableLayer{
+		{Source: "file 'vars.json'", Variables: terraform.ConfigVariables{"dm_client_ip": "5.6.7.8", "region": "syd"}},
+		{Source: "--terraform-variable", Variables: terraform.ConfigVariables{"dm_ssh_user": "ubuntu"}},
+	}
+
+	testCases := []struct {
+		policy            string
+		allowedNames      []string
+		expectedVariables terraform.ConfigVariables
+		expectError       bool
+	}{
+		{
+			policy:      builtInOverrideDeny,
+			expectError: true,
+		},
+		{
+			policy:       builtInOverrideDeny,
+			allowedNames: []string{"dm_client_ip"},
+			expectError:  true, // dm_ssh_user is still denied
+		},
+		{
+			policy:            builtInOverrideDeny,
+			allowedNames:      []string{"dm_client_ip", "dm_ssh_user"},
+			expectedVariables: terraform.ConfigVariables{"dm_machine_name": "web1"},
+		},
+		{
+			policy:            builtInOverrideWarn,
+			expectedVariables: builtInVariables,
+		},
+		{
+			policy:            builtInOverrideWarn,
+			allowedNames:      []string{"dm_ssh_user"},
+			expectedVariables: terraform.ConfigVariables{"dm_machine_name": "web1", "dm_client_ip": "1.2.3.4"},
+		},
+		{
+			policy:            builtInOverrideAllow,
+			expectedVariables: terraform.ConfigVariables{"dm_machine_name": "web1"},
+		},
+	}
+	for _, testCase := range testCases {
+		driver := &Driver{
+			BuiltInOverridePolicy:  testCase.policy,
+			BuiltInOverrideAllowed: testCase.allowedNames,
+		}
+
+		effectiveVariables, err := driver.applyBuiltInOverrides(userLayers, builtInVariables)
+		if testCase.expectError {
+			if err == nil {
+				t.Errorf("Expected an error for policy '%s' (allowed: %v)", testCase.policy, testCase.allowedNames)
+			}
+
+			continue
+		}
+		if err != nil {
+			t.Errorf("Unexpected error for policy '%s' (allowed: %v): %s", testCase.policy, testCase.allowedNames, err.Error())
+
+			continue
+		}
+		if !reflect.DeepEqual(effectiveVariables, testCase.expectedVariables) {
+			t.Errorf("Expected %v for policy '%s' (allowed: %v) (got %v)", testCase.expectedVariables, testCase.policy, testCase.allowedNames, effectiveVariables)
+		}
+	}
+
+	if len(builtInVariables) != 3 {
+		t.Errorf("Expected the built-in variables to be unchanged (got %v)", builtInVariables)
+	}
+}
+
+func TestValidateBuiltInOverrides(t *testing.T) {
+	testCases := []struct {
+		policy        string
+		allowedNames  []string
+		expectedValid bool
+	}{
+		{builtInOverrideDeny, nil, true},
+		{builtInOverrideWarn, []string{"dm_client_ip"}, true},
+		{builtInOverrideAllow, []string{"dm_client_ip", "dm_ssh_user"}, true},
+		{"", nil, false},
+		{"ignore", nil, false},
+		{builtInOverrideDeny, []string{"region"}, false},
+		{builtInOverrideDeny, []string{"dm_no_such_variable"}, false},
+	}
+	for _, testCase := range testCases {
+		err := validateBuiltInOverrides(testCase.policy, testCase.allowedNames)
+		if testCase.expectedValid && err != nil {
+			t.Errorf("Unexpected error for policy '%s' (allowed: %v): %s", testCase.policy, testCase.allowedNames, err.Error())
+		} else if !testCase.expectedValid && err == nil {
+			t.Errorf("Expected an error for policy '%s' (allowed: %v)", testCase.policy, testCase.allowedNames)
+		}
+	}
+}
@@ -34,13 +34,20 @@ func (driver *Driver) importConfig() error {
 	}
 
 	log.Debugf("Fetching Terraform configuration from '%s...'", driver.ConfigSource)
-	err = fetch.Content(driver.ConfigSource, localConfigDir)
+	result, err := fetch.ContentWithOptions(driver.ConfigSource, localConfigDir, driver.getFetchOptions())
 	if err != nil {
 		return err
 	}
+	driver.ConfigCommit = result.Commit
+	driver.ConfigModuleVersion = result.Version
+	if driver.ConfigModuleVersion != "" {
+		log.Infof("Using version %s of module '%s'.", driver.ConfigModuleVersion, driver.ConfigSource)
+	}
+	if driver.ConfigCommit != "" {
+		log.Infof("Using commit %s of '%s'.", driver.ConfigCommit, driver.ConfigSource)
+	}
 
-	log.Debugf("Fetching Terraform modules (if any)...")
-	err = terraformer.Get()
+	err = driver.getModules(localConfigDir)
 	if err != nil {
 		return err
 	}
@@ -86,3 +93,17 @@ func (driver *Driver) ensureConfigDirIsResolved() error {
 
 	return nil
 }
+
+// Get the options used to fetch Terraform configuration.
+func (driver *Driver) getFetchOptions() fetch.Options {
+	return fetch.Options{
+		S3Endpoint:      driver.ConfigS3Endpoint,
+		Version:         driver.ConfigVersion,
+		GitSSHKeyFile:   driver.ConfigSSHKeyFile,
+		Checksum:        driver.ConfigChecksum,
+		Signature:       driver.ConfigSignature,
+		SignatureType:   driver.ConfigSignatureType,
+		TrustedKeysFile: driver.ConfigTrustedKeysFile,
+		Cache:           driver.getConfigCache(),
+	}
+}
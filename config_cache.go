@@ -0,0 +1,64 @@
+package main
+
+/*
+ * Driver implementation (configuration cache)
+ * -------------------------------------------
+ */
+
+import (
+	"path"
+	"time"
+
+	"github.com/docker/machine/libmachine/log"
+	"github.com/tintoy/docker-machine-driver-terraform/fetch"
+)
+
+const (
+	// The name of the directory (under the machine store's cache directory) where Terraform configuration and modules are cached.
+	configCacheDirName = "terraform"
+
+	// The default number of seconds that cached Terraform configuration (and modules) remains fresh.
+	defaultConfigCacheTTL = 3600
+)
+
+// Get the cache for Terraform configuration and modules.
+//
+// The cache is shared by all machines in the machine store.
+func (driver *Driver) getConfigCache() *fetch.Cache {
+	return &fetch.Cache{
+		Dir:     path.Join(driver.StorePath, "cache", configCacheDirName),
+		TTL:     time.Duration(driver.ConfigCacheTTL) * time.Second,
+		Offline: driver.Offline,
+	}
+}
+
+// Fetch the Terraform modules (if any) used by the configuration, using cached modules where possible.
+func (driver *Driver) getModules(localConfigDir string) error {
+	cache := driver.getConfigCache()
+	restored, err := cache.RestoreModules(localConfigDir)
+	if err != nil {
+		return err
+	}
+	if restored {
+		log.Debugf("Using cached Terraform modules.")
+
+		return nil
+	}
+
+	if driver.Offline {
+		// Modules that are not cached can still be used if they are local.
+		log.Warnf("No cached Terraform modules found for this configuration; running 'terraform get' anyway (this will fail if any modules must be downloaded).")
+	}
+
+	log.Debugf("Fetching Terraform modules (if any)...")
+	terraformer, err := driver.getTerraformer()
+	if err != nil {
+		return err
+	}
+	err = terraformer.Get()
+	if err != nil {
+		return err
+	}
+
+	return cache.StoreModules(localConfigDir)
+}
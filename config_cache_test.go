@@ -0,0 +1,31 @@
+package main
+
+import (
+	"path"
+	"testing"
+	"time"
+
+	"github.com/docker/machine/libmachine/drivers"
+)
+
+func TestGetConfigCache(t *testing.T) {
+	driver := &Driver{
+		BaseDriver:     &drivers.BaseDriver{MachineName: "web1", StorePath: "/machine-store"},
+		ConfigCacheTTL: 600,
+		Offline:        true,
+	}
+
+	cache := driver.getConfigCache()
+
+	// The cache is shared by all machines in the store.
+	expectedDir := path.Join("/machine-store", "cache", configCacheDirName)
+	if cache.Dir != expectedDir {
+		t.Errorf("Expected cache directory '%s' (got '%s')", expectedDir, cache.Dir)
+	}
+	if cache.TTL != 10*time.Minute {
+		t.Errorf("Expected cache TTL of 10 minutes (got %s)", cache.TTL)
+	}
+	if !cache.Offline {
+		t.Errorf("Expected the cache to be offline")
+	}
+}
@@ -6,14 +6,19 @@ package main
  */
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"path"
 	"strings"
 
 	"github.com/docker/machine/libmachine/log"
+	"github.com/tintoy/docker-machine-driver-terraform/terraform"
 )
 
+// The default prefix for environment variables that represent additional Terraform variables.
+const defaultVariablesEnvironmentPrefix = "DM_TF_VAR_"
+
 func (driver *Driver) getVariablesFileName() (string, error) {
 	localConfigDir, err := driver.getConfigDir()
 	if err != nil {
@@ -50,63 +55,211 @@ func (driver *Driver) readVariables() error {
 	return nil
 }
 
-// Read all additional variables (from the command line, or from a file)
-func (driver *Driver) readAdditionalVariables() error {
-	err := driver.readAdditionalVariablesInline()
+// Resolve the final set of Terraform variables from all sources.
+//
+// In order of increasing precedence, the sources are:
+//
+// 1. Files passed in on the command line (--terraform-variables-from), in the order specified.
+// 2. Environment variables whose names start with the configured prefix (--terraform-variables-env-prefix).
+// 3. Variables passed in on the command line (--terraform-variable).
+// 4. Built-in variables supplied by the driver (dm_xxx), unless the override policy permits the user-supplied value to take precedence.
+//
+// Default values declared in the Terraform configuration have the lowest precedence of all, but are applied by Terraform itself.
+func (driver *Driver) resolveVariables(builtInVariables terraform.ConfigVariables) error {
+	var layers []terraform.VariableLayer
+
+	fileLayers, err := driver.readAdditionalVariablesFiles()
 	if err != nil {
 		return err
 	}
+	layers = append(layers, fileLayers...)
+
+	layers = append(layers, driver.readAdditionalVariablesEnvironment())
+
+	inlineLayer, err := driver.readAdditionalVariablesInline()
+	if err != nil {
+		return err
+	}
+	layers = append(layers, inlineLayer)
+
+	// Only user-supplied values can contain templates.
+	err = driver.expandVariableTemplates(layers)
+	if err != nil {
+		return err
+	}
+
+	builtInVariables, err = driver.applyBuiltInOverrides(layers, builtInVariables)
+	if err != nil {
+		return err
+	}
+
+	layers = append(layers, terraform.VariableLayer{
+		Source:    "built-in",
+		Variables: builtInVariables,
+	})
+
+	var provenance terraform.VariableProvenance
+	driver.ConfigVariables, provenance = terraform.MergeVariableLayers(layers...)
 
-	return driver.readAdditionalVariablesFile()
+	// Secret references take precedence over values for the same variable from any other source.
+	for variableName := range driver.SecretVariables {
+		if isBuiltInVariable(variableName) {
+			return fmt.Errorf("Built-in variable '%s' cannot be a secret reference", variableName)
+		}
+
+		if _, ok := driver.ConfigVariables[variableName]; ok {
+			log.Debugf("Variable '%s' is a secret reference, which replaces the value from %s.",
+				variableName,
+				strings.Join(provenance[variableName], " merged with "),
+			)
+			delete(driver.ConfigVariables, variableName)
+		}
+		provenance[variableName] = []string{"--terraform-secret-variable"}
+	}
+
+	driver.registerSensitiveVariables()
+	driver.logVariableProvenance(provenance)
+
+	// The resolved values are now in ConfigVariables, so don't also persist the raw values supplied on the command line.
+	driver.AdditionalVariablesInline = nil
+	driver.additionalVariablesEnvironment = nil
+
+	return nil
 }
 
 // Read additional variables passed in on the command-line (--terraform-variable a=b --terraform-variable c=d)
-func (driver *Driver) readAdditionalVariablesInline() error {
+func (driver *Driver) readAdditionalVariablesInline() (terraform.VariableLayer, error) {
+	layer := terraform.VariableLayer{
+		Source:    "--terraform-variable",
+		Variables: make(terraform.ConfigVariables),
+	}
+
 	for _, additionalVariable := range driver.AdditionalVariablesInline {
 		variableNameAndValue := strings.SplitN(additionalVariable, "=", 2)
 		if len(variableNameAndValue) != 2 {
-			return fmt.Errorf("Invalid format for additional variable '%s", additionalVariable)
+			return layer, fmt.Errorf("Invalid format for additional variable '%s", additionalVariable)
 		}
 
-		// Don't overwrite existing entries.
-		_, variableExists := driver.ConfigVariables[variableNameAndValue[0]]
-		if variableExists {
-			continue
+		layer.Variables[variableNameAndValue[0]] = terraform.ParseVariableValue(variableNameAndValue[1])
+	}
+
+	return layer, nil
+}
+
+// Parse secret variables passed in on the command-line (--terraform-secret-variable a=env:B --terraform-secret-variable c=file:/d)
+//
+// Secret references can only be supplied this way (never via variables files or environment variables), since a command reference runs an arbitrary command.
+func parseSecretVariables(items []string) (map[string]string, error) {
+	secretVariables := make(map[string]string)
+	for _, item := range items {
+		variableNameAndReference := strings.SplitN(item, "=", 2)
+		if len(variableNameAndReference) != 2 || variableNameAndReference[0] == "" {
+			return nil, fmt.Errorf("Invalid argument: --terraform-secret-variable '%s' must be of the form 'name=reference'", item)
 		}
 
-		driver.ConfigVariables[variableNameAndValue[0]] = variableNameAndValue[1]
+		err := terraform.ValidateSecretReference(variableNameAndReference[1])
+		if err != nil {
+			return nil, fmt.Errorf("Invalid argument: --terraform-secret-variable for '%s': %s", variableNameAndReference[0], err.Error())
+		}
+
+		secretVariables[variableNameAndReference[0]] = variableNameAndReference[1]
 	}
 
-	return nil
+	return secretVariables, nil
+}
+
+// Read additional variables captured from the environment (DM_TF_VAR_a=b DM_TF_VAR_c=d)
+func (driver *Driver) readAdditionalVariablesEnvironment() terraform.VariableLayer {
+	layer := terraform.VariableLayer{
+		Source:    "environment",
+		Variables: make(terraform.ConfigVariables),
+	}
+
+	for variableName, variableValue := range driver.additionalVariablesEnvironment {
+		layer.Variables[variableName] = terraform.ParseVariableValue(variableValue)
+	}
+
+	return layer
 }
 
-// Read Terraform variables from the file passed in on the command-line.
-func (driver *Driver) readAdditionalVariablesFile() error {
-	if driver.AdditionalVariablesFile == "" {
-		return nil // Nothing to do
+// Capture additional variables from environment variables whose names start with the specified prefix.
+//
+// The prefix is removed from the name of each variable (e.g. DM_TF_VAR_region=syd becomes region=syd).
+func captureEnvironmentVariables(prefix string) map[string]string {
+	variables := make(map[string]string)
+	if prefix == "" {
+		return variables // Feature is disabled
+	}
+
+	for _, environmentVariable := range os.Environ() {
+		nameAndValue := strings.SplitN(environmentVariable, "=", 2)
+		if len(nameAndValue) != 2 || !strings.HasPrefix(nameAndValue[0], prefix) {
+			continue
+		}
+
+		variableName := strings.TrimPrefix(nameAndValue[0], prefix)
+		if variableName == "" {
+			continue
+		}
+
+		variables[variableName] = nameAndValue[1]
 	}
 
-	variablesFileName := driver.AdditionalVariablesFile
-	if !path.IsAbs(variablesFileName) {
-		workingDirectory, err := os.Getwd()
+	return variables
+}
+
+// Read Terraform variables from the files passed in on the command-line.
+func (driver *Driver) readAdditionalVariablesFiles() ([]terraform.VariableLayer, error) {
+	var layers []terraform.VariableLayer
+	for _, variablesFileName := range driver.AdditionalVariablesFiles {
+		if !path.IsAbs(variablesFileName) {
+			workingDirectory, err := os.Getwd()
+			if err != nil {
+				return nil, err
+			}
+			variablesFileName = path.Join(workingDirectory, variablesFileName)
+		}
+
+		log.Debugf("Reading additional Terraform variables from '%s'...", variablesFileName)
+
+		variables, err := terraform.ReadVariablesFile(variablesFileName)
 		if err != nil {
-			return err
+			return nil, fmt.Errorf("Unable to read additional variables from '%s': %s",
+				variablesFileName,
+				err.Error(),
+			)
 		}
-		variablesFileName = path.Join(workingDirectory, variablesFileName)
+
+		layers = append(layers, terraform.VariableLayer{
+			Source:    fmt.Sprintf("file '%s'", variablesFileName),
+			Variables: variables,
+		})
 	}
 
-	log.Debugf("Reading additional Terraform variables from '%s'...", variablesFileName)
+	return layers, nil
+}
+
+// Log the source of each variable's final value.
+func (driver *Driver) logVariableProvenance(provenance terraform.VariableProvenance) {
+	for _, variableName := range provenance.Names() {
+		if reference, ok := driver.SecretVariables[variableName]; ok {
+			log.Debugf("Variable '%s' = secret reference '%s' (from %s)", variableName, reference, provenance[variableName][0])
 
-	// This operation is additive (preserves exising variables).
-	err := driver.ConfigVariables.Read(variablesFileName)
-	if err != nil {
-		return fmt.Errorf("Unable to read additional variables from '%s': %s",
-			variablesFileName,
-			err.Error(),
+			continue
+		}
+
+		valueJSON, err := json.Marshal(driver.ConfigVariables[variableName])
+		if err != nil {
+			valueJSON = []byte("?")
+		}
+
+		sources := provenance[variableName]
+		log.Debugf("Variable '%s' = %s (from %s)",
+			variableName,
+			driver.redact(string(valueJSON)),
+			strings.Join(sources, " merged with "),
 		)
 	}
-
-	return nil
 }
 
 // Write Terraform variables to tfvars.json
@@ -116,15 +269,135 @@ func (driver *Driver) writeVariables() error {
 		return err
 	}
 
-	log.Debugf("Writing %d Terraform variables to '%s'...",
-		len(driver.ConfigVariables),
+	policy, err := driver.getNormalizationPolicy()
+	if err != nil {
+		return err
+	}
+
+	variables, err := driver.getTerraformVariables()
+	if err != nil {
+		return err
+	}
+
+	log.Debugf("Writing %d Terraform variables to '%s' (normalisation policy: %s)...",
+		len(variables),
 		variablesFileName,
+		policy,
 	)
 
-	err = driver.ConfigVariables.Write(variablesFileName)
+	// Secret references are resolved by the terraformer (immediately before each Terraform command that requires them).
+	secretReferences, err := driver.getSecretReferences()
+	if err != nil {
+		return err
+	}
+	if len(secretReferences) > 0 {
+		log.Debugf("%d Terraform variables are secret references, and will not be written to '%s'.",
+			len(secretReferences),
+			variablesFileName,
+		)
+	}
+	terraformer, err := driver.getTerraformer()
+	if err != nil {
+		return err
+	}
+	terraformer.SecretVariables = secretReferences
+
+	if driver.EncryptVariables {
+		return driver.writeEncryptedVariables(variables, variablesFileName, policy)
+	}
+
+	err = variables.WriteWithPolicy(variablesFileName, policy)
 	if err != nil {
 		return err
 	}
 
 	return nil
 }
+
+// Get the variables that will be passed to Terraform.
+//
+// Variables are renamed according to the variable map, declarations are generated for built-in variables that the configuration does not declare,
+// and (if required) undeclared variables are removed.
+func (driver *Driver) getTerraformVariables() (terraform.ConfigVariables, error) {
+	variables := driver.applyVariableMap(driver.ConfigVariables)
+
+	err := driver.declareBuiltInVariables(variables)
+	if err != nil {
+		return nil, err
+	}
+
+	if driver.StripUndeclaredVariables {
+		variables, err = driver.stripUndeclaredVariables(variables)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return variables, nil
+}
+
+// Get the variables that will be passed to Terraform whose values are references to secrets.
+//
+// Unlike getTerraformVariables, this does not modify the Terraform configuration directory.
+func (driver *Driver) getSecretReferences() (terraform.ConfigVariables, error) {
+	secretReferences := driver.applyVariableMap(driver.getSecretVariables())
+	if len(secretReferences) == 0 || !driver.StripUndeclaredVariables {
+		return secretReferences, nil
+	}
+
+	return driver.stripUndeclaredVariables(secretReferences)
+}
+
+// Get the driver's secret variables (whose values are references to secrets) as Terraform variables.
+func (driver *Driver) getSecretVariables() terraform.ConfigVariables {
+	secretVariables := make(terraform.ConfigVariables)
+	for variableName, reference := range driver.SecretVariables {
+		secretVariables[variableName] = reference
+	}
+
+	return secretVariables
+}
+
+// Write variables to the Terraform configuration directory in encrypted form.
+//
+// The driver's own copy of the variables is also encrypted (so that they are not persisted in plain text).
+func (driver *Driver) writeEncryptedVariables(variables terraform.ConfigVariables, variablesFileName string, policy terraform.NormalizationPolicy) error {
+	key, err := driver.getEncryptionKey()
+	if err != nil {
+		return err
+	}
+
+	encryptedVariablesFileName := path.Join(path.Dir(variablesFileName), terraform.EncryptedVariablesFileName)
+	log.Debugf("Encrypting Terraform variables to '%s'...", encryptedVariablesFileName)
+
+	err = variables.WriteEncrypted(encryptedVariablesFileName, policy, key)
+	if err != nil {
+		return err
+	}
+
+	// Never leave a plain-text copy of the variables lying around.
+	err = os.Remove(variablesFileName)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return driver.encryptConfigVariables()
+}
+
+// Determine how variable values should be normalised for the version of Terraform in use.
+func (driver *Driver) getNormalizationPolicy() (terraform.NormalizationPolicy, error) {
+	terraformer, err := driver.getTerraformer()
+	if err != nil {
+		return terraform.NormalizeLegacy, err
+	}
+
+	terraformVersion, err := terraformer.Version()
+	if err != nil {
+		log.Warnf("Unable to determine Terraform version (%s); variable values will be converted to strings.", err.Error())
+
+		return terraform.NormalizeLegacy, nil
+	}
+	log.Debugf("Terraform version is %s.", terraformVersion)
+
+	return terraform.NormalizationPolicyForVersion(terraformVersion), nil
+}
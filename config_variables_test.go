@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/tintoy/docker-machine-driver-terraform/terraform"
+)
+
+func TestResolveVariables(t *testing.T) {
+	testDir, err := ioutil.TempDir("", "config-variables-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(testDir)
+
+	baseVariablesFile := path.Join(testDir, "base.json")
+	err = ioutil.WriteFile(baseVariablesFile, []byte(`{"region": "syd", "size": "small", "tags": {"owner": "ops"}}`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	overrideVariablesFile := path.Join(testDir, "override.tfvars")
+	err = ioutil.WriteFile(overrideVariablesFile, []byte("region = \"mel\"\ntags = { env = \"prod\" }\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	driver := &Driver{
+		BaseDriver:               &drivers.BaseDriver{MachineName: "web1"},
+		AdditionalVariablesFiles: []string{baseVariablesFile, overrideVariablesFile},
+		AdditionalVariablesInline: []string{
+			"region=per",
+		},
+	}
+	err = driver.resolveVariables(terraform.ConfigVariables{
+		"dm_machine_name": "web1",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedVariables := terraform.ConfigVariables{
+		"region":          "per",   // --terraform-variable overrides files
+		"size":            "small", // Only supplied by the first file
+		"tags":            map[string]interface{}{"owner": "ops", "env": "prod"},
+		"dm_machine_name": "web1",
+	}
+	if !reflect.DeepEqual(driver.ConfigVariables, expectedVariables) {
+		t.Errorf("Expected %#v (got %#v)", expectedVariables, driver.ConfigVariables)
+	}
+}
+
+func TestResolveVariablesFromEnvironment(t *testing.T) {
+	testDir, err := ioutil.TempDir("", "config-variables-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(testDir)
+
+	variablesFile := path.Join(testDir, "vars.json")
+	err = ioutil.WriteFile(variablesFile, []byte(`{"region": "syd", "zone": "a"}`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	driver := &Driver{
+		BaseDriver:               &drivers.BaseDriver{MachineName: "web1"},
+		AdditionalVariablesFiles: []string{variablesFile},
+		additionalVariablesEnvironment: map[string]string{
+			"region": "mel",
+			"zone":   "b",
+			"count":  "3",
+			"zones":  `["b", "c"]`,
+		},
+		AdditionalVariablesInline: []string{
+			"region=per",
+		},
+	}
+	err = driver.resolveVariables(terraform.ConfigVariables{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedVariables := terraform.ConfigVariables{
+		"region": "per", // --terraform-variable overrides the environment
+		"zone":   "b",   // The environment overrides files
+		"count":  json.Number("3"),
+		"zones":  []interface{}{"b", "c"},
+	}
+	if !reflect.DeepEqual(driver.ConfigVariables, expectedVariables) {
+		t.Errorf("Expected %#v (got %#v)", expectedVariables, driver.ConfigVariables)
+	}
+	if driver.additionalVariablesEnvironment != nil {
+		t.Errorf("Values captured from the environment should not be retained once they have been resolved")
+	}
+}
+
+func TestCaptureEnvironmentVariables(t *testing.T) {
+	testEnvironment := map[string]string{
+		"DM_TEST_VAR_region":      "syd",
+		"DM_TEST_VAR_tags":        `{"owner":"ops"}`,
+		"DM_TEST_VAR_with_equals": "a=b",
+		"DM_TEST_VAR_":            "no name",
+		"DM_TEST_OTHER_region":    "mel",
+	}
+	for name, value := range testEnvironment {
+		os.Setenv(name, value)
+		defer os.Unsetenv(name)
+	}
+
+	expectedVariables := map[string]string{
+		"region":      "syd",
+		"tags":        `{"owner":"ops"}`,
+		"with_equals": "a=b",
+	}
+	variables := captureEnvironmentVariables("DM_TEST_VAR_")
+	if !reflect.DeepEqual(variables, expectedVariables) {
+		t.Errorf("Expected %v (got %v)", expectedVariables, variables)
+	}
+
+	variables = captureEnvironmentVariables("")
+	if len(variables) != 0 {
+		t.Errorf("Expected no variables to be captured when the prefix is empty (got %v)", variables)
+	}
+}
+
+func TestParseSecretVariables(t *testing.T) {
+	secretVariables, err := parseSecretVariables([]string{
+		"api_token=env:API_TOKEN",
+		"password=file:/path/to/password",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedSecretVariables := map[string]string{
+		"api_token": "env:API_TOKEN",
+		"password":  "file:/path/to/password",
+	}
+	if !reflect.DeepEqual(secretVariables, expectedSecretVariables) {
+		t.Errorf("Expected %v (got %v)", expectedSecretVariables, secretVariables)
+	}
+
+	invalidItems := []string{
+		"api_token",
+		"=env:API_TOKEN",
+		"api_token=hunter2",
+		"api_token=env:",
+	}
+	for _, item := range invalidItems {
+		_, err = parseSecretVariables([]string{item})
+		if err == nil {
+			t.Errorf("Expected an error for '%s'", item)
+		}
+	}
+}
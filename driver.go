@@ -10,6 +10,7 @@ import (
 	"fmt"
 	"net"
 	"os"
+	"strconv"
 
 	stdlog "log"
 
@@ -17,6 +18,7 @@ import (
 	"github.com/docker/machine/libmachine/log"
 	"github.com/docker/machine/libmachine/mcnflag"
 	"github.com/docker/machine/libmachine/state"
+	"github.com/tintoy/docker-machine-driver-terraform/fetch"
 	"github.com/tintoy/docker-machine-driver-terraform/terraform"
 )
 
@@ -27,21 +29,112 @@ type Driver struct {
 	// The source path (or URL) of the Terraform configuration.
 	ConfigSource string
 
+	// The endpoint to use when fetching configuration from s3:: sources (e.g. an S3-compatible object store).
+	ConfigS3Endpoint string
+
+	// The version constraint (e.g. "~> 2.1") used to select the version of the Terraform configuration (for module registry sources).
+	ConfigVersion string
+
+	// The path of the SSH private key file used to fetch the Terraform configuration from git repositories via SSH.
+	ConfigSSHKeyFile string
+
+	// The commit that the Terraform configuration was fetched from (for git sources).
+	ConfigCommit string
+
+	// The module version that the Terraform configuration was fetched from (for module registry sources).
+	ConfigModuleVersion string
+
+	// The expected checksum of the Terraform configuration (e.g. "sha256:0123...").
+	ConfigChecksum string
+
+	// The location (local path or URL) of a detached signature for the Terraform configuration.
+	ConfigSignature string
+
+	// The type of signature for the Terraform configuration ("minisign" or "gpg"; detected from the signature's file extension if not specified).
+	ConfigSignatureType string
+
+	// A file containing the public keys that are trusted to sign the Terraform configuration.
+	ConfigTrustedKeysFile string
+
+	// The number of seconds that cached Terraform configuration (and modules) remains fresh.
+	ConfigCacheTTL int
+
+	// Only use cached Terraform configuration (and modules)?
+	Offline bool
+
 	// The path of the directory containing the imported Terraform configuration.
 	ConfigDir string
 
 	// Additional variables for the Terraform configuration
 	ConfigVariables terraform.ConfigVariables
 
-	// An optional file containing the JSON that represents additional variables for the Terraform configuration
-	AdditionalVariablesFile string
+	// Optional files (JSON, HCL, or YAML) containing additional variables for the Terraform configuration
+	AdditionalVariablesFiles []string
 
 	// Optional "name=value" items that represent additional variables for the Terraform configuration
 	AdditionalVariablesInline []string
 
+	// The prefix that identifies environment variables representing additional variables for the Terraform configuration
+	AdditionalVariablesEnvironmentPrefix string
+
+	// Additional variables for the Terraform configuration captured from environment variables (keyed by variable name, with the prefix removed)
+	//
+	// Unexported, so that the captured values (which may be secrets) are not persisted in the machine's configuration.
+	additionalVariablesEnvironment map[string]string
+
+	// The names of variables whose values are sensitive (and must not be logged)
+	SensitiveVariables []string
+
+	// Variables whose values are references to secrets (e.g. api_token => env:API_TOKEN), resolved immediately before each Terraform command that requires them
+	//
+	// Only the references (never the secrets themselves) are persisted.
+	SecretVariables map[string]string
+
+	// How to handle variables that do not match the configuration's declarations ("strict", "warn", or "off")
+	VariableValidation string
+
+	// Maps the names of variables supplied to the driver to the names of variables declared by the configuration (e.g. dm_machine_name => name)
+	VariableMap map[string]string
+
+	// Maps the names of outputs used by the driver to the names of outputs declared by the configuration (e.g. dm_machine_ip => public_ip)
+	OutputMap map[string]string
+
+	// Don't generate declarations (dm_variables.tf.json) for built-in variables that the configuration does not declare
+	SkipBuiltInDeclarations bool
+
+	// Don't pass variables that the configuration does not declare to Terraform
+	StripUndeclaredVariables bool
+
+	// How to handle user-supplied values for built-in variables ("deny", "warn", or "allow")
+	BuiltInOverridePolicy string
+
+	// The names of built-in variables whose values the user may override (regardless of BuiltInOverridePolicy)
+	BuiltInOverrideAllowed []string
+
 	// Refresh the configuration after applying it
 	RefreshAfterApply bool
 
+	// The number of seconds that Terraform should wait to acquire a state lock.
+	StateLockTimeout int
+
+	// Forcibly release a Terraform state lock (if it is older than ForceUnlockAfter)
+	ForceUnlock bool
+
+	// The minimum age (in seconds) of a Terraform state lock before it can be forcibly released.
+	ForceUnlockAfter int
+
+	// The number of Terraform state snapshots to retain (0 disables snapshots).
+	StateHistoryLimit int
+
+	// Encrypt Terraform variables at rest (in tfvars.json and the persisted driver configuration).
+	EncryptVariables bool
+
+	// The path of the file containing the key used to encrypt variables (if not supplied via TERRAFORM_ENCRYPTION_KEY).
+	EncryptionKeyFile string
+
+	// The driver's variables, in encrypted form (used instead of ConfigVariables when EncryptVariables is true).
+	EncryptedConfigVariables string
+
 	// The full path to the Terraform executable.
 	TerraformExecutablePath string
 
@@ -50,8 +143,17 @@ type Driver struct {
 	// If not specified, a new key-pair will be generated.
 	SSHKey string
 
+	// The client's public (external) IP address (as detected when the machine was created).
+	ClientIP string
+
+	// A unique identifier for the machine (a UUID generated when the machine is created).
+	MachineID string
+
 	// The terraform executor.
 	terraformer *terraform.Terraformer
+
+	// Masks sensitive values before they are logged.
+	redactor *terraform.Redactor
 }
 
 // GetCreateFlags registers the "machine create" flags recognized by this driver, including
@@ -63,20 +165,145 @@ func (driver *Driver) GetCreateFlags() []mcnflag.Flag {
 			Usage: "The path (or URL) of the Terraform configuration",
 			Value: "",
 		},
+		mcnflag.StringFlag{
+			EnvVar: "TERRAFORM_CONFIG_S3_ENDPOINT",
+			Name:   "terraform-config-s3-endpoint",
+			Usage:  "The endpoint to use when fetching Terraform configuration from s3:: sources (e.g. an S3-compatible object store such as Minio)",
+			Value:  "",
+		},
+		mcnflag.StringFlag{
+			Name:  "terraform-config-version",
+			Usage: "The version constraint (e.g. \"~> 2.1\") used to select the module version when the Terraform configuration comes from a module registry",
+			Value: "",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "TERRAFORM_CONFIG_SSH_KEY",
+			Name:   "terraform-config-ssh-key",
+			Usage:  "The SSH private key file used to fetch the Terraform configuration from a git repository via SSH (e.g. a deploy key)",
+			Value:  "",
+		},
+		mcnflag.StringFlag{
+			Name:  "terraform-config-checksum",
+			Usage: "The expected checksum of the Terraform configuration (e.g. sha256:0123...); modules downloaded by terraform get are not verified",
+			Value: "",
+		},
+		mcnflag.StringFlag{
+			Name:  "terraform-config-signature",
+			Usage: "The path (or URL) of a detached signature for the Terraform configuration; modules downloaded by terraform get are not verified",
+			Value: "",
+		},
+		mcnflag.StringFlag{
+			Name:  "terraform-config-signature-type",
+			Usage: "The type of signature for the Terraform configuration (minisign or gpg; detected from the signature's file extension if not specified)",
+			Value: "",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "TERRAFORM_CONFIG_TRUSTED_KEYS",
+			Name:   "terraform-config-trusted-keys",
+			Usage:  "A file containing the public keys (minisign or GPG) that are trusted to sign the Terraform configuration",
+			Value:  "",
+		},
+		mcnflag.IntFlag{
+			Name:  "terraform-cache-ttl",
+			Usage: "The number of seconds that cached Terraform configuration (and modules) remains fresh (0 always fetches it again; local configuration is never cached). Default: 3600",
+			Value: defaultConfigCacheTTL,
+		},
+		mcnflag.BoolFlag{
+			EnvVar: "TERRAFORM_OFFLINE",
+			Name:   "terraform-offline",
+			Usage:  "Only use cached Terraform configuration (and modules), rather than fetching them",
+		},
 		mcnflag.StringSliceFlag{
 			Name:  "terraform-variable",
 			Usage: "Additional variable(s) for the Terraform configuration (in the form name=value)",
 			Value: []string{},
 		},
-		mcnflag.StringFlag{
+		mcnflag.StringSliceFlag{
 			Name:  "terraform-variables-from",
-			Usage: "The name of a file containing the JSON that represents additional variables for the Terraform configuration",
-			Value: "",
+			Usage: "The name of a file (JSON, HCL, or YAML) containing additional variables for the Terraform configuration (can be specified more than once; later files take precedence)",
+			Value: []string{},
+		},
+		mcnflag.StringFlag{
+			Name:  "terraform-variables-env-prefix",
+			Usage: "Environment variables whose names start with this prefix represent additional variables for the Terraform configuration (e.g. DM_TF_VAR_region=syd becomes region=syd). Specify an empty prefix to disable. Default: " + defaultVariablesEnvironmentPrefix,
+			Value: defaultVariablesEnvironmentPrefix,
+		},
+		mcnflag.StringSliceFlag{
+			Name:  "terraform-secret-variable",
+			Usage: "A variable for the Terraform configuration whose value is a reference to a secret, resolved at run-time (in the form name=reference, where reference is env:NAME, file:/path, or cmd:command)",
+			Value: []string{},
+		},
+		mcnflag.StringSliceFlag{
+			Name:  "terraform-sensitive-variable",
+			Usage: "The name of a Terraform variable whose value is sensitive and must never be logged",
+			Value: []string{},
+		},
+		mcnflag.StringFlag{
+			Name:  "terraform-variable-validation",
+			Usage: "How to handle variables that do not match the variables declared by the Terraform configuration (strict, warn, or off)",
+			Value: variableValidationWarn,
+		},
+		mcnflag.StringSliceFlag{
+			Name:  "terraform-variable-map",
+			Usage: "Pass a variable to Terraform under a different name (in the form from=to, e.g. dm_machine_name=name)",
+			Value: []string{},
+		},
+		mcnflag.StringSliceFlag{
+			Name:  "terraform-output-map",
+			Usage: "Read an output used by the driver from a Terraform output with a different name (in the form from=to, e.g. dm_machine_ip=public_ip)",
+			Value: []string{},
+		},
+		mcnflag.BoolFlag{
+			Name:  "terraform-skip-builtin-declarations",
+			Usage: "Don't generate declarations (dm_variables.tf.json) for built-in variables that the Terraform configuration does not declare",
+		},
+		mcnflag.BoolFlag{
+			Name:  "terraform-strip-undeclared-variables",
+			Usage: "Don't pass variables that the Terraform configuration does not declare to Terraform",
+		},
+		mcnflag.StringFlag{
+			Name:  "terraform-builtin-override",
+			Usage: "How to handle values supplied for built-in (dm_xxx) variables: deny (fail), warn (ignore them, with a warning), or allow (use them instead of the built-in values)",
+			Value: builtInOverrideWarn,
+		},
+		mcnflag.StringSliceFlag{
+			Name:  "terraform-builtin-override-allow",
+			Usage: "The name of a built-in (dm_xxx) variable whose value can be supplied by the user (regardless of --terraform-builtin-override)",
+			Value: []string{},
 		},
 		mcnflag.BoolFlag{
 			Name:  "terraform-refresh",
 			Usage: "Refresh the configuration after applying it",
 		},
+		mcnflag.IntFlag{
+			Name:  "terraform-lock-timeout",
+			Usage: "The number of seconds to wait for a lock on the Terraform state. Default: 0 (don't wait)",
+			Value: 0,
+		},
+		mcnflag.BoolFlag{
+			Name:  "terraform-force-unlock",
+			Usage: "Forcibly release a lock on the Terraform state if it is older than --terraform-force-unlock-after",
+		},
+		mcnflag.IntFlag{
+			Name:  "terraform-force-unlock-after",
+			Usage: "The minimum age (in seconds) of a lock on the Terraform state before it can be forcibly released. Default: 3600",
+			Value: defaultForceUnlockAfter,
+		},
+		mcnflag.IntFlag{
+			Name:  "terraform-state-history",
+			Usage: "The number of Terraform state snapshots to retain for the machine (0 disables snapshots). Default: 10",
+			Value: defaultStateHistoryLimit,
+		},
+		mcnflag.BoolFlag{
+			Name:  "terraform-encrypt",
+			Usage: "Encrypt Terraform variables at rest (the key is taken from $TERRAFORM_ENCRYPTION_KEY or --terraform-encryption-key-file)",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "TERRAFORM_ENCRYPTION_KEY_FILE",
+			Name:   "terraform-encryption-key-file",
+			Usage:  "The file containing the key used to encrypt Terraform variables",
+			Value:  "",
+		},
 		mcnflag.StringFlag{
 			EnvVar: "TERRAFORM_SSH_USER",
 			Name:   "terraform-ssh-user",
@@ -113,13 +340,43 @@ func (driver *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
 	}
 
 	driver.ConfigSource = flags.String("terraform-config")
+	driver.ConfigS3Endpoint = flags.String("terraform-config-s3-endpoint")
+	driver.ConfigVersion = flags.String("terraform-config-version")
+	driver.ConfigSSHKeyFile = flags.String("terraform-config-ssh-key")
+	driver.ConfigChecksum = flags.String("terraform-config-checksum")
+	driver.ConfigSignature = flags.String("terraform-config-signature")
+	driver.ConfigSignatureType = flags.String("terraform-config-signature-type")
+	driver.ConfigTrustedKeysFile = flags.String("terraform-config-trusted-keys")
+	driver.ConfigCacheTTL = flags.Int("terraform-cache-ttl")
+	driver.Offline = flags.Bool("terraform-offline")
 	driver.ConfigVariables = make(map[string]interface{})
 
 	driver.AdditionalVariablesInline = flags.StringSlice("terraform-variable")
-	driver.AdditionalVariablesFile = flags.String("terraform-variables-from")
+	driver.AdditionalVariablesFiles = flags.StringSlice("terraform-variables-from")
+	driver.AdditionalVariablesEnvironmentPrefix = flags.String("terraform-variables-env-prefix")
+	driver.additionalVariablesEnvironment = captureEnvironmentVariables(driver.AdditionalVariablesEnvironmentPrefix)
+	driver.SensitiveVariables = flags.StringSlice("terraform-sensitive-variable")
+	driver.VariableValidation = flags.String("terraform-variable-validation")
+	driver.SkipBuiltInDeclarations = flags.Bool("terraform-skip-builtin-declarations")
+	driver.StripUndeclaredVariables = flags.Bool("terraform-strip-undeclared-variables")
+	driver.BuiltInOverridePolicy = flags.String("terraform-builtin-override")
+	driver.BuiltInOverrideAllowed = flags.StringSlice("terraform-builtin-override-allow")
 
 	driver.RefreshAfterApply = flags.Bool("terraform-refresh")
 
+	driver.StateLockTimeout = flags.Int("terraform-lock-timeout")
+	driver.ForceUnlock = flags.Bool("terraform-force-unlock")
+	driver.ForceUnlockAfter = flags.Int("terraform-force-unlock-after")
+
+	driver.StateHistoryLimit = flags.Int("terraform-state-history")
+
+	driver.EncryptVariables = flags.Bool("terraform-encrypt")
+	driver.EncryptionKeyFile = flags.String("terraform-encryption-key-file")
+
+	driver.SwarmMaster = flags.Bool("swarm-master")
+	driver.SwarmHost = flags.String("swarm-host")
+	driver.SwarmDiscovery = flags.String("swarm-discovery")
+
 	driver.SSHPort = flags.Int("terraform-ssh-port")
 	driver.SSHUser = flags.String("terraform-ssh-user")
 	driver.SSHKey = flags.String("terraform-ssh-key")
@@ -128,6 +385,67 @@ func (driver *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
 	if driver.ConfigSource == "" {
 		return errors.New("Required argument: --terraform-config")
 	}
+	if !isValidVariableValidation(driver.VariableValidation) {
+		return fmt.Errorf("Invalid argument: --terraform-variable-validation must be '%s', '%s', or '%s'",
+			variableValidationStrict,
+			variableValidationWarn,
+			variableValidationOff,
+		)
+	}
+	err := validateBuiltInOverrides(driver.BuiltInOverridePolicy, driver.BuiltInOverrideAllowed)
+	if err != nil {
+		return err
+	}
+	driver.SecretVariables, err = parseSecretVariables(flags.StringSlice("terraform-secret-variable"))
+	if err != nil {
+		return err
+	}
+	driver.VariableMap, err = parseNameMap("--terraform-variable-map", flags.StringSlice("terraform-variable-map"))
+	if err != nil {
+		return err
+	}
+	driver.OutputMap, err = parseNameMap("--terraform-output-map", flags.StringSlice("terraform-output-map"))
+	if err != nil {
+		return err
+	}
+	err = validateOutputMap(driver.OutputMap)
+	if err != nil {
+		return err
+	}
+	if driver.StateLockTimeout < 0 {
+		return errors.New("Invalid argument: --terraform-lock-timeout cannot be negative")
+	}
+	if driver.ForceUnlockAfter < 0 {
+		return errors.New("Invalid argument: --terraform-force-unlock-after cannot be negative")
+	}
+	if driver.StateHistoryLimit < 0 {
+		return errors.New("Invalid argument: --terraform-state-history cannot be negative")
+	}
+	if driver.ConfigCacheTTL < 0 {
+		return errors.New("Invalid argument: --terraform-cache-ttl cannot be negative")
+	}
+	if driver.ConfigSSHKeyFile != "" {
+		_, err = os.Stat(driver.ConfigSSHKeyFile)
+		if err != nil {
+			return fmt.Errorf("Invalid argument: --terraform-config-ssh-key (%s)", err.Error())
+		}
+	}
+	if driver.ConfigSignature != "" && driver.ConfigTrustedKeysFile == "" {
+		return errors.New("Invalid argument: --terraform-config-signature requires --terraform-config-trusted-keys")
+	}
+	if driver.ConfigSignatureType != "" && driver.ConfigSignatureType != fetch.SignatureTypeMinisign && driver.ConfigSignatureType != fetch.SignatureTypeGPG {
+		return fmt.Errorf("Invalid argument: --terraform-config-signature-type must be '%s' or '%s'",
+			fetch.SignatureTypeMinisign,
+			fetch.SignatureTypeGPG,
+		)
+	}
+	if driver.EncryptionKeyFile != "" && !driver.EncryptVariables {
+		return errors.New("Invalid argument: --terraform-encryption-key-file requires --terraform-encrypt")
+	}
+	err = driver.validateEncryptionKey()
+	if err != nil {
+		return err
+	}
 
 	return nil
 }
@@ -138,10 +456,17 @@ func (driver *Driver) PreCreateCheck() error {
 		return errors.New("The source for Terraform configuration has not been specified")
 	}
 
+	var err error
 	log.Infof("Auto-detecting client's public (external) IP address...")
-	clientIP, err := getClientPublicIPv4Address()
-	if err != nil {
-		return err
+	driver.ClientIP, err = getClientPublicIPv4Address()
+	clientIPErr := err
+	if clientIPErr != nil {
+		// If the user is permitted to supply dm_client_ip, detection failure is only an error if they don't actually supply it.
+		if driver.BuiltInOverridePolicy != builtInOverrideAllow && !driver.isBuiltInOverrideAllowed("dm_client_ip") {
+			return clientIPErr
+		}
+
+		log.Warnf("Unable to auto-detect client's public (external) IP address: %s", clientIPErr.Error())
 	}
 
 	log.Infof("Will create machine '%s' using Terraform configuration from '%s'.",
@@ -173,21 +498,23 @@ func (driver *Driver) PreCreateCheck() error {
 		}
 	}
 
-	log.Debugf("Generating one-time password...")
-	driver.ConfigVariables["dm_onetime_password"], err = driver.generateOneTimePassword()
+	log.Infof("Customising terraform configuration...")
+	builtInVariables, err := driver.getBuiltInVariables()
 	if err != nil {
 		return err
 	}
 
-	log.Infof("Customising terraform configuration...")
-	driver.ConfigVariables["dm_client_ip"] = clientIP
-	driver.ConfigVariables["dm_machine_name"] = driver.MachineName
-	driver.ConfigVariables["dm_ssh_private_key_file"] = driver.SSHKeyPath
-	driver.ConfigVariables["dm_ssh_public_key_file"] = driver.SSHKeyPath + ".pub"
-	driver.ConfigVariables["dm_ssh_user"] = driver.SSHUser
-	driver.ConfigVariables["dm_ssh_port"] = driver.SSHPort
-
-	err = driver.readAdditionalVariables()
+	err = driver.resolveVariables(builtInVariables)
+	if err != nil {
+		return err
+	}
+	suppliedClientIP, _ := driver.ConfigVariables["dm_client_ip"].(string)
+	if clientIPErr != nil && suppliedClientIP == "" {
+		return fmt.Errorf("Unable to auto-detect client's public (external) IP address, and no value was supplied for dm_client_ip: %s", clientIPErr.Error())
+	}
+
+	log.Infof("Validating Terraform variables...")
+	err = driver.validateVariables()
 	if err != nil {
 		return err
 	}
@@ -209,59 +536,121 @@ func (driver *Driver) Create() error {
 		return err
 	}
 
-	success, err := terraformer.Apply()
+	err = driver.snapshotState("apply")
+	if err != nil {
+		return err
+	}
+
+	var success bool
+	err = driver.withStateLockRecovery(func() (applyError error) {
+		success, applyError = terraformer.Apply()
+
+		return
+	})
 	if err != nil {
 		return err
 	}
 	if !success {
 		return errors.New("Failed to apply Terraform configuration")
 	}
+	err = driver.protectLocalState()
+	if err != nil {
+		return err
+	}
 
 	if driver.RefreshAfterApply {
 		log.Infof("Refreshing Terraform configuration state...")
-		err = terraformer.Refresh()
+		err = driver.snapshotState("refresh")
+		if err != nil {
+			return err
+		}
+		err = driver.withStateLockRecovery(terraformer.Refresh)
+		if err != nil {
+			return err
+		}
+		err = driver.protectLocalState()
 		if err != nil {
 			return err
 		}
 	}
 
-	outputs, err := terraformer.Output()
+	outputs, err := driver.readOutputs()
 	if err != nil {
 		return err
 	}
-	if !success {
-		return fmt.Errorf("Failed to obtain Terraform outputs")
-	}
 
-	output, ok := outputs["dm_machine_ip"]
-	if !ok {
-		return fmt.Errorf("Configuration does not declare required output 'dm_machine_ip'")
+	machineIPOutputName := driver.getOutputName("dm_machine_ip")
+	if !outputs.Has(machineIPOutputName) {
+		return fmt.Errorf("Configuration does not declare required output '%s'", machineIPOutputName)
+	}
+	driver.IPAddress, err = outputs.GetString(machineIPOutputName)
+	if err != nil {
+		return err
 	}
-	driver.IPAddress = output.Value.(string)
 
-	output, ok = outputs["dm_ssh_user"]
-	if ok {
-		driver.SSHUser = output.Value.(string)
+	sshUserOutputName := driver.getOutputName("dm_ssh_user")
+	if outputs.Has(sshUserOutputName) {
+		driver.SSHUser, err = outputs.GetString(sshUserOutputName)
+		if err != nil {
+			return err
+		}
 	}
 
-	log.Infof("Deployed host has IP '%s'.", driver.IPAddress)
-	log.Infof("Deployed host has SSH user '%s'.", driver.SSHUser)
+	log.Infof("Deployed host has IP '%s'.", driver.redact(driver.IPAddress))
+	log.Infof("Deployed host has SSH user '%s'.", driver.redact(driver.SSHUser))
 
 	return nil
 }
 
-// GetState retrieves the status of the target Docker Machine instance in CloudControl.
+// GetState retrieves the status of the target Docker Machine instance.
+//
+// This is determined from the Terraform state (the machine is considered to be running if the state contains any resources).
 func (driver *Driver) GetState() (state.State, error) {
+	if driver.ConfigDir == "" {
+		return state.None, nil // Not created yet
+	}
+
+	terraformer, err := driver.getTerraformer()
+	if err != nil {
+		return state.Error, err
+	}
+
+	currentState, err := terraformer.ReadState()
+	if err != nil {
+		return state.Error, err
+	}
+	if currentState == nil || currentState.IsEmpty() {
+		return state.None, nil
+	}
+
 	return state.Running, nil
 }
 
 // GetURL returns docker daemon URL on the target machine
 func (driver *Driver) GetURL() (string, error) {
+	if driver.IPAddress == "" && driver.ConfigDir != "" {
+		terraformer, err := driver.getTerraformer()
+		if err != nil {
+			return "", err
+		}
+
+		outputs, err := terraformer.StateOutputs()
+		if err != nil {
+			return "", err
+		}
+		machineIPOutputName := driver.getOutputName("dm_machine_ip")
+		if outputs.Has(machineIPOutputName) {
+			driver.IPAddress, err = outputs.GetString(machineIPOutputName)
+			if err != nil {
+				return "", err
+			}
+		}
+	}
 	if driver.IPAddress == "" {
 		return "", nil
 	}
 
-	url := fmt.Sprintf("tcp://%s", net.JoinHostPort(driver.IPAddress, "2376"))
+	url := fmt.Sprintf("tcp://%s", net.JoinHostPort(driver.IPAddress, strconv.Itoa(dockerPort)))
 
 	return url, nil
 }
@@ -270,12 +659,27 @@ func (driver *Driver) GetURL() (string, error) {
 func (driver *Driver) Remove() error {
 	log.Infof("Destroying terraform configuration...")
 
+	err := driver.decryptConfigVariables()
+	if err != nil {
+		return err
+	}
+
 	terraformer, err := driver.getTerraformer()
 	if err != nil {
 		return err
 	}
 
-	success, err := terraformer.Destroy()
+	err = driver.snapshotState("destroy")
+	if err != nil {
+		return err
+	}
+
+	var success bool
+	err = driver.withStateLockRecovery(func() (destroyError error) {
+		success, destroyError = terraformer.Destroy()
+
+		return
+	})
 	if err != nil {
 		return err
 	}
@@ -283,7 +687,7 @@ func (driver *Driver) Remove() error {
 		return errors.New("Failed to destroy Terraform configuration")
 	}
 
-	return nil
+	return driver.protectLocalState()
 }
 
 // Start the target machine.
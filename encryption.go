@@ -0,0 +1,148 @@
+package main
+
+/*
+ * Driver implementation (protection of variables and state at rest)
+ * -----------------------------------------------------------------
+ *
+ * Files that may contain secrets (variables and local Terraform state) are only readable by the current user.
+ * When encryption is enabled, variables are written to tfvars.json.enc (rather than tfvars.json),
+ * and the driver's persisted copy of the variables (in the machine's config.json) is replaced with an encrypted blob.
+ */
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+
+	"github.com/docker/machine/libmachine/log"
+	"github.com/tintoy/docker-machine-driver-terraform/terraform"
+)
+
+// The environment variable that supplies the key used to encrypt variables.
+const encryptionKeyEnvironmentVariable = "TERRAFORM_ENCRYPTION_KEY"
+
+// Get the key used to encrypt and decrypt variables.
+//
+// The key is taken from the TERRAFORM_ENCRYPTION_KEY environment variable or, if that is not set, from the configured key file.
+func (driver *Driver) getEncryptionKey() ([]byte, error) {
+	keyMaterial := []byte(os.Getenv(encryptionKeyEnvironmentVariable))
+	keySource := encryptionKeyEnvironmentVariable
+	if len(keyMaterial) == 0 && driver.EncryptionKeyFile != "" {
+		var err error
+		keyMaterial, err = ioutil.ReadFile(driver.EncryptionKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to read encryption key file '%s': %s", driver.EncryptionKeyFile, err.Error())
+		}
+		keySource = fmt.Sprintf("encryption key file '%s'", driver.EncryptionKeyFile)
+	}
+
+	if len(bytes.TrimSpace(keyMaterial)) == 0 {
+		return nil, fmt.Errorf("Encryption of Terraform variables is enabled for machine '%s', but no encryption key is available (set %s or specify --terraform-encryption-key-file)",
+			driver.MachineName,
+			encryptionKeyEnvironmentVariable,
+		)
+	}
+
+	key, err := terraform.ParseEncryptionKey(keyMaterial)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid key in %s: %s", keySource, err.Error())
+	}
+
+	return key, nil
+}
+
+// Replace the driver's variables with an encrypted copy (so they are not persisted in plain text).
+func (driver *Driver) encryptConfigVariables() error {
+	key, err := driver.getEncryptionKey()
+	if err != nil {
+		return err
+	}
+
+	variablesJSON, err := json.Marshal(driver.ConfigVariables)
+	if err != nil {
+		return err
+	}
+
+	driver.EncryptedConfigVariables, err = terraform.EncryptData(key, variablesJSON)
+	if err != nil {
+		return err
+	}
+	driver.ConfigVariables = nil
+
+	// The raw values supplied on the command line are only needed to resolve ConfigVariables, so they are not retained.
+	driver.AdditionalVariablesInline = nil
+	driver.additionalVariablesEnvironment = nil
+
+	return nil
+}
+
+// Restore the driver's variables from their encrypted copy (if required).
+func (driver *Driver) decryptConfigVariables() error {
+	if !driver.EncryptVariables || driver.EncryptedConfigVariables == "" || len(driver.ConfigVariables) > 0 {
+		return nil
+	}
+
+	key, err := driver.getEncryptionKey()
+	if err != nil {
+		return err
+	}
+
+	variablesJSON, err := terraform.DecryptData(key, driver.EncryptedConfigVariables)
+	if err != nil {
+		return fmt.Errorf("Unable to decrypt Terraform variables for machine '%s': %s", driver.MachineName, err.Error())
+	}
+
+	configVariables := make(terraform.ConfigVariables)
+	err = json.Unmarshal(variablesJSON, &configVariables)
+	if err != nil {
+		return err
+	}
+	driver.ConfigVariables = configVariables
+
+	log.Debugf("Decrypted %d Terraform variables.", len(driver.ConfigVariables))
+
+	// The terraformer may have been created before the variables were available.
+	if driver.terraformer != nil {
+		driver.registerSensitiveVariables()
+		driver.terraformer.SecretVariables, err = driver.getSecretReferences()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Ensure that the encryption key is available (if encryption is enabled).
+func (driver *Driver) validateEncryptionKey() error {
+	if !driver.EncryptVariables {
+		return nil
+	}
+
+	_, err := driver.getEncryptionKey()
+
+	return err
+}
+
+// The local Terraform state files (which may contain secrets).
+var localStateFileNames = []string{terraform.LocalStateFileName, terraform.LocalStateFileName + ".backup"}
+
+// Ensure that local Terraform state files are only readable by the current user.
+func (driver *Driver) protectLocalState() error {
+	localConfigDir, err := driver.getConfigDir()
+	if err != nil {
+		return err
+	}
+
+	for _, localStateFileName := range localStateFileNames {
+		err = os.Chmod(path.Join(localConfigDir, localStateFileName), 0600 /* u=rw,g=,o= */)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/tintoy/docker-machine-driver-terraform/terraform"
+)
+
+// A valid (base64-encoded) encryption key.
+const testEncryptionKey = "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="
+
+func TestEncryptConfigVariables(t *testing.T) {
+	os.Setenv(encryptionKeyEnvironmentVariable, testEncryptionKey)
+	defer os.Unsetenv(encryptionKeyEnvironmentVariable)
+
+	variables := terraform.ConfigVariables{
+		"region":   "syd",
+		"password": "hunter2",
+	}
+	driver := &Driver{
+		BaseDriver:       &drivers.BaseDriver{MachineName: "web1"},
+		EncryptVariables: true,
+		ConfigVariables:  variables,
+	}
+
+	err := driver.encryptConfigVariables()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if driver.ConfigVariables != nil {
+		t.Errorf("Expected plain-text variables to be removed once they have been encrypted")
+	}
+	if driver.EncryptedConfigVariables == "" || strings.Contains(driver.EncryptedConfigVariables, "hunter2") {
+		t.Fatalf("Expected variables to be encrypted (got '%s')", driver.EncryptedConfigVariables)
+	}
+
+	err = driver.decryptConfigVariables()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(driver.ConfigVariables, variables) {
+		t.Errorf("Expected %v (got %v)", variables, driver.ConfigVariables)
+	}
+}
+
+func TestGetEncryptionKey(t *testing.T) {
+	testDir, err := ioutil.TempDir("", "encryption-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(testDir)
+
+	keyFile := path.Join(testDir, "key")
+	err = ioutil.WriteFile(keyFile, []byte(testEncryptionKey+"\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	driver := &Driver{
+		BaseDriver:        &drivers.BaseDriver{MachineName: "web1"},
+		EncryptVariables:  true,
+		EncryptionKeyFile: keyFile,
+	}
+	_, err = driver.getEncryptionKey()
+	if err != nil {
+		t.Errorf("Unexpected error reading key from file: %s", err.Error())
+	}
+
+	driver.EncryptionKeyFile = ""
+	err = driver.validateEncryptionKey()
+	if err == nil {
+		t.Errorf("Expected an error when no encryption key is available")
+	}
+}
+
+func TestProtectLocalState(t *testing.T) {
+	configDir, err := ioutil.TempDir("", "encryption-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(configDir)
+
+	stateFile := path.Join(configDir, terraform.LocalStateFileName)
+	err = ioutil.WriteFile(stateFile, []byte("{}"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The backup file does not exist, which is not an error.
+	driver := &Driver{ConfigDir: configDir}
+	err = driver.protectLocalState()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(stateFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected mode 0600 (got %o)", info.Mode().Perm())
+	}
+}
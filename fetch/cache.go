@@ -0,0 +1,384 @@
+package fetch
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-getter"
+)
+
+// Cache is a content-addressed cache of fetched content (which can be shared between machines).
+//
+// Each source is mapped to the hash of its content (see Checksum), and the content itself is stored under that hash
+// (so content that is fetched from several sources is only stored once).
+type Cache struct {
+	// The directory where cached content is stored.
+	Dir string
+
+	// How long cached content remains fresh (after which, it is fetched again).
+	TTL time.Duration
+
+	// Only use cached content (never fetch it)?
+	Offline bool
+}
+
+// An entry in the cache that maps a source to its content.
+type cacheEntry struct {
+	// The source that the content was fetched from.
+	Source string `json:"source"`
+
+	// The hash of the content (see Checksum).
+	ContentHash string `json:"content_hash"`
+
+	// The date / time when the content was fetched.
+	FetchedAt time.Time `json:"fetched_at"`
+
+	// A description of the fetched content (if available).
+	Result *Result `json:"result,omitempty"`
+}
+
+const (
+	// The name of the directory (under .terraform) where Terraform stores modules.
+	modulesDirName = "modules"
+
+	// The name of the file or directory (under the directory for its hash) where cached content is stored.
+	cachedContentName = "content"
+)
+
+// RestoreModules restores the Terraform modules (.terraform/modules) for the configuration in configDir from the cache.
+//
+// Modules are cached by the hash of the configuration that uses them, and the restored modules are verified against the hash recorded when they were stored.
+// Returns false if the modules have not been cached, (unless the cache is offline) the cached modules are no longer fresh, or they fail verification
+// (which is an error if the cache is offline, since they cannot be fetched again).
+func (cache *Cache) RestoreModules(configDir string) (bool, error) {
+	entryFile, err := cache.getModulesEntryFile(configDir)
+	if err != nil {
+		return false, err
+	}
+
+	entry, err := cache.readEntry(entryFile)
+	if err != nil {
+		return false, err
+	}
+	if entry == nil || !(cache.Offline || cache.isFresh(entry)) {
+		return false, nil
+	}
+
+	modulesDir := path.Join(configDir, ".terraform", modulesDirName)
+	err = os.RemoveAll(modulesDir)
+	if err != nil {
+		return false, err
+	}
+	err = copyContent(cache.getContentPath(entry.ContentHash), modulesDir)
+	if err != nil {
+		return false, err
+	}
+
+	// Ensure that the cached modules have not been modified since they were stored.
+	modulesHash, err := Checksum(modulesDir, "sha256")
+	if err == nil && modulesHash != entry.ContentHash {
+		err = fmt.Errorf("expected '%s' but found '%s'", entry.ContentHash, modulesHash)
+	}
+	if err != nil {
+		os.RemoveAll(modulesDir)
+		if cache.Offline {
+			return false, fmt.Errorf("Unable to use cached Terraform modules (and cannot fetch them while offline): %s", err.Error())
+		}
+
+		return false, nil // Fetch them again
+	}
+
+	return true, nil
+}
+
+// StoreModules stores the Terraform modules (.terraform/modules) for the configuration in configDir in the cache.
+//
+// If the configuration does not use any modules, nothing is stored.
+func (cache *Cache) StoreModules(configDir string) error {
+	modulesDir := path.Join(configDir, ".terraform", modulesDirName)
+	_, err := os.Stat(modulesDir)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	entryFile, err := cache.getModulesEntryFile(configDir)
+	if err != nil {
+		return err
+	}
+
+	return cache.storeContent(entryFile, configDir, modulesDir, nil)
+}
+
+// Fetch content using the cache.
+//
+// Fresh cached content is used if available (or, if the cache is offline, any cached content); otherwise, the content is fetched and then cached.
+// Content is verified (see verifyContent) before it is cached, and again whenever it is used.
+// The description of the content (recorded in result) is cached with it.
+func (cache *Cache) fetch(client *getter.Client, options Options, result *Result) error {
+	entryFile := cache.getSourceEntryFile(client, options)
+	entry, err := cache.readEntry(entryFile)
+	if err != nil {
+		return err
+	}
+
+	if entry != nil && (cache.Offline || cache.isFresh(entry)) {
+		err = copyContent(cache.getContentPath(entry.ContentHash), client.Dst)
+		if err == nil {
+			err = verifyContent(client.Dst, options)
+			if err == nil {
+				if entry.Result != nil {
+					*result = *entry.Result
+				}
+
+				return nil
+			}
+		}
+		os.RemoveAll(client.Dst)
+
+		if cache.Offline {
+			return fmt.Errorf("Unable to use cached content for '%s' (and cannot fetch it while offline): %s", withoutSSHKey(client.Src), err.Error())
+		}
+
+		// Cached content is unusable; fall back to fetching it.
+	} else if cache.Offline {
+		return fmt.Errorf("Content for '%s' has not been cached (and cannot be fetched while offline)", withoutSSHKey(client.Src))
+	}
+
+	err = fetchAndVerify(client, options)
+	if err != nil {
+		return err
+	}
+
+	return cache.storeContent(entryFile, withoutSSHKey(client.Src), client.Dst, result)
+}
+
+// Store content in the cache, and record it in the specified entry file.
+func (cache *Cache) storeContent(entryFile string, source string, contentPath string, result *Result) error {
+	contentHash, err := Checksum(contentPath, "sha256")
+	if err != nil {
+		return err
+	}
+
+	cachedContentDir := path.Dir(cache.getContentPath(contentHash))
+	_, err = os.Stat(cachedContentDir)
+	if os.IsNotExist(err) {
+		err = cache.addContent(cachedContentDir, contentPath)
+	}
+	if err != nil {
+		return err
+	}
+
+	return writeEntry(entryFile, &cacheEntry{
+		Source:      source,
+		ContentHash: contentHash,
+		FetchedAt:   time.Now().UTC(),
+		Result:      result,
+	})
+}
+
+// Add content to the cache.
+//
+// Content is copied to a temporary directory, which is then renamed (so partially-copied content is never visible to other processes).
+func (cache *Cache) addContent(cachedContentDir string, contentPath string) error {
+	contentRootDir := path.Dir(cachedContentDir)
+	err := os.MkdirAll(contentRootDir, 0700 /* u=rwx,g=,o= */)
+	if err != nil {
+		return err
+	}
+
+	tempDir, err := ioutil.TempDir(contentRootDir, ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tempDir)
+
+	err = copyContent(contentPath, path.Join(tempDir, cachedContentName))
+	if err != nil {
+		return err
+	}
+
+	err = os.Rename(tempDir, cachedContentDir)
+	if err != nil {
+		// Another process may have added the same content in the meantime.
+		if _, statErr := os.Stat(cachedContentDir); statErr == nil {
+			return nil
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// Is the cached content recorded by the specified entry still fresh?
+func (cache *Cache) isFresh(entry *cacheEntry) bool {
+	return time.Since(entry.FetchedAt) < cache.TTL
+}
+
+// Get the path of the cache entry for the specified source.
+//
+// Sources are identified by everything that affects the content that is fetched from them (but never by an SSH private key in the source).
+func (cache *Cache) getSourceEntryFile(client *getter.Client, options Options) string {
+	sourceKey := fmt.Sprintf("%d\n%s\n%s\n%s\n%s\n%s", client.Mode, withoutSSHKey(client.Src), options.gitSCPAddress, options.S3Endpoint, options.Checksum, options.Version)
+
+	return path.Join(cache.Dir, "sources", hashString(sourceKey)+".json")
+}
+
+// Get the path of the cache entry for the modules used by the configuration in the specified directory.
+func (cache *Cache) getModulesEntryFile(configDir string) (string, error) {
+	configHash, err := Checksum(configDir, "sha256")
+	if err != nil {
+		return "", err
+	}
+
+	return path.Join(cache.Dir, "modules", strings.TrimPrefix(configHash, "sha256:")+".json"), nil
+}
+
+// Get the path of the cached content with the specified hash.
+func (cache *Cache) getContentPath(contentHash string) string {
+	return path.Join(cache.Dir, "content", strings.TrimPrefix(contentHash, "sha256:"), cachedContentName)
+}
+
+// Read a cache entry (returns nil if the entry does not exist).
+func (cache *Cache) readEntry(entryFile string) (*cacheEntry, error) {
+	entryJSON, err := ioutil.ReadFile(entryFile)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	entry := &cacheEntry{}
+	err = json.Unmarshal(entryJSON, entry)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid cache entry '%s': %s", entryFile, err.Error())
+	}
+
+	// Ignore entries whose content has been removed.
+	_, err = os.Lstat(cache.getContentPath(entry.ContentHash))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return entry, nil
+}
+
+// Write a cache entry (via a temporary file, so that a partially-written entry is never visible to other processes).
+func writeEntry(entryFile string, entry *cacheEntry) error {
+	entryJSON, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(path.Dir(entryFile), 0700 /* u=rwx,g=,o= */)
+	if err != nil {
+		return err
+	}
+
+	tempFile, err := ioutil.TempFile(path.Dir(entryFile), ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tempFile.Name())
+
+	_, err = tempFile.Write(entryJSON)
+	tempFile.Close()
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tempFile.Name(), entryFile)
+}
+
+// Copy content (a file or directory) to the specified destination.
+//
+// Symbolic links within a directory are copied as-is.
+func copyContent(source string, destination string) error {
+	source, err := filepath.EvalSymlinks(source) // The file getter links to local directories, rather than copying them
+	if err != nil {
+		return err
+	}
+
+	info, err := os.Stat(source)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return copyFile(source, destination, info.Mode())
+	}
+
+	return filepath.Walk(source, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relativePath, err := filepath.Rel(source, filePath)
+		if err != nil {
+			return err
+		}
+		targetPath := filepath.Join(destination, relativePath)
+
+		switch {
+		case info.IsDir():
+			return os.MkdirAll(targetPath, info.Mode().Perm()|0700 /* u=rwx */)
+		case info.Mode()&os.ModeSymlink != 0:
+			linkTarget, err := os.Readlink(filePath)
+			if err != nil {
+				return err
+			}
+
+			return os.Symlink(linkTarget, targetPath)
+		case info.Mode().IsRegular():
+			return copyFile(filePath, targetPath, info.Mode())
+		default:
+			return nil // Ignore devices, sockets, etc.
+		}
+	})
+}
+
+// Copy a file to the specified destination.
+func copyFile(source string, destination string, mode os.FileMode) error {
+	sourceFile, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer sourceFile.Close()
+
+	err = os.MkdirAll(path.Dir(destination), 0700 /* u=rwx,g=,o= */)
+	if err != nil {
+		return err
+	}
+
+	destinationFile, err := os.OpenFile(destination, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, mode.Perm())
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(destinationFile, sourceFile)
+	closeErr := destinationFile.Close()
+	if err != nil {
+		return err
+	}
+
+	return closeErr
+}
+
+// Get the (hex-encoded) SHA256 hash of a string.
+func hashString(value string) string {
+	valueHash := sha256.Sum256([]byte(value))
+
+	return hex.EncodeToString(valueHash[:])
+}
@@ -0,0 +1,334 @@
+package fetch
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-getter"
+)
+
+func TestRestoreModulesVerifiesCachedModules(t *testing.T) {
+	testDir, err := ioutil.TempDir("", "cache-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(testDir)
+
+	configDir := path.Join(testDir, "config")
+	modulesDir := path.Join(configDir, ".terraform", modulesDirName)
+	err = os.MkdirAll(path.Join(modulesDir, "network"), 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(path.Join(configDir, "main.tf"), []byte("# main"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(path.Join(modulesDir, "network", "main.tf"), []byte("# network"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cache := &Cache{
+		Dir: path.Join(testDir, "cache"),
+		TTL: time.Hour,
+	}
+	err = cache.StoreModules(configDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restored, err := cache.RestoreModules(configDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !restored {
+		t.Fatal("Expected cached modules to be restored")
+	}
+
+	// Tamper with the cached modules.
+	entryFile, err := cache.getModulesEntryFile(configDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry, err := cache.readEntry(entryFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(path.Join(cache.getContentPath(entry.ContentHash), "network", "main.tf"), []byte("# tampered"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restored, err = cache.RestoreModules(configDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored {
+		t.Fatal("Modified cached modules should not be restored")
+	}
+	if _, err = os.Stat(modulesDir); !os.IsNotExist(err) {
+		t.Fatal("Modules that fail verification should be removed")
+	}
+
+	cache.Offline = true
+	_, err = cache.RestoreModules(configDir)
+	if err == nil {
+		t.Fatal("Expected an error for modified cached modules while offline")
+	}
+}
+
+// A stand-in for a remote server that serves configuration files (and counts the requests for each one).
+type testConfigServer struct {
+	*httptest.Server
+
+	// The number of requests for each path.
+	Requests map[string]int
+}
+
+func newTestConfigServer(files map[string]string) *testConfigServer {
+	server := &testConfigServer{
+		Requests: make(map[string]int),
+	}
+	server.Server = httptest.NewServer(http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		content, ok := files[request.URL.Path]
+		if !ok {
+			http.NotFound(response, request)
+
+			return
+		}
+
+		server.Requests[request.URL.Path]++
+		response.Write([]byte(content))
+	}))
+
+	return server
+}
+
+// Fetch a file using the specified cache, and return its content.
+func fetchCachedFile(t *testing.T, source string, cache *Cache) (string, error) {
+	destinationDir, err := ioutil.TempDir("", "cache-test-destination")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(destinationDir)
+
+	destination := path.Join(destinationDir, "main.tf")
+	_, err = ContentWithOptions(source, destination, Options{Cache: cache})
+	if err != nil {
+		return "", err
+	}
+
+	content, err := ioutil.ReadFile(destination)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(content), nil
+}
+
+func TestCacheReusesFreshContent(t *testing.T) {
+	server := newTestConfigServer(map[string]string{"/main.tf": "# main"})
+	defer server.Close()
+
+	testDir, err := ioutil.TempDir("", "cache-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(testDir)
+
+	cache := &Cache{
+		Dir: path.Join(testDir, "cache"),
+		TTL: time.Hour,
+	}
+	for attempt := 1; attempt <= 3; attempt++ {
+		content, err := fetchCachedFile(t, server.URL+"/main.tf", cache)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if content != "# main" {
+			t.Errorf("Expected '# main' on attempt %d (got '%s')", attempt, content)
+		}
+	}
+	if server.Requests["/main.tf"] != 1 {
+		t.Errorf("Expected fresh cached content to be reused (got %d requests)", server.Requests["/main.tf"])
+	}
+
+	// Offline, cached content is used regardless of its age.
+	cache.TTL = 0
+	cache.Offline = true
+	content, err := fetchCachedFile(t, server.URL+"/main.tf", cache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content != "# main" || server.Requests["/main.tf"] != 1 {
+		t.Errorf("Expected cached content to be used while offline (got '%s' after %d requests)", content, server.Requests["/main.tf"])
+	}
+}
+
+func TestCacheRefetchesStaleContent(t *testing.T) {
+	files := map[string]string{"/main.tf": "# v1"}
+	server := newTestConfigServer(files)
+	defer server.Close()
+
+	testDir, err := ioutil.TempDir("", "cache-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(testDir)
+
+	cache := &Cache{
+		Dir: path.Join(testDir, "cache"),
+		TTL: time.Hour,
+	}
+	_, err = fetchCachedFile(t, server.URL+"/main.tf", cache)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Make the cached entry stale.
+	entryFile := cache.getSourceEntryFile(&getter.Client{Src: server.URL + "/main.tf", Mode: getter.ClientModeFile}, Options{})
+	entry, err := cache.readEntry(entryFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry == nil {
+		t.Fatalf("Expected a cache entry for '%s'", server.URL+"/main.tf")
+	}
+	entry.FetchedAt = entry.FetchedAt.Add(-2 * time.Hour)
+	err = writeEntry(entryFile, entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files["/main.tf"] = "# v2"
+	content, err := fetchCachedFile(t, server.URL+"/main.tf", cache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content != "# v2" || server.Requests["/main.tf"] != 2 {
+		t.Errorf("Expected stale cached content to be fetched again (got '%s' after %d requests)", content, server.Requests["/main.tf"])
+	}
+
+	// The entry now refers to the new content, and is fresh again.
+	content, err = fetchCachedFile(t, server.URL+"/main.tf", cache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content != "# v2" || server.Requests["/main.tf"] != 2 {
+		t.Errorf("Expected refreshed content to be reused (got '%s' after %d requests)", content, server.Requests["/main.tf"])
+	}
+}
+
+func TestCacheOfflineMiss(t *testing.T) {
+	server := newTestConfigServer(map[string]string{"/main.tf": "# main"})
+	defer server.Close()
+
+	testDir, err := ioutil.TempDir("", "cache-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(testDir)
+
+	cache := &Cache{
+		Dir:     path.Join(testDir, "cache"),
+		TTL:     time.Hour,
+		Offline: true,
+	}
+	_, err = fetchCachedFile(t, server.URL+"/main.tf", cache)
+	if err == nil || !strings.Contains(err.Error(), "has not been cached") {
+		t.Errorf("Expected an error for content that has not been cached (got %v)", err)
+	}
+	if server.Requests["/main.tf"] != 0 {
+		t.Errorf("Expected nothing to be fetched while offline (got %d requests)", server.Requests["/main.tf"])
+	}
+
+	restored, err := cache.RestoreModules(testDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored {
+		t.Errorf("Expected no modules to be restored when none have been cached")
+	}
+}
+
+func TestCacheKeys(t *testing.T) {
+	cache := &Cache{Dir: "/cache"}
+	fileClient := &getter.Client{Src: "https://example.com/main.tf", Mode: getter.ClientModeFile}
+	entryFile := cache.getSourceEntryFile(fileClient, Options{})
+
+	testCases := []struct {
+		description string
+		client      *getter.Client
+		options     Options
+		sameEntry   bool
+	}{
+		{"same source", &getter.Client{Src: "https://example.com/main.tf", Mode: getter.ClientModeFile}, Options{}, true},
+		{"different source", &getter.Client{Src: "https://example.com/other.tf", Mode: getter.ClientModeFile}, Options{}, false},
+		{"different mode", &getter.Client{Src: "https://example.com/main.tf", Mode: getter.ClientModeDir}, Options{}, false},
+		{"checksum", fileClient, Options{Checksum: "sha256:0123"}, false},
+		{"version", fileClient, Options{Version: "~> 1.0"}, false},
+		{"S3 endpoint", fileClient, Options{S3Endpoint: "minio.example.com"}, false},
+		{"trusted keys", fileClient, Options{TrustedKeysFile: "/keys.gpg"}, true},
+	}
+	for _, testCase := range testCases {
+		otherEntryFile := cache.getSourceEntryFile(testCase.client, testCase.options)
+		if (otherEntryFile == entryFile) != testCase.sameEntry {
+			t.Errorf("Expected %s to use the same cache entry: %t", testCase.description, testCase.sameEntry)
+		}
+	}
+
+	// Content is stored by its hash.
+	contentHash := "sha256:" + hashString("# main")
+	expectedContentPath := path.Join("/cache", "content", hashString("# main"), cachedContentName)
+	if cache.getContentPath(contentHash) != expectedContentPath {
+		t.Errorf("Expected content path '%s' (got '%s')", expectedContentPath, cache.getContentPath(contentHash))
+	}
+}
+
+func TestCacheStoresContentOnce(t *testing.T) {
+	server := newTestConfigServer(map[string]string{
+		"/main.tf": "# main",
+		"/copy.tf": "# main",
+	})
+	defer server.Close()
+
+	testDir, err := ioutil.TempDir("", "cache-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(testDir)
+
+	cache := &Cache{
+		Dir: path.Join(testDir, "cache"),
+		TTL: time.Hour,
+	}
+	for _, source := range []string{server.URL + "/main.tf", server.URL + "/copy.tf"} {
+		_, err = fetchCachedFile(t, source, cache)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sourceEntries, err := ioutil.ReadDir(path.Join(cache.Dir, "sources"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	contentEntries, err := ioutil.ReadDir(path.Join(cache.Dir, "content"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sourceEntries) != 2 || len(contentEntries) != 1 {
+		t.Errorf("Expected 2 sources with the same content to share 1 cached copy (got %d sources and %d copies)", len(sourceEntries), len(contentEntries))
+	}
+	expectedContentDir := hashString("# main")
+	if len(contentEntries) == 1 && contentEntries[0].Name() != expectedContentDir {
+		t.Errorf("Expected content to be stored under its hash '%s' (got '%s')", expectedContentDir, contentEntries[0].Name())
+	}
+}
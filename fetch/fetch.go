@@ -1,27 +1,118 @@
 package fetch
 
 import (
+	"fmt"
+	"net/url"
 	"os"
 	"strings"
 
 	"github.com/hashicorp/go-getter"
 )
 
-// For now, we only support a subset of the sources that go-getter supports
-// (weird problems with GitHub, for example).
-var supportedGetters = map[string]getter.Getter{
-	"file":  getter.Getters["file"],
-	"git":   getter.Getters["git"],
-	"http":  getter.Getters["http"],
-	"https": getter.Getters["https"],
+// Options controls how content is fetched.
+type Options struct {
+	// The endpoint to use for s3:: sources (e.g. an S3-compatible object store such as Minio).
+	//
+	// If not specified, the endpoint is determined from the source URL.
+	S3Endpoint string
+
+	// The expected checksum of the fetched content (e.g. "sha256:0123...").
+	//
+	// A checksum can also be specified using go-getter's "?checksum=" syntax.
+	// For files (and archives), this is the checksum of the downloaded file; for directories, see Checksum.
+	Checksum string
+
+	// The location (local path or URL) of a detached signature for the fetched content.
+	//
+	// See verifySignature for details.
+	Signature string
+
+	// The type of signature (SignatureTypeMinisign or SignatureTypeGPG).
+	//
+	// If not specified, the type is determined from the signature's file extension.
+	SignatureType string
+
+	// A file containing the public keys that are trusted to sign fetched content.
+	TrustedKeysFile string
+
+	// The version constraint (e.g. "~> 2.1") used to select the version of registry:: modules.
+	//
+	// A version constraint can also be specified using the "?version=" query parameter.
+	Version string
+
+	// The path of the SSH private key file to use for git sources that are cloned via SSH (e.g. a deploy key for a private repository).
+	GitSSHKeyFile string
+
+	// An optional cache for fetched content.
+	Cache *Cache
+
+	// The scp-style address of the git repository being fetched, if any (see splitGitSCPSource).
+	gitSCPAddress string
+}
+
+// Result describes the content that was fetched.
+type Result struct {
+	// The commit that was checked out (for git sources).
+	Commit string `json:"commit,omitempty"`
+
+	// The module version that was selected (for module registry sources).
+	Version string `json:"version,omitempty"`
 }
 
 // For now, we only support a subset of the sources that go-getter supports
 // (weird problems with GitHub, for example).
+//
+// Getters that can describe the content that they fetch record it in result.
+func supportedGetters(options Options, result *Result) map[string]getter.Getter {
+	getters := remoteGetters(options, result)
+	getters["file"] = getter.Getters["file"]
+	getters["registry"] = &registryGetter{
+		Version: options.Version,
+		Options: options,
+		Result:  result,
+	}
+
+	return getters
+}
+
+// The getters that fetch content from remote locations.
+//
+// These are the only getters that can be used to download registry modules (a registry must not be able to make us read local files, or call another registry).
+func remoteGetters(options Options, result *Result) map[string]getter.Getter {
+	s3 := &s3Getter{
+		Endpoint: options.S3Endpoint,
+	}
+	gcs := &s3Getter{
+		GCS: true,
+	}
+
+	git := &gitGetter{
+		SSHKeyFile: options.GitSSHKeyFile,
+		Result:     result,
+		SCPAddress: options.gitSCPAddress,
+	}
+
+	return map[string]getter.Getter{
+		"git":   git,
+		"http":  getter.Getters["http"],
+		"https": getter.Getters["https"],
+		"s3":    s3,
+		"gcs":   gcs,
+		"gs":    gcs,
+	}
+}
+
+// For now, we only support a subset of the sources that go-getter supports.
+//
+// The file detector accepts any source, so it must come last.
+// scp-style git sources are detected separately (see ParseSource).
 func supportedDetectors() []getter.Detector {
 	return []getter.Detector{
-		new(getter.FileDetector),
 		new(getter.GitHubDetector),
+		new(gitLabDetector),
+		new(bitbucketDetector),
+		new(getter.S3Detector),
+		new(getter.FileDetector),
 	}
 }
 
@@ -32,20 +123,63 @@ func ParseSource(source string) (string, error) {
 		return "", err
 	}
 
+	// Terraform module registry addresses look like relative paths, so they must be detected first.
+	registrySource, isRegistrySource := detectRegistrySource(source, workingDirectory)
+	if isRegistrySource {
+		return registrySource, nil
+	}
+
+	// go-getter can only handle subdirectories in sources that it can parse as URLs, so scp-style git sources must also be detected first.
+	scpSource, isSCPSource, err := (&gitSCPDetector{}).Detect(strings.TrimPrefix(source, "git::"), workingDirectory)
+	if isSCPSource || err != nil {
+		return scpSource, err
+	}
+
 	isSourceDirectory := strings.HasSuffix(source, "/")
-	parsedSource, err := getter.Detect(source, workingDirectory, supportedDetectors())
+	parsedSource, err := getter.Detect(strings.TrimSuffix(source, "/"), workingDirectory, supportedDetectors())
 	if err != nil {
 		return "", err
 	}
 
-	// Retain trailing slash if required.
-	if isSourceDirectory && !strings.HasSuffix(parsedSource, "/") {
+	// Retain trailing slash if required (git and registry sources are always directories).
+	if isSourceDirectory && !IsDirectorySource(parsedSource) {
 		parsedSource += "/"
 	}
 
 	return parsedSource, nil
 }
 
+// IsDirectorySource determines whether the specified (parsed) source represents a directory, rather than a file.
+//
+// Sources ending with "/" represent directories, as do git and module registry sources.
+func IsDirectorySource(source string) bool {
+	return strings.HasSuffix(source, "/") || strings.HasPrefix(source, "git::") || strings.HasPrefix(source, "registry::")
+}
+
+// IsLocalSource determines whether the specified (parsed) source is a local file or directory.
+//
+// Local sources are never cached, since they can change at any time (and copying them is cheap anyway).
+func IsLocalSource(source string) bool {
+	return getSourceGetter(source) == "file"
+}
+
+// Get the name of the getter that will be used to fetch the specified (parsed) source (e.g. "git" for "git::https://example.com/repo.git").
+//
+// Returns an empty string if the source is not a valid URL.
+func getSourceGetter(source string) string {
+	separatorIndex := strings.Index(source, "::")
+	if separatorIndex != -1 {
+		return source[:separatorIndex]
+	}
+
+	sourceURL, err := url.Parse(source)
+	if err != nil {
+		return ""
+	}
+
+	return sourceURL.Scheme
+}
+
 // Content downloads a URL into the given destination.
 //
 // destination must be a directory.
@@ -53,18 +187,82 @@ func ParseSource(source string) (string, error) {
 // with the basename of the URL.
 // If source is a directory or archive, it will be unpacked directly into destination.
 func Content(source string, destination string) error {
+	_, err := ContentWithOptions(source, destination, Options{})
+
+	return err
+}
+
+// ContentWithOptions downloads a URL into the given destination, using the specified options.
+//
+// See Content for details.
+//
+// If a checksum or signature is specified (either in options or the source URL), the content is verified once it has been fetched;
+// if verification fails, the fetched content is removed.
+//
+// If a cache is specified, cached content is used where possible (see Cache); local sources are never cached (see IsLocalSource).
+//
+// Returns a description of the fetched content.
+func ContentWithOptions(source string, destination string, options Options) (*Result, error) {
+	source, checksum, err := extractChecksum(source)
+	if err != nil {
+		return nil, err
+	}
+	if checksum != "" {
+		if options.Checksum != "" && !strings.EqualFold(checksum, options.Checksum) {
+			return nil, fmt.Errorf("The checksum in the source URL ('%s') does not match the checksum that was specified ('%s')", checksum, options.Checksum)
+		}
+		options.Checksum = checksum
+	}
+
+	source, options.gitSCPAddress = splitGitSCPSource(source)
+
 	// Manual detection of source type (file / directory) since getter.ClientModeAny just assumes its a file.
-	isDirectory := strings.HasSuffix(source, "/")
+	isDirectory := IsDirectorySource(source)
 	clientMode := getter.ClientModeFile
 	if isDirectory {
 		clientMode = getter.ClientModeDir
 		source = strings.TrimSuffix(source, "/") // Trim off the suffix since we only needed it for choosing the client mode
+	} else if options.Checksum != "" {
+		// go-getter verifies files (and archives, before they are unpacked) itself.
+		source, err = addChecksum(source, options.Checksum)
+		if err != nil {
+			return nil, err
+		}
+		options.Checksum = ""
 	}
 
-	return (&getter.Client{
+	result := &Result{}
+	client := &getter.Client{
 		Src:     source,
 		Dst:     destination,
 		Mode:    clientMode,
-		Getters: supportedGetters,
-	}).Get()
+		Getters: supportedGetters(options, result),
+	}
+	if options.Cache != nil && !IsLocalSource(source) {
+		err = options.Cache.fetch(client, options, result)
+	} else {
+		err = fetchAndVerify(client, options)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// Fetch content, and then verify it.
+func fetchAndVerify(client *getter.Client, options Options) error {
+	err := client.Get()
+	if err != nil {
+		return err
+	}
+
+	err = verifyContent(client.Dst, options)
+	if err != nil {
+		os.RemoveAll(client.Dst) // Don't leave unverified content lying around
+
+		return err
+	}
+
+	return nil
 }
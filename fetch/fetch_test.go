@@ -0,0 +1,71 @@
+package fetch
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+)
+
+func TestIsLocalSource(t *testing.T) {
+	testCases := map[string]bool{
+		"file:///configs/machine/":                     true,
+		"file::/configs/machine":                       true,
+		"file::https://example.com/configs/main.tf":    true,
+		"https://example.com/configs/main.tf":          false,
+		"git::https://github.com/example/configs.git":  false,
+		"s3::https://s3.amazonaws.com/bucket/main.tf":  false,
+		"registry::https://registry.example.com/a/b/c": false,
+	}
+	for source, expectedIsLocal := range testCases {
+		if IsLocalSource(source) != expectedIsLocal {
+			t.Errorf("Expected IsLocalSource('%s') to be %t", source, expectedIsLocal)
+		}
+	}
+}
+
+func TestLocalSourcesAreNotCached(t *testing.T) {
+	testDir, err := ioutil.TempDir("", "fetch-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(testDir)
+
+	sourceDir := path.Join(testDir, "source")
+	err = os.MkdirAll(sourceDir, 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache := &Cache{
+		Dir: path.Join(testDir, "cache"),
+		TTL: time.Hour,
+	}
+
+	for _, expectedContent := range []string{"# v1", "# v2"} {
+		err = ioutil.WriteFile(path.Join(sourceDir, "main.tf"), []byte(expectedContent), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		destination := path.Join(testDir, "destination")
+		os.RemoveAll(destination)
+		_, err = ContentWithOptions("file://"+sourceDir+"/", destination, Options{Cache: cache})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		content, err := ioutil.ReadFile(path.Join(destination, "main.tf"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(content) != expectedContent {
+			t.Errorf("Expected '%s' but found '%s'", expectedContent, string(content))
+		}
+	}
+
+	_, err = os.Stat(cache.Dir)
+	if !os.IsNotExist(err) {
+		t.Errorf("Nothing should have been written to the cache for a local source")
+	}
+}
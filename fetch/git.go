@@ -0,0 +1,348 @@
+package fetch
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// A full git commit SHA.
+var gitCommitPattern = regexp.MustCompile(`^[0-9a-fA-F]{40}$`)
+
+// A ref that could be an abbreviated git commit SHA (or a branch or tag whose name looks like one).
+var gitAbbreviatedCommitPattern = regexp.MustCompile(`^[0-9a-fA-F]{7,39}$`)
+
+// An scp-style git address (e.g. "git@example.com:owner/repo.git").
+var gitSCPPattern = regexp.MustCompile(`^([A-Za-z0-9_.-]+)@([A-Za-z0-9.-]+):(.+)$`)
+
+// gitGetter is a go-getter Getter that clones git repositories.
+//
+// Unlike go-getter's own GitGetter, it supports shallow clones and SSH keys, and records the commit that was checked out.
+//
+// The following query parameters are supported:
+//
+// ref: the branch, tag, or commit to check out (defaults to the repository's default branch)
+// depth: the number of commits to fetch (defaults to the entire history; ignored if ref is an abbreviated commit SHA, since that can only be resolved from a full clone)
+// sshkey: a base64-encoded SSH private key to use (for compatibility with go-getter; prefer the getter's SSHKeyFile)
+type gitGetter struct {
+	// The path of the SSH private key file to use when cloning via SSH.
+	SSHKeyFile string
+
+	// If not nil, receives the commit that was checked out.
+	Result *Result
+
+	// The scp-style address of the repository (e.g. "git@example.com:owner/repo.git"), if any.
+	//
+	// If specified, this is passed to git instead of the URL (see splitGitSCPSource).
+	SCPAddress string
+}
+
+// Get clones the repository into the specified directory, and checks out the requested ref.
+func (git *gitGetter) Get(destination string, sourceURL *url.URL) error {
+	executablePath, err := exec.LookPath("git")
+	if err != nil {
+		return fmt.Errorf("Unable to fetch '%s' (cannot find the git executable): %s", sourceURL.String(), err.Error())
+	}
+
+	repositoryURL := *sourceURL
+	query := repositoryURL.Query()
+	ref := query.Get("ref")
+	depth := 0
+	if query.Get("depth") != "" {
+		depth, err = strconv.Atoi(query.Get("depth"))
+		if err != nil || depth < 0 {
+			return fmt.Errorf("Invalid depth '%s' for git source '%s' (must be a positive number)", query.Get("depth"), sourceURL.String())
+		}
+	}
+	encodedSSHKey := query.Get("sshkey")
+	query.Del("ref")
+	query.Del("depth")
+	query.Del("sshkey")
+	repositoryURL.RawQuery = query.Encode()
+
+	repositoryAddress := repositoryURL.String()
+	if git.SCPAddress != "" {
+		repositoryAddress = git.SCPAddress
+	}
+
+	environment, cleanup, err := git.getEnvironment(encodedSSHKey)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	// Start from scratch.
+	err = os.RemoveAll(destination)
+	if err != nil {
+		return err
+	}
+
+	run := func(workingDirectory string, arguments ...string) (string, error) {
+		return runGit(executablePath, workingDirectory, environment, arguments...)
+	}
+
+	if depth > 0 && gitAbbreviatedCommitPattern.MatchString(ref) {
+		depth = 0 // Fall back to a full clone, and then check out the ref.
+	}
+
+	if depth > 0 && gitCommitPattern.MatchString(ref) {
+		// A shallow clone can't start from a commit, so fetch it directly (this requires the server to permit fetching commits by SHA).
+		err = os.MkdirAll(destination, 0755 /* u=rwx,g=rx,o=rx */)
+		if err != nil {
+			return err
+		}
+		_, err = run(destination, "init", "--quiet")
+		if err == nil {
+			_, err = run(destination, "fetch", "--quiet", "--depth", strconv.Itoa(depth), repositoryAddress, ref)
+		}
+		if err == nil {
+			_, err = run(destination, "checkout", "--quiet", "FETCH_HEAD")
+		}
+	} else {
+		cloneArguments := []string{"clone", "--quiet"}
+		if depth > 0 {
+			cloneArguments = append(cloneArguments, "--depth", strconv.Itoa(depth))
+			if ref != "" {
+				cloneArguments = append(cloneArguments, "--branch", ref)
+			}
+		}
+		cloneArguments = append(cloneArguments, repositoryAddress, destination)
+
+		_, err = run("", cloneArguments...)
+		if err == nil && ref != "" && depth == 0 {
+			_, err = run(destination, "checkout", "--quiet", ref)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	commit, err := run(destination, "rev-parse", "HEAD")
+	if err != nil {
+		return err
+	}
+	if git.Result != nil {
+		git.Result.Commit = commit
+	}
+
+	return nil
+}
+
+// GetFile is not supported (select a directory within the repository using "//path" instead).
+func (git *gitGetter) GetFile(destination string, sourceURL *url.URL) error {
+	return fmt.Errorf("Cannot fetch git source '%s' as a file (use '//path' to select a directory within the repository)", sourceURL.String())
+}
+
+// Get the environment for git commands (configuring SSH to use the required key, if any).
+//
+// The returned function cleans up any temporary files.
+func (git *gitGetter) getEnvironment(encodedSSHKey string) ([]string, func(), error) {
+	cleanup := func() {}
+
+	sshKeyFile := git.SSHKeyFile
+	if encodedSSHKey != "" {
+		sshKey, err := base64.URLEncoding.DecodeString(encodedSSHKey)
+		if err != nil {
+			sshKey, err = base64.StdEncoding.DecodeString(encodedSSHKey)
+		}
+		if err != nil {
+			return nil, cleanup, fmt.Errorf("Invalid sshkey for git source (must be base64-encoded): %s", err.Error())
+		}
+
+		tempFile, err := ioutil.TempFile("", "docker-machine-driver-terraform-ssh")
+		if err != nil {
+			return nil, cleanup, err
+		}
+		cleanup = func() {
+			os.Remove(tempFile.Name())
+		}
+
+		// ioutil.TempFile creates files that are only accessible to the current user (as ssh requires).
+		_, err = tempFile.Write(sshKey)
+		tempFile.Close()
+		if err != nil {
+			cleanup()
+
+			return nil, func() {}, err
+		}
+
+		sshKeyFile = tempFile.Name()
+	}
+
+	environment := os.Environ()
+	if sshKeyFile != "" {
+		environment = append(environment,
+			fmt.Sprintf("GIT_SSH_COMMAND=ssh -i '%s' -o IdentitiesOnly=yes", strings.Replace(sshKeyFile, "'", `'\''`, -1)),
+		)
+	}
+
+	// Never prompt for credentials.
+	environment = append(environment, "GIT_TERMINAL_PROMPT=0")
+
+	return environment, cleanup, nil
+}
+
+// Run a git command, returning its (trimmed) output.
+func runGit(executablePath string, workingDirectory string, environment []string, arguments ...string) (string, error) {
+	command := exec.Command(executablePath, arguments...)
+	command.Dir = workingDirectory
+	command.Env = environment
+
+	output, err := command.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("Failed to execute 'git %s' (%s):\n%s", arguments[0], err.Error(), strings.TrimSpace(string(output)))
+	}
+
+	return strings.TrimSpace(string(output)), nil
+}
+
+// gitLabDetector detects GitLab sources (e.g. "gitlab.com/group/subgroup/repo").
+//
+// Since GitLab supports nested groups, the entire path is the repository; use "//path" to select a directory within it.
+type gitLabDetector struct{}
+
+// Detect converts a GitLab source into a git:: URL.
+func (detector *gitLabDetector) Detect(source string, _ string) (string, bool, error) {
+	if !strings.HasPrefix(source, "gitlab.com/") {
+		return "", false, nil
+	}
+
+	sourceURL, err := url.Parse("https://" + source)
+	if err != nil {
+		return "", true, fmt.Errorf("Invalid GitLab source '%s': %s", source, err.Error())
+	}
+	if strings.Count(strings.Trim(sourceURL.Path, "/"), "/") < 1 {
+		return "", true, fmt.Errorf("Invalid GitLab source '%s' (expected 'gitlab.com/group/repo')", source)
+	}
+	if !strings.HasSuffix(sourceURL.Path, ".git") {
+		sourceURL.Path += ".git"
+	}
+
+	return "git::" + sourceURL.String(), true, nil
+}
+
+// bitbucketDetector detects Bitbucket sources (e.g. "bitbucket.org/owner/repo/path").
+//
+// go-getter's BitBucketDetector looks up each repository's type using version 1.0 of the Bitbucket API (which cannot see private repositories),
+// and does not support selecting a directory within the repository. This detector does not call the API, and always produces a git source
+// (Mercurial sources are not supported).
+type bitbucketDetector struct{}
+
+// Detect converts a Bitbucket source into a git:: URL.
+func (detector *bitbucketDetector) Detect(source string, _ string) (string, bool, error) {
+	if !strings.HasPrefix(source, "bitbucket.org/") {
+		return "", false, nil
+	}
+
+	sourceURL, err := url.Parse("https://" + source)
+	if err != nil {
+		return "", true, fmt.Errorf("Invalid Bitbucket source '%s': %s", source, err.Error())
+	}
+
+	pathSegments := strings.Split(strings.Trim(sourceURL.Path, "/"), "/")
+	if len(pathSegments) < 2 {
+		return "", true, fmt.Errorf("Invalid Bitbucket source '%s' (expected 'bitbucket.org/owner/repo')", source)
+	}
+	sourceURL.Path = "/" + strings.Join(pathSegments[:2], "/")
+	if !strings.HasSuffix(sourceURL.Path, ".git") {
+		sourceURL.Path += ".git"
+	}
+
+	// As for GitHub, anything after the repository is a directory within it.
+	if len(pathSegments) > 2 {
+		sourceURL.Path += "//" + strings.Join(pathSegments[2:], "/")
+	}
+
+	return "git::" + sourceURL.String(), true, nil
+}
+
+// gitSCPDetector detects scp-style git sources (e.g. "git@example.com:owner/repo.git").
+//
+// The scp-style address is retained (e.g. "git::git@example.com:owner/repo.git"), since its path is relative to the remote user's home directory
+// (whereas the path in an equivalent ssh:// URL would be absolute).
+type gitSCPDetector struct{}
+
+// Detect converts an scp-style git source into a git:: source.
+func (detector *gitSCPDetector) Detect(source string, _ string) (string, bool, error) {
+	if strings.Contains(source, "://") {
+		return "", false, nil
+	}
+
+	if !gitSCPPattern.MatchString(source) {
+		return "", false, nil
+	}
+
+	return "git::" + source, true, nil
+}
+
+// Split an scp-style git source (e.g. "git::git@example.com:owner/repo.git//path?ref=v1") into a source that go-getter can parse
+// (e.g. "git::ssh://git@example.com/owner/repo.git//path?ref=v1") and the scp-style address of the repository to pass to git (e.g. "git@example.com:owner/repo.git").
+//
+// go-getter cannot parse scp-style addresses, and the repository cannot simply be cloned from the equivalent ssh:// URL
+// (whose path is absolute, rather than relative to the remote user's home directory).
+// Other sources are returned unchanged (with an empty address).
+func splitGitSCPSource(source string) (parseableSource string, repositoryAddress string) {
+	if !strings.HasPrefix(source, "git::") || strings.Contains(source, "://") {
+		return source, ""
+	}
+
+	match := gitSCPPattern.FindStringSubmatch(strings.TrimPrefix(source, "git::"))
+	if match == nil {
+		return source, ""
+	}
+
+	repositoryPath := match[3]
+	rawQuery := ""
+	queryIndex := strings.Index(repositoryPath, "?")
+	if queryIndex != -1 {
+		rawQuery = repositoryPath[queryIndex+1:]
+		repositoryPath = repositoryPath[:queryIndex]
+	}
+	subdirectory := ""
+	subdirectoryIndex := strings.Index(repositoryPath, "//")
+	if subdirectoryIndex != -1 {
+		subdirectory = repositoryPath[subdirectoryIndex:]
+		repositoryPath = repositoryPath[:subdirectoryIndex]
+	}
+
+	sourceURL := &url.URL{
+		Scheme:   "ssh",
+		User:     url.User(match[1]),
+		Host:     match[2],
+		Path:     "/" + strings.TrimPrefix(repositoryPath, "/") + subdirectory,
+		RawQuery: rawQuery,
+	}
+
+	return "git::" + sourceURL.String(), fmt.Sprintf("%s@%s:%s", match[1], match[2], repositoryPath)
+}
+
+// Remove any SSH private key (the "sshkey" query parameter) from a source, so that it can be safely recorded.
+func withoutSSHKey(source string) string {
+	if !strings.Contains(source, "sshkey=") {
+		return source
+	}
+
+	forcedGetter := ""
+	separatorIndex := strings.Index(source, "::")
+	if separatorIndex != -1 {
+		forcedGetter = source[:separatorIndex+2]
+		source = source[separatorIndex+2:]
+	}
+
+	sourceURL, err := url.Parse(source)
+	if err != nil {
+		return forcedGetter + "<invalid source>"
+	}
+
+	query := sourceURL.Query()
+	query.Del("sshkey")
+	sourceURL.RawQuery = query.Encode()
+
+	return forcedGetter + sourceURL.String()
+}
@@ -0,0 +1,247 @@
+package fetch
+
+import (
+	"io/ioutil"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestGitLabDetector(t *testing.T) {
+	testCases := map[string]string{
+		"gitlab.com/group/repo":                       "git::https://gitlab.com/group/repo.git",
+		"gitlab.com/group/subgroup/repo":              "git::https://gitlab.com/group/subgroup/repo.git",
+		"gitlab.com/group/subgroup/repo.git?ref=v1":   "git::https://gitlab.com/group/subgroup/repo.git?ref=v1",
+		"gitlab.com/group/subgroup/team/repo?depth=1": "git::https://gitlab.com/group/subgroup/team/repo.git?depth=1",
+	}
+	for source, expectedSource := range testCases {
+		detectedSource, ok, err := (&gitLabDetector{}).Detect(source, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ok || detectedSource != expectedSource {
+			t.Errorf("Expected '%s' to be detected as '%s' (got '%s')", source, expectedSource, detectedSource)
+		}
+	}
+
+	_, ok, err := (&gitLabDetector{}).Detect("gitlab.com/repo", "")
+	if !ok || err == nil {
+		t.Fatal("Expected an error for a GitLab source without a group")
+	}
+	_, ok, _ = (&gitLabDetector{}).Detect("github.com/owner/repo", "")
+	if ok {
+		t.Fatal("GitHub source should not be detected as a GitLab source")
+	}
+}
+
+func TestBitbucketDetector(t *testing.T) {
+	testCases := map[string]string{
+		"bitbucket.org/owner/repo":                 "git::https://bitbucket.org/owner/repo.git",
+		"bitbucket.org/owner/repo.git":             "git::https://bitbucket.org/owner/repo.git",
+		"bitbucket.org/owner/repo/modules/machine": "git::https://bitbucket.org/owner/repo.git//modules/machine",
+		"bitbucket.org/owner/repo/machine?ref=v2":  "git::https://bitbucket.org/owner/repo.git//machine?ref=v2",
+	}
+	for source, expectedSource := range testCases {
+		detectedSource, ok, err := (&bitbucketDetector{}).Detect(source, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ok || detectedSource != expectedSource {
+			t.Errorf("Expected '%s' to be detected as '%s' (got '%s')", source, expectedSource, detectedSource)
+		}
+	}
+
+	_, ok, err := (&bitbucketDetector{}).Detect("bitbucket.org/owner", "")
+	if !ok || err == nil {
+		t.Fatal("Expected an error for a Bitbucket source without a repository")
+	}
+}
+
+func TestGitSCPDetector(t *testing.T) {
+	testCases := map[string]string{
+		"git@example.com:owner/repo.git":                    "git::git@example.com:owner/repo.git",
+		"git@example.com:owner/repo.git?ref=v1":             "git::git@example.com:owner/repo.git?ref=v1",
+		"git@example.com:group/sub/repo.git?ref=v1&depth=1": "git::git@example.com:group/sub/repo.git?ref=v1&depth=1",
+	}
+	for source, expectedSource := range testCases {
+		detectedSource, ok, err := (&gitSCPDetector{}).Detect(source, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ok || detectedSource != expectedSource {
+			t.Errorf("Expected '%s' to be detected as '%s' (got '%s')", source, expectedSource, detectedSource)
+		}
+	}
+
+	for _, source := range []string{"https://git@example.com/owner/repo.git", "configs/machine", "C:/configs"} {
+		_, ok, _ := (&gitSCPDetector{}).Detect(source, "")
+		if ok {
+			t.Errorf("'%s' should not be detected as an scp-style git source", source)
+		}
+	}
+}
+
+func TestParseGitSourceWithSubdirectoryAndRef(t *testing.T) {
+	source, err := ParseSource("git@example.com:owner/repo.git//machine?ref=v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if source != "git::git@example.com:owner/repo.git//machine?ref=v1" {
+		t.Fatalf("Unexpected source '%s'", source)
+	}
+	if !IsDirectorySource(source) {
+		t.Fatal("Git sources should always be directory sources")
+	}
+}
+
+func TestSplitGitSCPSource(t *testing.T) {
+	testCases := map[string][2]string{
+		"git::git@example.com:owner/repo.git":                 {"git::ssh://git@example.com/owner/repo.git", "git@example.com:owner/repo.git"},
+		"git::git@example.com:owner/repo.git//machine?ref=v1": {"git::ssh://git@example.com/owner/repo.git//machine?ref=v1", "git@example.com:owner/repo.git"},
+		"git::git@example.com:/srv/git/repo.git?depth=1":      {"git::ssh://git@example.com/srv/git/repo.git?depth=1", "git@example.com:/srv/git/repo.git"},
+		"git::ssh://git@example.com/owner/repo.git":           {"git::ssh://git@example.com/owner/repo.git", ""},
+		"git::https://example.com/owner/repo.git//machine":    {"git::https://example.com/owner/repo.git//machine", ""},
+		"https://example.com/configs/main.tf":                 {"https://example.com/configs/main.tf", ""},
+	}
+	for source, expected := range testCases {
+		parseableSource, repositoryAddress := splitGitSCPSource(source)
+		if parseableSource != expected[0] || repositoryAddress != expected[1] {
+			t.Errorf("Expected '%s' to be split into '%s' and '%s' (got '%s' and '%s')", source, expected[0], expected[1], parseableSource, repositoryAddress)
+		}
+	}
+}
+
+func TestWithoutSSHKey(t *testing.T) {
+	testCases := map[string]string{
+		"git::ssh://git@example.com/owner/repo.git?ref=v1&sshkey=c2VjcmV0": "git::ssh://git@example.com/owner/repo.git?ref=v1",
+		"git::ssh://git@example.com/owner/repo.git?sshkey=c2VjcmV0":        "git::ssh://git@example.com/owner/repo.git",
+		"git::ssh://git@example.com/owner/repo.git?ref=v1":                 "git::ssh://git@example.com/owner/repo.git?ref=v1",
+	}
+	for source, expectedSource := range testCases {
+		if actualSource := withoutSSHKey(source); actualSource != expectedSource {
+			t.Errorf("Expected '%s' to become '%s' (got '%s')", source, expectedSource, actualSource)
+		}
+	}
+}
+
+// Create a local git repository with 2 commits (the first tagged "v1"), and return its path and the SHA of each commit.
+func newTestGitRepository(t *testing.T) (string, []string) {
+	repository, err := ioutil.TempDir("", "git-repository")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	git := func(arguments ...string) string {
+		command := exec.Command("git", arguments...)
+		command.Dir = repository
+		command.Env = append(os.Environ(),
+			"GIT_AUTHOR_NAME=Test", "GIT_AUTHOR_EMAIL=test@example.com",
+			"GIT_COMMITTER_NAME=Test", "GIT_COMMITTER_EMAIL=test@example.com",
+		)
+		output, err := command.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %s failed: %s\n%s", strings.Join(arguments, " "), err.Error(), output)
+		}
+
+		return strings.TrimSpace(string(output))
+	}
+
+	var commits []string
+	git("init", "--quiet")
+	git("config", "uploadpack.allowAnySHA1InWant", "true")
+	for index, content := range []string{"# v1", "# v2"} {
+		err = os.MkdirAll(path.Join(repository, "machine"), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = ioutil.WriteFile(path.Join(repository, "machine", "main.tf"), []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		git("add", ".")
+		git("commit", "--quiet", "-m", content)
+		if index == 0 {
+			git("tag", "v1")
+		}
+		commits = append(commits, git("rev-parse", "HEAD"))
+	}
+
+	return repository, commits
+}
+
+func TestGitGetterRefs(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not available")
+	}
+
+	repository, commits := newTestGitRepository(t)
+	defer os.RemoveAll(repository)
+
+	destination, err := ioutil.TempDir("", "git-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(destination)
+
+	testCases := []struct {
+		Query           string
+		ExpectedContent string
+		ExpectedCommit  string
+	}{
+		{"", "# v2", commits[1]},
+		{"?ref=v1", "# v1", commits[0]},
+		{"?ref=v1&depth=1", "# v1", commits[0]},
+		{"?ref=" + commits[0] + "&depth=1", "# v1", commits[0]},     // Fetched by commit
+		{"?ref=" + commits[0][:7] + "&depth=1", "# v1", commits[0]}, // Abbreviated commit (full clone)
+		{"?ref=" + commits[0][:12], "# v1", commits[0]},
+	}
+	for index, testCase := range testCases {
+		source := "git::file://" + repository + "//machine" + testCase.Query
+		testDestination := path.Join(destination, string(rune('a'+index)))
+
+		result, err := ContentWithOptions(source, testDestination, Options{})
+		if err != nil {
+			t.Fatalf("Unable to fetch '%s': %s", source, err.Error())
+		}
+
+		content, err := ioutil.ReadFile(path.Join(testDestination, "main.tf"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(content) != testCase.ExpectedContent {
+			t.Errorf("Expected content '%s' for '%s' (got '%s')", testCase.ExpectedContent, source, content)
+		}
+		if result.Commit != testCase.ExpectedCommit {
+			t.Errorf("Expected commit '%s' for '%s' (got '%s')", testCase.ExpectedCommit, source, result.Commit)
+		}
+	}
+}
+
+func TestGitGetterUsesSCPAddress(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not available")
+	}
+
+	repository, commits := newTestGitRepository(t)
+	defer os.RemoveAll(repository)
+
+	destination, err := ioutil.TempDir("", "git-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(destination)
+
+	// The URL refers to a non-existent host, so the clone only succeeds if the scp-style address (here, a local path) is passed to git instead.
+	sourceURL, _ := url.Parse("ssh://git@example.invalid/owner/repo.git?ref=v1")
+	result := &Result{}
+	err = (&gitGetter{SCPAddress: repository, Result: result}).Get(path.Join(destination, "clone"), sourceURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Commit != commits[0] {
+		t.Errorf("Expected commit '%s' (got '%s')", commits[0], result.Commit)
+	}
+}
@@ -0,0 +1,429 @@
+package fetch
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-getter"
+	"github.com/hashicorp/go-version"
+)
+
+const (
+	// The path of the registry's service discovery document.
+	registryDiscoveryPath = "/.well-known/terraform.json"
+
+	// The registry service that provides modules.
+	registryModulesService = "modules.v1"
+
+	// The prefix for environment variables containing registry API tokens (e.g. TF_TOKEN_registry_example_com).
+	registryTokenEnvironmentPrefix = "TF_TOKEN_"
+)
+
+var (
+	// Valid registry module namespaces and names.
+	registryNamePattern = regexp.MustCompile(`^[0-9A-Za-z](?:[0-9A-Za-z_-]{0,62}[0-9A-Za-z])?$`)
+
+	// Valid registry module provider names.
+	registryProviderPattern = regexp.MustCompile(`^[0-9a-z]{1,64}$`)
+
+	// Hosts that look like registry hosts, but whose sources are handled by other detectors.
+	nonRegistryHosts = map[string]bool{
+		"github.com":    true,
+		"bitbucket.org": true,
+		"gitlab.com":    true,
+	}
+
+	// The client used to call registry APIs.
+	registryHTTPClient = &http.Client{
+		Timeout: 30 * time.Second,
+	}
+)
+
+// A module in a Terraform module registry.
+type registryModule struct {
+	// The base URL of the registry (e.g. "https://registry.example.com").
+	BaseURL *url.URL
+
+	Namespace string
+	Name      string
+	Provider  string
+}
+
+// registryGetter is a go-getter Getter that downloads modules from a Terraform module registry.
+//
+// Supported URL forms:
+//
+// registry::https://registry.example.com/namespace/name/provider
+// registry::http://localhost:8080/namespace/name/provider (plain HTTP is only used when explicitly specified)
+//
+// Module addresses in Terraform's format (e.g. "registry.example.com/namespace/name/provider", or "namespace/name/provider" for the public registry)
+// are converted to the first form by ParseSource.
+//
+// The module version is selected using the version constraint in the "version" query parameter (or the constraint in the getter's options).
+type registryGetter struct {
+	// The version constraint (e.g. "~> 2.1") to use if the URL does not specify one.
+	Version string
+
+	// The options used to fetch the module once its download location has been resolved.
+	Options Options
+
+	// If not nil, receives the module version that was selected.
+	Result *Result
+}
+
+// Get downloads the module into the specified directory.
+func (registry *registryGetter) Get(destination string, sourceURL *url.URL) error {
+	module, err := parseRegistryURL(sourceURL)
+	if err != nil {
+		return err
+	}
+
+	versionConstraint := sourceURL.Query().Get("version")
+	if versionConstraint == "" {
+		versionConstraint = registry.Version
+	}
+
+	moduleSource, moduleVersion, err := module.Resolve(versionConstraint)
+	if err != nil {
+		return err
+	}
+	if registry.Result != nil {
+		registry.Result.Version = moduleVersion
+	}
+
+	moduleOptions := registry.Options
+	moduleSource, moduleOptions.gitSCPAddress = splitGitSCPSource(moduleSource)
+
+	getters := remoteGetters(moduleOptions, registry.Result)
+	if _, ok := getters[getSourceGetter(moduleSource)]; !ok {
+		return fmt.Errorf("Cannot download version %s of module '%s' from '%s' (registry modules can only be downloaded from http, https, s3, gcs, or git locations)",
+			moduleVersion, module.String(), withoutSSHKey(moduleSource),
+		)
+	}
+
+	return (&getter.Client{
+		Src:     moduleSource,
+		Dst:     destination,
+		Mode:    getter.ClientModeDir,
+		Getters: getters,
+	}).Get()
+}
+
+// GetFile is not supported (registry modules are always directories).
+func (registry *registryGetter) GetFile(destination string, sourceURL *url.URL) error {
+	return fmt.Errorf("Cannot fetch registry module '%s' as a file (registry modules are directories)", sourceURL.String())
+}
+
+// Resolve the module's download location (and version), using the newest version that matches the specified version constraint (if any).
+func (module *registryModule) Resolve(versionConstraint string) (location string, moduleVersion string, err error) {
+	modulesURL, err := module.discoverModulesURL()
+	if err != nil {
+		return "", "", err
+	}
+
+	moduleVersion, err = module.selectVersion(modulesURL, versionConstraint)
+	if err != nil {
+		return "", "", err
+	}
+
+	location, err = module.getDownloadLocation(modulesURL, moduleVersion)
+	if err != nil {
+		return "", "", err
+	}
+
+	return location, moduleVersion, nil
+}
+
+// String returns the module's address.
+func (module *registryModule) String() string {
+	return fmt.Sprintf("%s/%s/%s/%s", module.BaseURL.Host, module.Namespace, module.Name, module.Provider)
+}
+
+// Use the registry's service discovery document to find the base URL for its modules API.
+func (module *registryModule) discoverModulesURL() (*url.URL, error) {
+	discoveryURL, err := module.BaseURL.Parse(registryDiscoveryPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var services map[string]interface{}
+	_, err = module.getJSON(discoveryURL, &services)
+	if err != nil {
+		return nil, fmt.Errorf("Service discovery failed for registry '%s': %s", module.BaseURL.Host, err.Error())
+	}
+
+	modulesLocation, ok := services[registryModulesService].(string)
+	if !ok {
+		return nil, fmt.Errorf("'%s' does not provide a Terraform module registry (service '%s' not found in '%s')",
+			module.BaseURL.Host,
+			registryModulesService,
+			discoveryURL.String(),
+		)
+	}
+	if !strings.HasSuffix(modulesLocation, "/") {
+		modulesLocation += "/"
+	}
+
+	return discoveryURL.Parse(modulesLocation)
+}
+
+// Select the newest available version of the module that matches the specified version constraint.
+//
+// If no constraint is specified, the newest version that is not a pre-release is selected.
+func (module *registryModule) selectVersion(modulesURL *url.URL, versionConstraint string) (string, error) {
+	var constraints version.Constraints
+	if versionConstraint != "" {
+		var err error
+		constraints, err = version.NewConstraint(versionConstraint)
+		if err != nil {
+			return "", fmt.Errorf("Invalid version constraint '%s': %s", versionConstraint, err.Error())
+		}
+	}
+
+	versionsURL, err := modulesURL.Parse(fmt.Sprintf("%s/%s/%s/versions", module.Namespace, module.Name, module.Provider))
+	if err != nil {
+		return "", err
+	}
+
+	var versionsResponse struct {
+		Modules []struct {
+			Versions []struct {
+				Version string `json:"version"`
+			} `json:"versions"`
+		} `json:"modules"`
+	}
+	_, err = module.getJSON(versionsURL, &versionsResponse)
+	if err != nil {
+		return "", fmt.Errorf("Unable to list versions of module '%s': %s", module.String(), err.Error())
+	}
+
+	var selectedVersion *version.Version
+	for _, moduleVersions := range versionsResponse.Modules {
+		for _, moduleVersion := range moduleVersions.Versions {
+			candidateVersion, err := version.NewVersion(moduleVersion.Version)
+			if err != nil {
+				continue // Ignore invalid versions
+			}
+			if constraints == nil && candidateVersion.Prerelease() != "" {
+				continue
+			}
+			if constraints != nil && !constraints.Check(candidateVersion) {
+				continue
+			}
+
+			if selectedVersion == nil || candidateVersion.GreaterThan(selectedVersion) {
+				selectedVersion = candidateVersion
+			}
+		}
+	}
+	if selectedVersion == nil {
+		if versionConstraint == "" {
+			return "", fmt.Errorf("No versions of module '%s' are available", module.String())
+		}
+
+		return "", fmt.Errorf("No version of module '%s' matches the version constraint '%s'", module.String(), versionConstraint)
+	}
+
+	return selectedVersion.Original(), nil
+}
+
+// Get the location (a go-getter source) that the specified version of the module can be downloaded from.
+func (module *registryModule) getDownloadLocation(modulesURL *url.URL, moduleVersion string) (string, error) {
+	downloadURL, err := modulesURL.Parse(fmt.Sprintf("%s/%s/%s/%s/download", module.Namespace, module.Name, module.Provider, moduleVersion))
+	if err != nil {
+		return "", err
+	}
+
+	response, err := module.get(downloadURL)
+	if err != nil {
+		return "", fmt.Errorf("Unable to download version %s of module '%s': %s", moduleVersion, module.String(), err.Error())
+	}
+	response.Body.Close()
+
+	location := response.Header.Get("X-Terraform-Get")
+	if location == "" {
+		return "", fmt.Errorf("Unable to download version %s of module '%s' (the registry did not supply a download location)", moduleVersion, module.String())
+	}
+
+	// Relative locations are relative to the download URL.
+	if !strings.Contains(location, "::") {
+		locationURL, err := url.Parse(location)
+		if err == nil && locationURL.Scheme == "" && locationURL.Host == "" {
+			location = downloadURL.ResolveReference(locationURL).String()
+		}
+	}
+
+	return location, nil
+}
+
+// Call a registry API, and deserialise its response from JSON.
+func (module *registryModule) getJSON(requestURL *url.URL, result interface{}) (*http.Response, error) {
+	response, err := module.get(requestURL)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	responseBody, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(responseBody, result)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid response from '%s': %s", requestURL.String(), err.Error())
+	}
+
+	return response, nil
+}
+
+// Call a registry API (the caller is responsible for closing the response body).
+//
+// If a token for the registry host is available (TF_TOKEN_<host>), it is supplied with the request.
+func (module *registryModule) get(requestURL *url.URL) (*http.Response, error) {
+	request, err := http.NewRequest("GET", requestURL.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if requestURL.Host == module.BaseURL.Host {
+		token := getRegistryToken(stripPort(requestURL.Host))
+		if token != "" {
+			request.Header.Set("Authorization", "Bearer "+token)
+		}
+	}
+
+	response, err := registryHTTPClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		response.Body.Close()
+
+		return nil, fmt.Errorf("Request to '%s' failed (%s)", requestURL.String(), response.Status)
+	}
+
+	return response, nil
+}
+
+// Get the API token (if any) for the specified registry host from the environment.
+//
+// As for Terraform, the variable name is TF_TOKEN_ followed by the host name, with periods replaced by underscores and hyphens by double underscores.
+func getRegistryToken(host string) string {
+	variableName := registryTokenEnvironmentPrefix + strings.NewReplacer(".", "_", "-", "__").Replace(host)
+
+	return os.Getenv(variableName)
+}
+
+// Parse a registry:: URL.
+func parseRegistryURL(sourceURL *url.URL) (*registryModule, error) {
+	if sourceURL.Scheme != "http" && sourceURL.Scheme != "https" {
+		return nil, fmt.Errorf("Invalid registry module URL '%s' (expected 'registry::https://host/namespace/name/provider')", sourceURL.String())
+	}
+
+	pathSegments := strings.Split(strings.Trim(sourceURL.Path, "/"), "/")
+	if len(pathSegments) != 3 {
+		return nil, fmt.Errorf("Invalid registry module URL '%s' (expected 'registry::https://host/namespace/name/provider')", sourceURL.String())
+	}
+
+	module := &registryModule{
+		BaseURL: &url.URL{
+			Scheme: sourceURL.Scheme,
+			Host:   sourceURL.Host,
+			Path:   "/",
+		},
+		Namespace: pathSegments[0],
+		Name:      pathSegments[1],
+		Provider:  pathSegments[2],
+	}
+	if !isValidRegistryModule(module.Namespace, module.Name, module.Provider) {
+		return nil, fmt.Errorf("Invalid registry module URL '%s' (invalid namespace, name, or provider)", sourceURL.String())
+	}
+
+	return module, nil
+}
+
+// Detect a module address in Terraform's format ("host/namespace/name/provider[//subdir]"), and convert it to a registry:: source.
+//
+// Addresses that refer to local paths (or that could be handled by another detector) are not treated as registry module addresses.
+// The host is required (e.g. "registry.terraform.io" for the public registry), since "namespace/name/provider" is also a plausible relative path
+// (and a mistyped local path should be reported as missing, rather than looked up in the public registry).
+func detectRegistrySource(source string, workingDirectory string) (string, bool) {
+	if strings.Contains(source, "://") || strings.Contains(source, "::") || strings.Contains(source, "\\") {
+		return "", false
+	}
+	if strings.HasPrefix(source, ".") || strings.HasPrefix(source, "/") || strings.HasPrefix(source, "~") {
+		return "", false
+	}
+
+	query := ""
+	queryIndex := strings.Index(source, "?")
+	if queryIndex != -1 {
+		query = source[queryIndex:]
+		source = source[:queryIndex]
+	}
+
+	address, subDir := getter.SourceDirSubdir(source)
+	if strings.HasSuffix(address, "/") {
+		return "", false
+	}
+	if _, err := os.Stat(filepath.Join(workingDirectory, address)); err == nil {
+		return "", false // It's a local path
+	}
+
+	pathSegments := strings.Split(address, "/")
+	if len(pathSegments) != 4 {
+		return "", false
+	}
+	host := pathSegments[0]
+	if !isValidRegistryHost(host) {
+		return "", false
+	}
+	pathSegments = pathSegments[1:]
+	if !isValidRegistryModule(pathSegments[0], pathSegments[1], pathSegments[2]) {
+		return "", false
+	}
+
+	registrySource := fmt.Sprintf("registry::https://%s/%s", host, strings.Join(pathSegments, "/"))
+	if subDir != "" {
+		registrySource += "//" + subDir
+	}
+
+	return registrySource + query, true
+}
+
+// Is the specified host name (with optional port) valid for a module registry?
+func isValidRegistryHost(host string) bool {
+	hostName := stripPort(host)
+	if hostName == host && !strings.Contains(host, ".") {
+		return false // Could be a relative path
+	}
+
+	return hostName != "" && !nonRegistryHosts[strings.ToLower(hostName)]
+}
+
+// Remove the port (if any) from a host.
+func stripPort(host string) string {
+	hostName, _, err := net.SplitHostPort(host)
+	if err != nil {
+		return host // No port
+	}
+
+	return hostName
+}
+
+// Are the specified namespace, name, and provider valid for a registry module?
+func isValidRegistryModule(namespace string, name string, provider string) bool {
+	return registryNamePattern.MatchString(namespace) &&
+		registryNamePattern.MatchString(name) &&
+		registryProviderPattern.MatchString(provider)
+}
@@ -0,0 +1,300 @@
+package fetch
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// A stand-in for a Terraform module registry that serves a single module (platform/dockerhost/aws) from a local directory.
+//
+// The module is downloaded (as a .tar.gz archive) from the registry server itself.
+type testRegistry struct {
+	*httptest.Server
+
+	// If not empty, the download location supplied (via X-Terraform-Get) instead of the module's archive.
+	Location string
+
+	// The path of the last module download request.
+	DownloadPath string
+
+	// The Authorization header from the last request.
+	Authorization string
+}
+
+func newTestRegistry(t *testing.T, moduleDir string) *testRegistry {
+	registry := &testRegistry{}
+	moduleArchive := newTestModuleArchive(t, moduleDir)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/terraform.json", func(response http.ResponseWriter, request *http.Request) {
+		response.Write([]byte(`{"modules.v1": "/api/modules/v1/"}`))
+	})
+	mux.HandleFunc("/api/modules/v1/platform/dockerhost/aws/versions", func(response http.ResponseWriter, request *http.Request) {
+		registry.Authorization = request.Header.Get("Authorization")
+		response.Write([]byte(`{"modules": [{"versions": [
+			{"version": "1.0.0"}, {"version": "2.1.3"}, {"version": "2.2.0"}, {"version": "3.0.0-beta1"}, {"version": "3.0.0"}
+		]}]}`))
+	})
+	mux.HandleFunc("/api/modules/v1/platform/dockerhost/aws/", func(response http.ResponseWriter, request *http.Request) {
+		registry.DownloadPath = request.URL.Path
+		location := registry.Location
+		if location == "" {
+			location = "/archives/dockerhost-aws.tar.gz" // Relative to the download URL
+		}
+		response.Header().Set("X-Terraform-Get", location)
+		response.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("/archives/dockerhost-aws.tar.gz", func(response http.ResponseWriter, request *http.Request) {
+		response.Write(moduleArchive)
+	})
+	registry.Server = httptest.NewServer(mux)
+
+	return registry
+}
+
+// Create a .tar.gz archive of the files in the specified module directory.
+func newTestModuleArchive(t *testing.T, moduleDir string) []byte {
+	var archive bytes.Buffer
+	gzipWriter := gzip.NewWriter(&archive)
+	tarWriter := tar.NewWriter(gzipWriter)
+
+	err := filepath.Walk(moduleDir, func(filePath string, fileInfo os.FileInfo, err error) error {
+		if err != nil || filePath == moduleDir {
+			return err
+		}
+
+		relativePath, err := filepath.Rel(moduleDir, filePath)
+		if err != nil {
+			return err
+		}
+		if fileInfo.IsDir() {
+			// go-getter's tar decompressor does not create parent directories for the files it extracts.
+			return tarWriter.WriteHeader(&tar.Header{
+				Name:     filepath.ToSlash(relativePath) + "/",
+				Mode:     0755,
+				Typeflag: tar.TypeDir,
+			})
+		}
+
+		content, err := ioutil.ReadFile(filePath)
+		if err != nil {
+			return err
+		}
+
+		err = tarWriter.WriteHeader(&tar.Header{
+			Name: filepath.ToSlash(relativePath),
+			Mode: 0644,
+			Size: int64(len(content)),
+		})
+		if err != nil {
+			return err
+		}
+		_, err = tarWriter.Write(content)
+
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tarWriter.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = gzipWriter.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return archive.Bytes()
+}
+
+func newTestModule(t *testing.T) string {
+	moduleDir, err := ioutil.TempDir("", "registry-module")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = os.MkdirAll(path.Join(moduleDir, "examples"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(path.Join(moduleDir, "main.tf"), []byte("# main"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(path.Join(moduleDir, "examples", "example.tf"), []byte("# example"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return moduleDir
+}
+
+func TestRegistryVersionConstraint(t *testing.T) {
+	moduleDir := newTestModule(t)
+	defer os.RemoveAll(moduleDir)
+	registry := newTestRegistry(t, moduleDir)
+	defer registry.Close()
+
+	destination, err := ioutil.TempDir("", "registry-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(destination)
+
+	os.Setenv("TF_TOKEN_127_0_0_1", "test-token")
+	defer os.Unsetenv("TF_TOKEN_127_0_0_1")
+
+	result, err := ContentWithOptions("registry::"+registry.URL+"/platform/dockerhost/aws", path.Join(destination, "module"), Options{
+		Version: "~> 2.1",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Version != "2.2.0" {
+		t.Fatalf("Expected version 2.2.0 to be selected (got '%s')", result.Version)
+	}
+	if registry.DownloadPath != "/api/modules/v1/platform/dockerhost/aws/2.2.0/download" {
+		t.Fatalf("Unexpected download request path '%s'", registry.DownloadPath)
+	}
+	if registry.Authorization != "Bearer test-token" {
+		t.Fatalf("Expected registry token to be supplied (got '%s')", registry.Authorization)
+	}
+
+	// Fetched from the location supplied by X-Terraform-Get.
+	content, err := ioutil.ReadFile(path.Join(destination, "module", "main.tf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "# main" {
+		t.Fatalf("Unexpected module content '%s'", content)
+	}
+}
+
+func TestRegistryLatestVersionAndSubdirectory(t *testing.T) {
+	moduleDir := newTestModule(t)
+	defer os.RemoveAll(moduleDir)
+	registry := newTestRegistry(t, moduleDir)
+	defer registry.Close()
+
+	destination, err := ioutil.TempDir("", "registry-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(destination)
+
+	result, err := ContentWithOptions("registry::"+registry.URL+"/platform/dockerhost/aws//examples", path.Join(destination, "module"), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Pre-release versions are not selected unless requested.
+	if result.Version != "3.0.0" {
+		t.Fatalf("Expected version 3.0.0 to be selected (got '%s')", result.Version)
+	}
+	_, err = os.Stat(path.Join(destination, "module", "example.tf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRegistryNoMatchingVersion(t *testing.T) {
+	moduleDir := newTestModule(t)
+	defer os.RemoveAll(moduleDir)
+	registry := newTestRegistry(t, moduleDir)
+	defer registry.Close()
+
+	destination, err := ioutil.TempDir("", "registry-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(destination)
+
+	_, err = ContentWithOptions("registry::"+registry.URL+"/platform/dockerhost/aws", path.Join(destination, "module"), Options{
+		Version: ">= 4.0",
+	})
+	if err == nil {
+		t.Fatal("Expected an error when no version matches the constraint")
+	}
+	if registry.DownloadPath != "" {
+		t.Fatalf("Module should not have been downloaded (requested '%s')", registry.DownloadPath)
+	}
+}
+
+func TestRegistryRejectsLocalDownloadLocations(t *testing.T) {
+	moduleDir := newTestModule(t)
+	defer os.RemoveAll(moduleDir)
+	registry := newTestRegistry(t, moduleDir)
+	defer registry.Close()
+
+	testCases := []string{
+		"file://" + moduleDir,
+		"file::" + moduleDir,
+		"registry::" + registry.URL + "/platform/dockerhost/aws",
+		"hg::https://example.com/repo",
+	}
+	for _, location := range testCases {
+		destination, err := ioutil.TempDir("", "registry-test")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		registry.Location = location
+		_, err = ContentWithOptions("registry::"+registry.URL+"/platform/dockerhost/aws", path.Join(destination, "module"), Options{})
+		if err == nil {
+			t.Errorf("Expected an error when the registry supplies the download location '%s'", location)
+		} else if !strings.Contains(err.Error(), "can only be downloaded from") {
+			t.Errorf("Unexpected error for download location '%s': %s", location, err.Error())
+		}
+
+		_, err = os.Stat(path.Join(destination, "module", "main.tf"))
+		if err == nil {
+			t.Errorf("Module should not have been downloaded from '%s'", location)
+		}
+		os.RemoveAll(destination)
+	}
+}
+
+func TestDetectRegistrySource(t *testing.T) {
+	workingDirectory, err := ioutil.TempDir("", "registry-detect")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(workingDirectory)
+
+	err = os.MkdirAll(path.Join(workingDirectory, "local", "aws", "prod", "main"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := map[string]string{
+		"registry.example.com/platform/dockerhost/aws":                 "registry::https://registry.example.com/platform/dockerhost/aws",
+		"registry.terraform.io/hashicorp/consul/aws//modules/x?ref=v1": "registry::https://registry.terraform.io/hashicorp/consul/aws//modules/x?ref=v1",
+		"localhost:8080/platform/dockerhost/aws":                       "registry::https://localhost:8080/platform/dockerhost/aws",
+		"configs/aws/prod":                                             "", // Mistyped (non-existent) relative path
+		"hashicorp/consul/aws":                                         "", // Host is required
+		"local/aws/prod/main":                                          "", // Exists locally
+		"configs/aws/prod/main":                                        "", // No dot in the host segment
+		"github.com/example/configs/aws":                               "", // Handled by the GitHub detector
+		"./registry.example.com/platform/dockerhost/aws":               "",
+		"https://registry.example.com/platform/dockerhost/aws":         "",
+		"registry.example.com/platform/docker_host!/aws":               "",
+		"registry.example.com/platform/dockerhost/aws/extra":           "",
+	}
+	for source, expectedSource := range testCases {
+		registrySource, ok := detectRegistrySource(source, workingDirectory)
+		if ok != (expectedSource != "") || registrySource != expectedSource {
+			t.Errorf("Expected '%s' to be detected as '%s' (got '%s', %t)", source, expectedSource, registrySource, ok)
+		}
+	}
+}
@@ -0,0 +1,305 @@
+package fetch
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/hashicorp/go-getter"
+)
+
+const (
+	// The endpoint for Google Cloud Storage's S3-compatible (XML) API.
+	gcsEndpoint = "https://storage.googleapis.com"
+
+	// The environment variable that supplies the access Id of the HMAC key used for Google Cloud Storage.
+	gcsAccessKeyIDVariable = "GCS_ACCESS_KEY_ID"
+
+	// The environment variable that supplies the secret of the HMAC key used for Google Cloud Storage.
+	gcsSecretAccessKeyVariable = "GCS_SECRET_ACCESS_KEY"
+)
+
+// s3Getter is a go-getter Getter that downloads from Amazon S3 or an S3-compatible object store (e.g. Google Cloud Storage, Minio).
+//
+// Objects in Amazon S3 are downloaded by go-getter's own S3Getter. That getter always uses the AWS endpoints,
+// so objects in other object stores are downloaded using a client configured for the store's endpoint.
+//
+// Supported URL forms:
+//
+// s3::https://s3.amazonaws.com/bucket/path (or s3-region.amazonaws.com, or s3.region.amazonaws.com)
+// s3::https://my-object-store:9000/bucket/path (any other host is treated as an S3-compatible endpoint)
+// s3::s3://bucket/path (uses the configured endpoint, or AWS)
+// gcs::https://storage.googleapis.com/bucket/path (or gcs::https://www.googleapis.com/storage/v1/bucket/path, or gcs::gs://bucket/path)
+//
+// The query parameters "region" and "version" (for individual objects only) are also supported,
+// as are go-getter's "aws_access_key_id", "aws_access_key_secret", and "aws_access_token".
+// Otherwise, credentials are resolved from the standard AWS chain (environment variables, shared credentials file / AWS_PROFILE, and EC2 instance role).
+//
+// Google Cloud Storage's S3-compatible API only accepts HMAC keys (not Google credentials), so GCS never uses the AWS chain;
+// unless credentials are supplied in the URL, the HMAC key is read from GCS_ACCESS_KEY_ID and GCS_SECRET_ACCESS_KEY.
+type s3Getter struct {
+	getter.S3Getter
+
+	// The endpoint to use (overrides the endpoint in the URL, if any).
+	Endpoint string
+
+	// Is the getter for Google Cloud Storage?
+	GCS bool
+}
+
+// The location of an object (or objects) in an S3-compatible object store.
+type s3Location struct {
+	// The object store endpoint (empty for Amazon S3).
+	Endpoint string
+
+	Region      string
+	Bucket      string
+	Key         string
+	Version     string
+	Credentials url.Values
+}
+
+// The query parameters that supply static credentials.
+var s3CredentialParameters = []string{"aws_access_key_id", "aws_access_key_secret", "aws_access_token"}
+
+// Get downloads all objects whose keys start with the path in the URL into the specified directory.
+func (getter *s3Getter) Get(destination string, sourceURL *url.URL) error {
+	location, err := getter.parseURL(sourceURL)
+	if err != nil {
+		return err
+	}
+	if location.Version != "" {
+		return fmt.Errorf("URL '%s' specifies an object version, but refers to a directory (versions can only be specified for individual objects)", sourceURL.String())
+	}
+
+	// Only match objects "inside" the directory (e.g. "configs/machine/" rather than "configs/machine-old/").
+	if location.Key != "" {
+		location.Key = strings.TrimSuffix(location.Key, "/") + "/"
+	}
+
+	if location.Endpoint == "" {
+		return getter.S3Getter.Get(destination, location.awsURL())
+	}
+
+	// Start from scratch.
+	err = os.RemoveAll(destination)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(destination, 0755 /* u=rwx,g=rx,o=rx */)
+	if err != nil {
+		return err
+	}
+
+	client := location.newClient()
+	request := &s3.ListObjectsInput{
+		Bucket: aws.String(location.Bucket),
+		Prefix: aws.String(location.Key),
+	}
+	for {
+		response, err := client.ListObjects(request)
+		if err != nil {
+			return err
+		}
+
+		var lastKey string
+		for _, object := range response.Contents {
+			key := aws.StringValue(object.Key)
+			lastKey = key
+			if strings.HasSuffix(key, "/") {
+				continue // Directory placeholder
+			}
+
+			relativePath := strings.TrimPrefix(key, location.Key)
+			objectDestination := filepath.Join(destination, filepath.FromSlash(relativePath))
+			if !strings.HasPrefix(objectDestination, filepath.Clean(destination)+string(filepath.Separator)) {
+				return fmt.Errorf("Object key '%s' would be written outside the destination directory", key)
+			}
+
+			err = downloadS3Object(client, objectDestination, location.Bucket, key, "")
+			if err != nil {
+				return err
+			}
+		}
+
+		if !aws.BoolValue(response.IsTruncated) || lastKey == "" {
+			break
+		}
+		request.Marker = aws.String(lastKey)
+	}
+
+	return nil
+}
+
+// GetFile downloads a single object to the specified file.
+func (getter *s3Getter) GetFile(destination string, sourceURL *url.URL) error {
+	location, err := getter.parseURL(sourceURL)
+	if err != nil {
+		return err
+	}
+
+	if location.Endpoint == "" {
+		return getter.S3Getter.GetFile(destination, location.awsURL())
+	}
+
+	return downloadS3Object(location.newClient(), destination, location.Bucket, location.Key, location.Version)
+}
+
+// Parse an S3 (or GCS) URL.
+func (getter *s3Getter) parseURL(sourceURL *url.URL) (*s3Location, error) {
+	query := sourceURL.Query()
+	location := &s3Location{
+		Version:     query.Get("version"),
+		Credentials: make(url.Values),
+	}
+
+	objectPath := sourceURL.Path
+	switch {
+	case sourceURL.Scheme == "s3" || sourceURL.Scheme == "gs":
+		// Bucket is the host name.
+		objectPath = "/" + sourceURL.Host + objectPath
+	case getter.GCS:
+		objectPath = strings.TrimPrefix(objectPath, "/storage/v1") // JSON API-style URLs
+	case strings.HasSuffix(sourceURL.Host, ".amazonaws.com"):
+		location.Region = parseS3Region(sourceURL.Host)
+	default:
+		location.Endpoint = fmt.Sprintf("%s://%s", sourceURL.Scheme, sourceURL.Host)
+	}
+	if getter.GCS {
+		location.Endpoint = gcsEndpoint
+		location.Region = "auto"
+	}
+	if getter.Endpoint != "" {
+		location.Endpoint = getter.Endpoint
+	}
+
+	pathParts := strings.SplitN(strings.TrimPrefix(objectPath, "/"), "/", 2)
+	if pathParts[0] == "" {
+		return nil, fmt.Errorf("URL '%s' does not specify a bucket", sourceURL.String())
+	}
+	location.Bucket = pathParts[0]
+	if len(pathParts) == 2 {
+		location.Key = pathParts[1]
+	}
+
+	if region := query.Get("region"); region != "" {
+		location.Region = region
+	}
+	if location.Region == "" {
+		location.Region = os.Getenv("AWS_REGION")
+	}
+	if location.Region == "" {
+		location.Region = "us-east-1"
+	}
+
+	for _, parameterName := range s3CredentialParameters {
+		if parameterValue, ok := query[parameterName]; ok {
+			location.Credentials[parameterName] = parameterValue
+		}
+	}
+	if getter.GCS && len(location.Credentials) == 0 {
+		accessKeyID := os.Getenv(gcsAccessKeyIDVariable)
+		secretAccessKey := os.Getenv(gcsSecretAccessKeyVariable)
+		if accessKeyID == "" || secretAccessKey == "" {
+			return nil, fmt.Errorf("Google Cloud Storage requires an HMAC key (set %s and %s)", gcsAccessKeyIDVariable, gcsSecretAccessKeyVariable)
+		}
+
+		location.Credentials.Set("aws_access_key_id", accessKeyID)
+		location.Credentials.Set("aws_access_key_secret", secretAccessKey)
+	}
+
+	return location, nil
+}
+
+// Create the equivalent Amazon S3 URL, in the form expected by go-getter's S3Getter (e.g. https://s3-ap-southeast-2.amazonaws.com/bucket/path).
+func (location *s3Location) awsURL() *url.URL {
+	host := "s3.amazonaws.com"
+	if location.Region != "us-east-1" {
+		host = "s3-" + location.Region + ".amazonaws.com"
+	}
+
+	query := make(url.Values)
+	for parameterName, parameterValue := range location.Credentials {
+		query[parameterName] = parameterValue
+	}
+	if location.Version != "" {
+		query.Set("version", location.Version)
+	}
+
+	return &url.URL{
+		Scheme:   "https",
+		Host:     host,
+		Path:     "/" + location.Bucket + "/" + location.Key,
+		RawQuery: query.Encode(),
+	}
+}
+
+// Create an S3 client for the location's endpoint.
+//
+// Unless credentials were supplied in the URL, they are resolved from the standard AWS chain (as they are by go-getter's S3Getter).
+func (location *s3Location) newClient() *s3.S3 {
+	config := aws.NewConfig().
+		WithRegion(location.Region).
+		WithEndpoint(location.Endpoint).
+		WithS3ForcePathStyle(true)
+	if len(location.Credentials) > 0 {
+		config = config.WithCredentials(credentials.NewStaticCredentials(
+			location.Credentials.Get("aws_access_key_id"),
+			location.Credentials.Get("aws_access_key_secret"),
+			location.Credentials.Get("aws_access_token"),
+		))
+	}
+
+	return s3.New(
+		session.New(config),
+	)
+}
+
+// Download an object to the specified file.
+func downloadS3Object(client *s3.S3, destination string, bucket string, key string, version string) error {
+	request := &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	}
+	if version != "" {
+		request.VersionId = aws.String(version)
+	}
+
+	response, err := client.GetObject(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	err = os.MkdirAll(filepath.Dir(destination), 0755 /* u=rwx,g=rx,o=rx */)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Create(destination)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = io.Copy(file, response.Body)
+
+	return err
+}
+
+// Parse the region from an AWS S3 host name (e.g. s3-ap-southeast-2.amazonaws.com, s3.ap-southeast-2.amazonaws.com).
+func parseS3Region(host string) string {
+	hostParts := strings.Split(host, ".")
+	if len(hostParts) == 4 && hostParts[0] == "s3" {
+		return hostParts[1] // s3.region.amazonaws.com
+	}
+
+	return strings.TrimPrefix(strings.TrimPrefix(hostParts[0], "s3-"), "s3")
+}
@@ -0,0 +1,216 @@
+package fetch
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// A minimal stand-in for an S3-compatible object store (path-style requests only).
+type testObjectStore struct {
+	// Object content, keyed by "bucket/key".
+	Objects map[string]string
+
+	// Versioned object content, keyed by "bucket/key?versionId".
+	Versions map[string]string
+}
+
+func (store *testObjectStore) ServeHTTP(response http.ResponseWriter, request *http.Request) {
+	pathParts := strings.SplitN(strings.TrimPrefix(request.URL.Path, "/"), "/", 2)
+	if len(pathParts) == 1 || pathParts[1] == "" {
+		store.listObjects(response, pathParts[0], request.URL.Query().Get("prefix"))
+
+		return
+	}
+
+	objectName := pathParts[0] + "/" + pathParts[1]
+	content, ok := store.Objects[objectName]
+	if versionID := request.URL.Query().Get("versionId"); versionID != "" {
+		content, ok = store.Versions[objectName+"?"+versionID]
+	}
+	if !ok {
+		response.WriteHeader(http.StatusNotFound)
+
+		return
+	}
+
+	response.Write([]byte(content))
+}
+
+func (store *testObjectStore) listObjects(response http.ResponseWriter, bucket string, prefix string) {
+	var keys []string
+	for objectName := range store.Objects {
+		if strings.HasPrefix(objectName, bucket+"/"+prefix) {
+			keys = append(keys, strings.TrimPrefix(objectName, bucket+"/"))
+		}
+	}
+	sort.Strings(keys)
+
+	listing := `<?xml version="1.0" encoding="UTF-8"?><ListBucketResult><Name>` + bucket + `</Name><IsTruncated>false</IsTruncated>`
+	for _, key := range keys {
+		listing += fmt.Sprintf("<Contents><Key>%s</Key><Size>%d</Size></Contents>", key, len(store.Objects[bucket+"/"+key]))
+	}
+	listing += "</ListBucketResult>"
+
+	response.Header().Set("Content-Type", "application/xml")
+	response.Write([]byte(listing))
+}
+
+func newTestObjectStore(t *testing.T) *httptest.Server {
+	os.Setenv("AWS_ACCESS_KEY_ID", "test-access-key")
+	os.Setenv("AWS_SECRET_ACCESS_KEY", "test-secret-key")
+
+	return httptest.NewServer(&testObjectStore{
+		Objects: map[string]string{
+			"configs/machine/main.tf":      "# main",
+			"configs/machine/modules/a.tf": "# a",
+			"configs/machine-old/main.tf":  "# old",
+			"configs/single.tf":            "# latest",
+		},
+		Versions: map[string]string{
+			"configs/single.tf?v1": "# v1",
+		},
+	})
+}
+
+func TestS3DirectoryFromEndpoint(t *testing.T) {
+	server := newTestObjectStore(t)
+	defer server.Close()
+
+	destination, err := ioutil.TempDir("", "s3-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(destination)
+
+	_, err = ContentWithOptions("s3::s3://configs/machine/", path.Join(destination, "machine"), Options{
+		S3Endpoint: server.URL,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedFiles := map[string]string{
+		"main.tf":      "# main",
+		"modules/a.tf": "# a",
+	}
+	for fileName, expectedContent := range expectedFiles {
+		content, err := ioutil.ReadFile(path.Join(destination, "machine", fileName))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(content) != expectedContent {
+			t.Fatalf("Unexpected content for '%s': '%s'", fileName, content)
+		}
+	}
+
+	// Objects from a directory whose name merely starts with the same prefix must not be included.
+	_, err = os.Stat(path.Join(destination, "machine", "old", "main.tf"))
+	if !os.IsNotExist(err) {
+		t.Fatal("Objects outside the requested directory were downloaded")
+	}
+}
+
+func TestS3FileVersionFromEndpoint(t *testing.T) {
+	server := newTestObjectStore(t)
+	defer server.Close()
+
+	destination, err := ioutil.TempDir("", "s3-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(destination)
+
+	// An endpoint in the URL is used if no endpoint is configured.
+	_, err = ContentWithOptions("s3::"+server.URL+"/configs/single.tf?version=v1", path.Join(destination, "single.tf"), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := ioutil.ReadFile(path.Join(destination, "single.tf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "# v1" {
+		t.Fatalf("Expected version 'v1' of the object (got '%s')", content)
+	}
+}
+
+func TestS3DirectoryVersionIsRejected(t *testing.T) {
+	s3 := &s3Getter{Endpoint: "http://localhost:9000"}
+	sourceURL, _ := url.Parse("s3://configs/machine/?version=v1")
+
+	err := s3.Get(os.TempDir(), sourceURL)
+	if err == nil || !strings.Contains(err.Error(), "specifies an object version") {
+		t.Fatalf("Expected an error for a versioned directory (got %v)", err)
+	}
+}
+
+func TestS3AWSURL(t *testing.T) {
+	testCases := map[string]string{
+		"s3://bucket/configs/main.tf":                                           "https://s3.amazonaws.com/bucket/configs/main.tf",
+		"s3://bucket/configs/main.tf?region=ap-southeast-2&version=3":           "https://s3-ap-southeast-2.amazonaws.com/bucket/configs/main.tf?version=3",
+		"https://s3.ap-southeast-2.amazonaws.com/bucket/main.tf":                "https://s3-ap-southeast-2.amazonaws.com/bucket/main.tf",
+		"https://s3-eu-west-1.amazonaws.com/bucket/main.tf?aws_access_key_id=a": "https://s3-eu-west-1.amazonaws.com/bucket/main.tf?aws_access_key_id=a",
+	}
+	for sourceURL, expectedURL := range testCases {
+		parsedURL, err := url.Parse(sourceURL)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		location, err := (&s3Getter{}).parseURL(parsedURL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if location.Endpoint != "" {
+			t.Fatalf("Expected no custom endpoint for '%s' (got '%s')", sourceURL, location.Endpoint)
+		}
+		if awsURL := location.awsURL().String(); awsURL != expectedURL {
+			t.Fatalf("Expected '%s' to be converted to '%s' (got '%s')", sourceURL, expectedURL, awsURL)
+		}
+	}
+}
+
+func TestGCSUsesGCSEndpoint(t *testing.T) {
+	os.Setenv(gcsAccessKeyIDVariable, "GOOG1EXAMPLE")
+	os.Setenv(gcsSecretAccessKeyVariable, "gcs-secret")
+	defer os.Unsetenv(gcsAccessKeyIDVariable)
+	defer os.Unsetenv(gcsSecretAccessKeyVariable)
+
+	parsedURL, _ := url.Parse("gs://bucket/configs/main.tf")
+
+	location, err := (&s3Getter{GCS: true}).parseURL(parsedURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if location.Endpoint != gcsEndpoint || location.Bucket != "bucket" || location.Key != "configs/main.tf" {
+		t.Fatalf("Unexpected location %+v", location)
+	}
+	if location.Credentials.Get("aws_access_key_id") != "GOOG1EXAMPLE" || location.Credentials.Get("aws_access_key_secret") != "gcs-secret" {
+		t.Fatalf("Expected the GCS HMAC key to be used (got %+v)", location.Credentials)
+	}
+}
+
+func TestGCSDoesNotUseAWSCredentials(t *testing.T) {
+	os.Unsetenv(gcsAccessKeyIDVariable)
+	os.Unsetenv(gcsSecretAccessKeyVariable)
+	os.Setenv("AWS_ACCESS_KEY_ID", "AKIAEXAMPLE")
+	os.Setenv("AWS_SECRET_ACCESS_KEY", "aws-secret")
+	defer os.Unsetenv("AWS_ACCESS_KEY_ID")
+	defer os.Unsetenv("AWS_SECRET_ACCESS_KEY")
+
+	parsedURL, _ := url.Parse("gs://bucket/configs/main.tf")
+
+	_, err := (&s3Getter{GCS: true}).parseURL(parsedURL)
+	if err == nil {
+		t.Fatal("Expected an error when no GCS HMAC key is supplied")
+	}
+}
@@ -0,0 +1,427 @@
+package fetch
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	// SignatureTypeMinisign represents a minisign (https://jedisct1.github.io/minisign/) signature.
+	SignatureTypeMinisign = "minisign"
+
+	// SignatureTypeGPG represents a GPG (OpenPGP) signature.
+	SignatureTypeGPG = "gpg"
+)
+
+// Directories that are excluded when calculating the checksum of fetched content.
+var excludedChecksumDirs = map[string]bool{
+	".git":       true,
+	".terraform": true,
+}
+
+// Checksum calculates the checksum (e.g. "sha256:0123...") of fetched content using the specified algorithm (md5, sha1, sha256, or sha512).
+//
+// The checksum of a file is the hash of its content.
+// The checksum of a directory is the hash of its manifest (see Manifest).
+func Checksum(fileOrDir string, algorithm string) (string, error) {
+	checksumHash, err := newChecksumHash(algorithm)
+	if err != nil {
+		return "", err
+	}
+
+	info, err := os.Stat(fileOrDir)
+	if err != nil {
+		return "", err
+	}
+
+	if info.IsDir() {
+		manifest, err := Manifest(fileOrDir)
+		if err != nil {
+			return "", err
+		}
+		checksumHash.Write(manifest)
+	} else {
+		err = hashFile(checksumHash, fileOrDir)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return algorithm + ":" + hex.EncodeToString(checksumHash.Sum(nil)), nil
+}
+
+// Manifest generates a manifest of the files in a directory (excluding .git and .terraform).
+//
+// The manifest has the same format as the output of sha256sum (one line per file, sorted by path, with paths relative to the directory and separated by "/").
+// Symbolic links are not followed; the hash of a link is the hash of its target path.
+// The manifest is what is signed (and hashed) to verify a directory.
+func Manifest(dir string) ([]byte, error) {
+	rootDir, err := filepath.EvalSymlinks(dir) // The file getter links to local directories, rather than copying them
+	if err != nil {
+		return nil, err
+	}
+
+	var relativePaths []string
+	err = filepath.Walk(rootDir, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if excludedChecksumDirs[info.Name()] && filePath != rootDir {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		relativePath, err := filepath.Rel(rootDir, filePath)
+		if err != nil {
+			return err
+		}
+		relativePaths = append(relativePaths, filepath.ToSlash(relativePath))
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(relativePaths)
+
+	var manifest bytes.Buffer
+	for _, relativePath := range relativePaths {
+		fileHash := sha256.New()
+		filePath := filepath.Join(rootDir, filepath.FromSlash(relativePath))
+		info, err := os.Lstat(filePath)
+		if err != nil {
+			return nil, err
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			linkTarget, err := os.Readlink(filePath)
+			if err != nil {
+				return nil, err
+			}
+			fileHash.Write([]byte(linkTarget))
+		} else {
+			err = hashFile(fileHash, filePath)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		fmt.Fprintf(&manifest, "%s  %s\n", hex.EncodeToString(fileHash.Sum(nil)), relativePath)
+	}
+
+	return manifest.Bytes(), nil
+}
+
+// Verify fetched content against the expected checksum and / or signature (if any) in the options.
+func verifyContent(destination string, options Options) error {
+	if options.Checksum == "" && options.Signature == "" {
+		return nil
+	}
+
+	err := checkVerifiableContent(destination)
+	if err != nil {
+		return err
+	}
+
+	if options.Checksum != "" {
+		err := verifyChecksum(destination, options.Checksum)
+		if err != nil {
+			return err
+		}
+	}
+
+	if options.Signature != "" {
+		err := verifySignature(destination, options)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Ensure that fetched content contains nothing that would be excluded from its checksum or signature.
+//
+// A .terraform directory is excluded from the manifest (so the hash of a configuration does not change once Terraform has initialised it),
+// which means that its content cannot be verified; fetched content must not include one.
+func checkVerifiableContent(destination string) error {
+	rootDir, err := filepath.EvalSymlinks(destination) // The file getter links to local directories, rather than copying them
+	if err != nil {
+		return err
+	}
+
+	return filepath.Walk(rootDir, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() || filePath == rootDir {
+			return nil
+		}
+
+		switch info.Name() {
+		case ".terraform":
+			relativePath, err := filepath.Rel(rootDir, filePath)
+			if err != nil {
+				return err
+			}
+
+			return fmt.Errorf("Cannot verify the fetched configuration because it contains '%s' (which is excluded from checksums and signatures)", filepath.ToSlash(relativePath))
+		case ".git":
+			return filepath.SkipDir
+		default:
+			return nil
+		}
+	})
+}
+
+// Verify that fetched content matches the expected checksum (e.g. "sha256:0123...").
+func verifyChecksum(destination string, expectedChecksum string) error {
+	algorithmAndValue := strings.SplitN(expectedChecksum, ":", 2)
+	if len(algorithmAndValue) != 2 {
+		return fmt.Errorf("Invalid checksum '%s' (expected 'algorithm:value', e.g. 'sha256:0123...')", expectedChecksum)
+	}
+
+	actualChecksum, err := Checksum(destination, algorithmAndValue[0])
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(actualChecksum, expectedChecksum) {
+		return fmt.Errorf("Checksum mismatch for fetched configuration (expected '%s' but found '%s')", expectedChecksum, actualChecksum)
+	}
+
+	return nil
+}
+
+// Verify fetched content against a detached signature, using the trusted keys in the options.
+//
+// For a file, the signature must be for the file's content; for a directory (or an unpacked archive), it must be for the directory's manifest (see Manifest).
+func verifySignature(destination string, options Options) error {
+	if options.TrustedKeysFile == "" {
+		return fmt.Errorf("Cannot verify the signature of the fetched configuration (no trusted keys have been specified)")
+	}
+
+	signatureType := options.SignatureType
+	if signatureType == "" {
+		signatureType = detectSignatureType(options.Signature)
+	}
+
+	workDir, err := ioutil.TempDir("", "docker-machine-driver-terraform-verify")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(workDir)
+
+	// The signature can come from anywhere that configuration can.
+	signatureSource, err := ParseSource(options.Signature)
+	if err != nil {
+		return err
+	}
+	signatureFile := path.Join(workDir, "signature")
+	_, err = ContentWithOptions(strings.TrimSuffix(signatureSource, "/"), signatureFile, Options{
+		S3Endpoint: options.S3Endpoint,
+		Cache:      options.Cache,
+	})
+	if err != nil {
+		return fmt.Errorf("Unable to fetch signature '%s': %s", options.Signature, err.Error())
+	}
+
+	signedFile := destination
+	info, err := os.Stat(destination)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		manifest, err := Manifest(destination)
+		if err != nil {
+			return err
+		}
+
+		signedFile = path.Join(workDir, "manifest")
+		err = ioutil.WriteFile(signedFile, manifest, 0600 /* u=rw,g=,o= */)
+		if err != nil {
+			return err
+		}
+	}
+
+	switch signatureType {
+	case SignatureTypeMinisign:
+		return verifyMinisignSignature(signedFile, signatureFile, options.TrustedKeysFile)
+	case SignatureTypeGPG:
+		return verifyGPGSignature(signedFile, signatureFile, options.TrustedKeysFile, workDir)
+	default:
+		return fmt.Errorf("Unsupported signature type '%s' (must be '%s' or '%s')", signatureType, SignatureTypeMinisign, SignatureTypeGPG)
+	}
+}
+
+// Verify a minisign signature against each of the trusted public keys (one per line, in the format written by "minisign -G").
+func verifyMinisignSignature(signedFile string, signatureFile string, trustedKeysFile string) error {
+	executablePath, err := exec.LookPath("minisign")
+	if err != nil {
+		return fmt.Errorf("Unable to verify minisign signature (cannot find the minisign executable): %s", err.Error())
+	}
+
+	trustedKeys, err := ioutil.ReadFile(trustedKeysFile)
+	if err != nil {
+		return err
+	}
+
+	var outputs []string
+	for _, line := range strings.Split(string(trustedKeys), "\n") {
+		publicKey := strings.TrimSpace(line)
+		if publicKey == "" || strings.HasPrefix(publicKey, "untrusted comment:") || strings.HasPrefix(publicKey, "#") {
+			continue
+		}
+
+		output, err := exec.Command(executablePath, "-V", "-q",
+			"-m", signedFile,
+			"-x", signatureFile,
+			"-P", publicKey,
+		).CombinedOutput()
+		if err == nil {
+			return nil
+		}
+		outputs = append(outputs, strings.TrimSpace(string(output)))
+	}
+	if len(outputs) == 0 {
+		return fmt.Errorf("No minisign public keys found in '%s'", trustedKeysFile)
+	}
+
+	return fmt.Errorf("Signature verification failed (the fetched configuration has been modified, or is not signed by a trusted key):\n%s", strings.Join(outputs, "\n"))
+}
+
+// Verify a GPG signature using only the trusted public keys (an exported, armoured or binary, keyring).
+func verifyGPGSignature(signedFile string, signatureFile string, trustedKeysFile string, workDir string) error {
+	executablePath, err := exec.LookPath("gpg")
+	if err != nil {
+		executablePath, err = exec.LookPath("gpg2")
+	}
+	if err != nil {
+		return fmt.Errorf("Unable to verify GPG signature (cannot find the gpg executable): %s", err.Error())
+	}
+
+	// Use an isolated home directory so that only the trusted keys are considered.
+	gpgHome := path.Join(workDir, "gnupg")
+	err = os.Mkdir(gpgHome, 0700 /* u=rwx,g=,o= */)
+	if err != nil {
+		return err
+	}
+
+	output, err := exec.Command(executablePath, "--batch", "--homedir", gpgHome, "--import", trustedKeysFile).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("Unable to import trusted keys from '%s':\n%s", trustedKeysFile, strings.TrimSpace(string(output)))
+	}
+
+	output, err = exec.Command(executablePath, "--batch", "--homedir", gpgHome, "--verify", signatureFile, signedFile).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("Signature verification failed (the fetched configuration has been modified, or is not signed by a trusted key):\n%s", strings.TrimSpace(string(output)))
+	}
+
+	return nil
+}
+
+// Determine the type of a signature from its file extension (minisign signatures end with ".minisig"; anything else is assumed to be GPG).
+func detectSignatureType(signature string) string {
+	if strings.HasSuffix(signature, ".minisig") {
+		return SignatureTypeMinisign
+	}
+
+	return SignatureTypeGPG
+}
+
+// Remove the "checksum" query parameter (if any) from a source, so it can be verified once the source has been fetched.
+//
+// go-getter verifies "?checksum=" itself, but only for files; this way, directories can be verified too.
+func extractChecksum(source string) (sourceWithoutChecksum string, checksum string, err error) {
+	if !strings.Contains(source, "checksum=") {
+		return source, "", nil
+	}
+
+	forcedGetter := ""
+	separatorIndex := strings.Index(source, "::")
+	if separatorIndex != -1 {
+		forcedGetter = source[:separatorIndex+2]
+		source = source[separatorIndex+2:]
+	}
+
+	sourceURL, err := url.Parse(source)
+	if err != nil {
+		return "", "", err
+	}
+
+	query := sourceURL.Query()
+	checksum = query.Get("checksum")
+	if checksum == "" {
+		return forcedGetter + source, "", nil
+	}
+	query.Del("checksum")
+	sourceURL.RawQuery = query.Encode()
+
+	return forcedGetter + sourceURL.String(), checksum, nil
+}
+
+// Add a "checksum" query parameter to a source (so that go-getter will verify it).
+func addChecksum(source string, checksum string) (string, error) {
+	forcedGetter := ""
+	separatorIndex := strings.Index(source, "::")
+	if separatorIndex != -1 {
+		forcedGetter = source[:separatorIndex+2]
+		source = source[separatorIndex+2:]
+	}
+
+	sourceURL, err := url.Parse(source)
+	if err != nil {
+		return "", err
+	}
+
+	query := sourceURL.Query()
+	query.Set("checksum", checksum)
+	sourceURL.RawQuery = query.Encode()
+
+	return forcedGetter + sourceURL.String(), nil
+}
+
+// Create the hash for a checksum algorithm.
+func newChecksumHash(algorithm string) (hash.Hash, error) {
+	switch algorithm {
+	case "md5":
+		return md5.New(), nil
+	case "sha1":
+		return sha1.New(), nil
+	case "sha256":
+		return sha256.New(), nil
+	case "sha512":
+		return sha512.New(), nil
+	default:
+		return nil, fmt.Errorf("Unsupported checksum type '%s'", algorithm)
+	}
+}
+
+// Add the content of a file to a hash.
+func hashFile(fileHash hash.Hash, fileName string) error {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = io.Copy(fileHash, file)
+
+	return err
+}
@@ -0,0 +1,415 @@
+package fetch
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"testing"
+)
+
+// Create a directory of configuration files for verification tests.
+func newVerifyTestDir(t *testing.T) (testDir string, configDir string) {
+	testDir, err := ioutil.TempDir("", "verify-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	configDir = path.Join(testDir, "config")
+	files := map[string]string{
+		"main.tf":             "# main\n",
+		"modules/network.tf":  "# network\n",
+		".git/config":         "[core]\n",
+		"modules/.git/config": "[core]\n",
+	}
+	for fileName, content := range files {
+		filePath := path.Join(configDir, fileName)
+		err = os.MkdirAll(path.Dir(filePath), 0700)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = ioutil.WriteFile(filePath, []byte(content), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = os.Symlink("main.tf", path.Join(configDir, "link.tf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return testDir, configDir
+}
+
+// Calculate the hex-encoded SHA256 hash of a string.
+func sha256Hex(data string) string {
+	dataHash := sha256.Sum256([]byte(data))
+
+	return hex.EncodeToString(dataHash[:])
+}
+
+func TestManifest(t *testing.T) {
+	testDir, configDir := newVerifyTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	manifest, err := Manifest(configDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Files under .git are excluded, and a link is hashed by its target path.
+	expectedManifest := sha256Hex("main.tf") + "  link.tf\n" +
+		sha256Hex("# main\n") + "  main.tf\n" +
+		sha256Hex("# network\n") + "  modules/network.tf\n"
+	if string(manifest) != expectedManifest {
+		t.Errorf("Expected manifest:\n%s\n(got:\n%s)", expectedManifest, string(manifest))
+	}
+
+	// A link to the directory has the same manifest.
+	linkDir := path.Join(testDir, "link")
+	err = os.Symlink(configDir, linkDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	linkManifest, err := Manifest(linkDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(linkManifest) != expectedManifest {
+		t.Errorf("Expected the same manifest via a link to the directory (got:\n%s)", string(linkManifest))
+	}
+}
+
+func TestChecksum(t *testing.T) {
+	testDir, configDir := newVerifyTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	manifest, err := Manifest(configDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		fileOrDir        string
+		algorithm        string
+		expectedChecksum string
+	}{
+		{path.Join(configDir, "main.tf"), "sha256", "sha256:" + sha256Hex("# main\n")},
+		{path.Join(configDir, "main.tf"), "md5", "md5:" + fmt.Sprintf("%x", md5.Sum([]byte("# main\n")))},
+		{configDir, "sha256", "sha256:" + sha256Hex(string(manifest))},
+	}
+	for _, testCase := range testCases {
+		checksum, err := Checksum(testCase.fileOrDir, testCase.algorithm)
+		if err != nil {
+			t.Errorf("Unexpected error calculating %s checksum of '%s': %s", testCase.algorithm, testCase.fileOrDir, err.Error())
+
+			continue
+		}
+		if checksum != testCase.expectedChecksum {
+			t.Errorf("Expected %s checksum of '%s' to be '%s' (got '%s')", testCase.algorithm, testCase.fileOrDir, testCase.expectedChecksum, checksum)
+		}
+	}
+
+	_, err = Checksum(configDir, "crc32")
+	if err == nil || !strings.Contains(err.Error(), "Unsupported checksum type 'crc32'") {
+		t.Errorf("Expected an error for an unsupported checksum type (got %v)", err)
+	}
+}
+
+func TestVerifyChecksum(t *testing.T) {
+	testDir, configDir := newVerifyTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	fileChecksum := "sha256:" + sha256Hex("# main\n")
+	dirChecksum, err := Checksum(configDir, "sha256")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		destination   string
+		checksum      string
+		expectedError string
+	}{
+		{path.Join(configDir, "main.tf"), fileChecksum, ""},
+		{path.Join(configDir, "main.tf"), strings.ToUpper(fileChecksum[len("sha256:"):]), "Invalid checksum"},
+		{path.Join(configDir, "main.tf"), "sha256:" + strings.ToUpper(fileChecksum[len("sha256:"):]), ""},
+		{path.Join(configDir, "main.tf"), "sha256:" + sha256Hex("# modified\n"), "Checksum mismatch"},
+		{path.Join(configDir, "main.tf"), "crc32:01234567", "Unsupported checksum type"},
+		{configDir, dirChecksum, ""},
+		{configDir, fileChecksum, "Checksum mismatch"},
+	}
+	for _, testCase := range testCases {
+		err := verifyChecksum(testCase.destination, testCase.checksum)
+		if testCase.expectedError == "" {
+			if err != nil {
+				t.Errorf("Unexpected error verifying '%s' against '%s': %s", testCase.destination, testCase.checksum, err.Error())
+			}
+
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), testCase.expectedError) {
+			t.Errorf("Expected error containing '%s' verifying '%s' against '%s' (got %v)", testCase.expectedError, testCase.destination, testCase.checksum, err)
+		}
+	}
+
+	// Changes to excluded directories do not affect the checksum, but changes to anything else do.
+	err = ioutil.WriteFile(path.Join(configDir, ".git", "HEAD"), []byte("ref: refs/heads/master\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = verifyChecksum(configDir, dirChecksum)
+	if err != nil {
+		t.Errorf("Unexpected error after changing .git: %s", err.Error())
+	}
+	err = ioutil.WriteFile(path.Join(configDir, "extra.tf"), []byte("# extra\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = verifyChecksum(configDir, dirChecksum)
+	if err == nil || !strings.Contains(err.Error(), "Checksum mismatch") {
+		t.Errorf("Expected a checksum mismatch after adding a file (got %v)", err)
+	}
+}
+
+func TestVerifyContentRejectsTerraformDir(t *testing.T) {
+	testCases := []struct {
+		terraformDir  string
+		options       Options
+		expectedError string
+	}{
+		{".terraform", Options{}, ""},
+		{".terraform", Options{Checksum: "sha256:"}, "contains '.terraform'"},
+		{"modules/.terraform", Options{Checksum: "sha256:"}, "contains 'modules/.terraform'"},
+		{".terraform", Options{Signature: "config.sig"}, "contains '.terraform'"},
+		{".git/.terraform", Options{Checksum: "sha256:"}, ""},
+	}
+	for _, testCase := range testCases {
+		testDir, configDir := newVerifyTestDir(t)
+		defer os.RemoveAll(testDir)
+
+		options := testCase.options
+		if options.Checksum != "" {
+			checksum, err := Checksum(configDir, "sha256")
+			if err != nil {
+				t.Fatal(err)
+			}
+			options.Checksum = checksum
+		}
+
+		// The checksum does not change when .terraform is added (so it cannot be used to detect it).
+		err := os.MkdirAll(path.Join(configDir, testCase.terraformDir, "providers"), 0700)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = ioutil.WriteFile(path.Join(configDir, testCase.terraformDir, "providers", "terraform-provider-evil"), []byte("#!/bin/sh\n"), 0700)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = verifyContent(configDir, options)
+		if testCase.expectedError == "" {
+			if err != nil {
+				t.Errorf("Unexpected error for '%s' with options %+v: %s", testCase.terraformDir, testCase.options, err.Error())
+			}
+
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), testCase.expectedError) {
+			t.Errorf("Expected error containing \"%s\" for '%s' with options %+v (got %v)", testCase.expectedError, testCase.terraformDir, testCase.options, err)
+		}
+	}
+}
+
+func TestExtractChecksum(t *testing.T) {
+	testCases := []struct {
+		source           string
+		expectedSource   string
+		expectedChecksum string
+	}{
+		{"https://example.com/config.zip", "https://example.com/config.zip", ""},
+		{"https://example.com/config.zip?checksum=sha256:0123", "https://example.com/config.zip", "sha256:0123"},
+		{"https://example.com/config.zip?archive=zip&checksum=md5:abcd", "https://example.com/config.zip?archive=zip", "md5:abcd"},
+		{"git::https://example.com/repo.git?ref=v1&checksum=sha256:0123", "git::https://example.com/repo.git?ref=v1", "sha256:0123"},
+		{"s3::https://s3.amazonaws.com/bucket/config?checksum=", "s3::https://s3.amazonaws.com/bucket/config?checksum=", ""},
+	}
+	for _, testCase := range testCases {
+		source, checksum, err := extractChecksum(testCase.source)
+		if err != nil {
+			t.Errorf("Unexpected error extracting checksum from '%s': %s", testCase.source, err.Error())
+
+			continue
+		}
+		if source != testCase.expectedSource || checksum != testCase.expectedChecksum {
+			t.Errorf("Expected '%s' to yield ('%s', '%s') (got ('%s', '%s'))",
+				testCase.source, testCase.expectedSource, testCase.expectedChecksum, source, checksum,
+			)
+		}
+	}
+}
+
+func TestAddChecksum(t *testing.T) {
+	testCases := []struct {
+		source         string
+		checksum       string
+		expectedSource string
+	}{
+		{"https://example.com/config.zip", "sha256:0123", "https://example.com/config.zip?checksum=sha256%3A0123"},
+		{"https://example.com/config.zip?archive=zip", "md5:abcd", "https://example.com/config.zip?archive=zip&checksum=md5%3Aabcd"},
+		{"https://example.com/config.zip?checksum=md5:abcd", "sha256:0123", "https://example.com/config.zip?checksum=sha256%3A0123"},
+		{"git::https://example.com/repo.git?ref=v1", "sha256:0123", "git::https://example.com/repo.git?checksum=sha256%3A0123&ref=v1"},
+	}
+	for _, testCase := range testCases {
+		source, err := addChecksum(testCase.source, testCase.checksum)
+		if err != nil {
+			t.Errorf("Unexpected error adding checksum to '%s': %s", testCase.source, err.Error())
+
+			continue
+		}
+		if source != testCase.expectedSource {
+			t.Errorf("Expected '%s' with checksum '%s' to be '%s' (got '%s')", testCase.source, testCase.checksum, testCase.expectedSource, source)
+		}
+
+		// The checksum can be extracted again.
+		_, checksum, err := extractChecksum(source)
+		if err != nil || checksum != testCase.checksum {
+			t.Errorf("Expected to extract checksum '%s' from '%s' (got '%s', %v)", testCase.checksum, source, checksum, err)
+		}
+	}
+}
+
+func TestDetectSignatureType(t *testing.T) {
+	testCases := map[string]string{
+		"https://example.com/config.zip.minisig": SignatureTypeMinisign,
+		"/signatures/config.minisig":             SignatureTypeMinisign,
+		"https://example.com/config.zip.sig":     SignatureTypeGPG,
+		"https://example.com/config.zip.asc":     SignatureTypeGPG,
+		"s3::https://s3.amazonaws.com/b/sig":     SignatureTypeGPG,
+		"config.minisig.asc":                     SignatureTypeGPG,
+	}
+	for signature, expectedType := range testCases {
+		signatureType := detectSignatureType(signature)
+		if signatureType != expectedType {
+			t.Errorf("Expected signature '%s' to be of type '%s' (got '%s')", signature, expectedType, signatureType)
+		}
+	}
+}
+
+func TestVerifySignatureRequiresTrustedKeys(t *testing.T) {
+	testDir, configDir := newVerifyTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	err := verifySignature(configDir, Options{Signature: path.Join(testDir, "config.sig")})
+	if err == nil || !strings.Contains(err.Error(), "no trusted keys") {
+		t.Errorf("Expected an error when no trusted keys have been specified (got %v)", err)
+	}
+
+	err = verifySignature(configDir, Options{
+		Signature:       path.Join(testDir, "config.sig"),
+		SignatureType:   "pgp",
+		TrustedKeysFile: path.Join(testDir, "keys.gpg"),
+	})
+	if err == nil {
+		t.Errorf("Expected an error for an unsupported signature type")
+	}
+}
+
+// Run gpg with the specified home directory (failing the test if it returns an error).
+func runTestGPG(t *testing.T, gpgHome string, args ...string) {
+	output, err := exec.Command("gpg", append([]string{"--batch", "--homedir", gpgHome}, args...)...).CombinedOutput()
+	if err != nil {
+		t.Fatalf("gpg %s failed: %s\n%s", strings.Join(args, " "), err.Error(), string(output))
+	}
+}
+
+func TestVerifyGPGSignature(t *testing.T) {
+	_, err := exec.LookPath("gpg")
+	if err != nil {
+		t.Skip("gpg is not available")
+	}
+
+	testDir, configDir := newVerifyTestDir(t)
+	defer os.RemoveAll(testDir)
+
+	gpgHome := path.Join(testDir, "gnupg")
+	err = os.Mkdir(gpgHome, 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	runTestGPG(t, gpgHome, "--passphrase", "", "--quick-generate-key", "signer@example.com", "ed25519", "sign", "never")
+	runTestGPG(t, gpgHome, "--export", "-o", path.Join(testDir, "keys.gpg"))
+
+	// Sign the file, and the directory's manifest.
+	manifest, err := Manifest(configDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifestFile := path.Join(testDir, "manifest")
+	err = ioutil.WriteFile(manifestFile, manifest, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	runTestGPG(t, gpgHome, "--detach-sign", "-o", path.Join(testDir, "config.sig"), manifestFile)
+	runTestGPG(t, gpgHome, "--detach-sign", "-o", path.Join(testDir, "main.tf.sig"), path.Join(configDir, "main.tf"))
+
+	options := Options{
+		Signature:       path.Join(testDir, "config.sig"),
+		TrustedKeysFile: path.Join(testDir, "keys.gpg"),
+	}
+	err = verifySignature(configDir, options)
+	if err != nil {
+		t.Fatalf("Unexpected error verifying directory signature: %s", err.Error())
+	}
+
+	// A signature for different content.
+	options.Signature = path.Join(testDir, "main.tf.sig")
+	err = verifySignature(configDir, options)
+	if err == nil || !strings.Contains(err.Error(), "Signature verification failed") {
+		t.Errorf("Expected verification to fail for a signature of different content (got %v)", err)
+	}
+	err = verifySignature(path.Join(configDir, "main.tf"), options)
+	if err != nil {
+		t.Errorf("Unexpected error verifying file signature: %s", err.Error())
+	}
+
+	// A signature that is not valid.
+	err = ioutil.WriteFile(path.Join(testDir, "bad.sig"), []byte("not a signature"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	options.Signature = path.Join(testDir, "bad.sig")
+	err = verifySignature(path.Join(configDir, "main.tf"), options)
+	if err == nil || !strings.Contains(err.Error(), "Signature verification failed") {
+		t.Errorf("Expected verification to fail for an invalid signature (got %v)", err)
+	}
+
+	// Content modified after it was signed.
+	err = ioutil.WriteFile(path.Join(configDir, "modules", "network.tf"), []byte("# modified\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	options.Signature = path.Join(testDir, "config.sig")
+	err = verifySignature(configDir, options)
+	if err == nil || !strings.Contains(err.Error(), "Signature verification failed") {
+		t.Errorf("Expected verification to fail for modified content (got %v)", err)
+	}
+
+	// A signature by a key that is not trusted.
+	otherHome := path.Join(testDir, "other-gnupg")
+	err = os.Mkdir(otherHome, 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	runTestGPG(t, otherHome, "--passphrase", "", "--quick-generate-key", "other@example.com", "ed25519", "sign", "never")
+	runTestGPG(t, otherHome, "--detach-sign", "-o", path.Join(testDir, "untrusted.sig"), path.Join(configDir, "main.tf"))
+	options.Signature = path.Join(testDir, "untrusted.sig")
+	err = verifySignature(path.Join(configDir, "main.tf"), options)
+	if err == nil || !strings.Contains(err.Error(), "Signature verification failed") {
+		t.Errorf("Expected verification to fail for a signature by an untrusted key (got %v)", err)
+	}
+}
@@ -0,0 +1,310 @@
+package main
+
+/*
+ * Command-line interface (configuration inspection)
+ * -------------------------------------------------
+ *
+ * docker-machine-driver-terraform inspect [--format json|yaml] [--s3-endpoint URL] [--config-version CONSTRAINT] [--ssh-key FILE] [--manifest] <source>
+ */
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+
+	"github.com/tintoy/docker-machine-driver-terraform/fetch"
+	"github.com/tintoy/docker-machine-driver-terraform/terraform/config"
+	yaml "gopkg.in/yaml.v3"
+)
+
+// Replaces the default value of sensitive variables in configuration descriptions.
+const redactedDefaultValue = "<sensitive>"
+
+// A description of a Terraform configuration's inputs and outputs.
+type configDescription struct {
+	Source    string                `json:"source" yaml:"source"`
+	Commit    string                `json:"commit,omitempty" yaml:"commit,omitempty"`
+	Version   string                `json:"version,omitempty" yaml:"version,omitempty"`
+	Checksum  string                `json:"checksum,omitempty" yaml:"checksum,omitempty"`
+	Variables []variableDescription `json:"variables" yaml:"variables"`
+	Outputs   []outputDescription   `json:"outputs" yaml:"outputs"`
+	Contract  contractDescription   `json:"contract" yaml:"contract"`
+}
+
+// A description of a variable declared by a Terraform configuration.
+type variableDescription struct {
+	Name        string      `json:"name" yaml:"name"`
+	Type        string      `json:"type,omitempty" yaml:"type,omitempty"`
+	Default     interface{} `json:"default" yaml:"default"`
+	Required    bool        `json:"required" yaml:"required"`
+	Description string      `json:"description,omitempty" yaml:"description,omitempty"`
+	Sensitive   bool        `json:"sensitive" yaml:"sensitive"`
+}
+
+// A description of an output declared by a Terraform configuration.
+type outputDescription struct {
+	Name        string `json:"name" yaml:"name"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+	Sensitive   bool   `json:"sensitive" yaml:"sensitive"`
+}
+
+// A description of how well a Terraform configuration supports the driver's contract.
+type contractDescription struct {
+	// Does the configuration support all required contract items?
+	Complete bool `json:"complete" yaml:"complete"`
+
+	// The built-in (dm_*) variables that the configuration uses.
+	SupportedVariables []string `json:"supported_variables" yaml:"supported_variables"`
+
+	// The built-in (dm_*) variables that the configuration does not use.
+	UnusedVariables []string `json:"unused_variables" yaml:"unused_variables"`
+
+	// The (dm_*) outputs that the configuration supplies.
+	SupportedOutputs []string `json:"supported_outputs" yaml:"supported_outputs"`
+
+	// The optional (dm_*) outputs that the configuration does not supply.
+	MissingOptionalOutputs []string `json:"missing_optional_outputs" yaml:"missing_optional_outputs"`
+
+	// The required (dm_*) outputs that the configuration does not supply.
+	MissingRequiredOutputs []string `json:"missing_required_outputs" yaml:"missing_required_outputs"`
+}
+
+// Run the "inspect" command.
+func runInspectCommand(arguments []string) error {
+	commandFlags := flag.NewFlagSet("inspect", flag.ContinueOnError)
+	format := commandFlags.String("format", "json", "The output format (json or yaml)")
+	s3Endpoint := commandFlags.String("s3-endpoint", os.Getenv("TERRAFORM_CONFIG_S3_ENDPOINT"),
+		"The endpoint to use for s3:: sources (defaults to $TERRAFORM_CONFIG_S3_ENDPOINT)",
+	)
+	configVersion := commandFlags.String("config-version", "", "The version constraint (e.g. \"~> 2.1\") used to select the module version for module registry sources")
+	sshKey := commandFlags.String("ssh-key", os.Getenv("TERRAFORM_CONFIG_SSH_KEY"),
+		"The SSH private key file to use for git sources (defaults to $TERRAFORM_CONFIG_SSH_KEY)",
+	)
+	manifest := commandFlags.Bool("manifest", false, "Write the configuration's manifest (the content to sign for --terraform-config-signature) instead of describing it")
+	commandFlags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage:")
+		fmt.Fprintln(os.Stderr, "  docker-machine-driver-terraform inspect [--format json|yaml] [--s3-endpoint URL] [--config-version CONSTRAINT] [--ssh-key FILE] [--manifest] <source>")
+		commandFlags.PrintDefaults()
+	}
+	err := commandFlags.Parse(arguments)
+	if err != nil {
+		return err
+	}
+
+	arguments = commandFlags.Args()
+	if len(arguments) != 1 {
+		commandFlags.Usage()
+
+		return errors.New("The inspect command requires a configuration source (path or URL)")
+	}
+	if *format != "json" && *format != "yaml" {
+		return fmt.Errorf("Unsupported output format '%s' (must be 'json' or 'yaml')", *format)
+	}
+
+	fetchOptions := fetch.Options{
+		S3Endpoint:    *s3Endpoint,
+		Version:       *configVersion,
+		GitSSHKeyFile: *sshKey,
+	}
+	if *manifest {
+		output, err := manifestConfig(arguments[0], fetchOptions)
+		if err != nil {
+			return err
+		}
+
+		_, err = os.Stdout.Write(output)
+
+		return err
+	}
+
+	description, err := inspectConfig(arguments[0], fetchOptions)
+	if err != nil {
+		return err
+	}
+
+	var output []byte
+	if *format == "yaml" {
+		output, err = yaml.Marshal(description)
+	} else {
+		output, err = json.MarshalIndent(description, "", "  ")
+		output = append(output, '\n')
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = os.Stdout.Write(output)
+
+	return err
+}
+
+// Fetch the Terraform configuration from the specified source, and describe its inputs and outputs.
+func inspectConfig(source string, fetchOptions fetch.Options) (*configDescription, error) {
+	var description *configDescription
+	err := withFetchedConfig(source, fetchOptions, func(parsedSource string, configDir string, result *fetch.Result) error {
+		module, err := config.LoadModule(configDir)
+		if err != nil {
+			return err
+		}
+		description = describeModule(parsedSource, module)
+		description.Commit = result.Commit
+		description.Version = result.Version
+
+		// Only directory checksums are calculated by the driver (go-getter verifies the checksums of files and archives before they are unpacked).
+		if fetch.IsDirectorySource(parsedSource) {
+			description.Checksum, err = fetch.Checksum(configDir, "sha256")
+		}
+
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return description, nil
+}
+
+// Fetch the Terraform configuration from the specified source, and generate its manifest.
+func manifestConfig(source string, fetchOptions fetch.Options) ([]byte, error) {
+	var manifest []byte
+	err := withFetchedConfig(source, fetchOptions, func(parsedSource string, configDir string, result *fetch.Result) error {
+		var err error
+		manifest, err = fetch.Manifest(configDir)
+
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return manifest, nil
+}
+
+// Fetch the Terraform configuration from the specified source into a temporary directory, and invoke the specified function.
+func withFetchedConfig(source string, fetchOptions fetch.Options, action func(parsedSource string, configDir string, result *fetch.Result) error) error {
+	parsedSource, err := fetch.ParseSource(source)
+	if err != nil {
+		return err
+	}
+
+	workDir, err := ioutil.TempDir("", "docker-machine-driver-terraform-inspect")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(workDir)
+
+	// Directory must not exist until fetch (go-getter) creates it.
+	configDir := path.Join(workDir, "terraform-config")
+	result, err := fetch.ContentWithOptions(parsedSource, configDir, fetchOptions)
+	if err != nil {
+		return err
+	}
+
+	return action(parsedSource, configDir, result)
+}
+
+// Describe the inputs and outputs of a Terraform module.
+func describeModule(source string, module *config.Module) *configDescription {
+	description := &configDescription{
+		Source:    source,
+		Variables: []variableDescription{},
+		Outputs:   []outputDescription{},
+	}
+
+	for _, name := range module.VariableNames() {
+		variable := module.Variables[name]
+		defaultValue := toPlainValue(variable.Default)
+		if variable.Sensitive && defaultValue != nil {
+			defaultValue = redactedDefaultValue
+		}
+
+		description.Variables = append(description.Variables, variableDescription{
+			Name:        variable.Name,
+			Type:        variable.Type,
+			Default:     defaultValue,
+			Required:    variable.Required(),
+			Description: variable.Description,
+			Sensitive:   variable.Sensitive,
+		})
+	}
+	for _, name := range module.OutputNames() {
+		output := module.Outputs[name]
+		description.Outputs = append(description.Outputs, outputDescription{
+			Name:        output.Name,
+			Description: output.Description,
+			Sensitive:   output.Sensitive,
+		})
+	}
+
+	description.Contract = describeContract(module)
+
+	return description
+}
+
+// Describe how well a Terraform module supports the driver's contract.
+func describeContract(module *config.Module) contractDescription {
+	contract := contractDescription{
+		SupportedVariables:     []string{},
+		UnusedVariables:        []string{},
+		SupportedOutputs:       []string{},
+		MissingOptionalOutputs: []string{},
+		MissingRequiredOutputs: []string{},
+	}
+
+	for _, variableName := range builtInVariableNames {
+		if _, ok := module.Variables[variableName]; ok {
+			contract.SupportedVariables = append(contract.SupportedVariables, variableName)
+		} else {
+			contract.UnusedVariables = append(contract.UnusedVariables, variableName)
+		}
+	}
+	for _, item := range contractOutputs {
+		if _, ok := module.Outputs[item.Name]; ok {
+			contract.SupportedOutputs = append(contract.SupportedOutputs, item.Name)
+		} else if item.Required {
+			contract.MissingRequiredOutputs = append(contract.MissingRequiredOutputs, item.Name)
+		} else {
+			contract.MissingOptionalOutputs = append(contract.MissingOptionalOutputs, item.Name)
+		}
+	}
+	contract.Complete = len(contract.MissingRequiredOutputs) == 0
+
+	return contract
+}
+
+// Convert a value so that it is serialised the same way in JSON and YAML (e.g. json.Number becomes a number rather than a string).
+func toPlainValue(value interface{}) interface{} {
+	switch typedValue := value.(type) {
+	case json.Number:
+		intValue, err := typedValue.Int64()
+		if err == nil {
+			return intValue
+		}
+		floatValue, err := typedValue.Float64()
+		if err == nil {
+			return floatValue
+		}
+
+		return typedValue.String()
+	case []interface{}:
+		plainValue := make([]interface{}, len(typedValue))
+		for index, element := range typedValue {
+			plainValue[index] = toPlainValue(element)
+		}
+
+		return plainValue
+	case map[string]interface{}:
+		plainValue := make(map[string]interface{})
+		for key, element := range typedValue {
+			plainValue[key] = toPlainValue(element)
+		}
+
+		return plainValue
+	default:
+		return value
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+
+	"github.com/tintoy/docker-machine-driver-terraform/terraform/config"
+)
+
+func TestDescribeContract(t *testing.T) {
+	module := &config.Module{
+		Variables: map[string]*config.Variable{
+			"dm_machine_name": {Name: "dm_machine_name"},
+			"region":          {Name: "region"},
+		},
+		Outputs: map[string]*config.Output{
+			"dm_ssh_user": {Name: "dm_ssh_user"},
+		},
+	}
+
+	contract := describeContract(module)
+	if contract.Complete {
+		t.Errorf("Expected the contract to be incomplete when 'dm_machine_ip' is not supplied")
+	}
+	if !reflect.DeepEqual(contract.SupportedVariables, []string{"dm_machine_name"}) {
+		t.Errorf("Expected only 'dm_machine_name' to be supported (got %v)", contract.SupportedVariables)
+	}
+	if len(contract.UnusedVariables) != len(builtInVariableNames)-1 {
+		t.Errorf("Expected %d unused variables (got %v)", len(builtInVariableNames)-1, contract.UnusedVariables)
+	}
+	if !reflect.DeepEqual(contract.SupportedOutputs, []string{"dm_ssh_user"}) {
+		t.Errorf("Expected only 'dm_ssh_user' to be supplied (got %v)", contract.SupportedOutputs)
+	}
+	if !reflect.DeepEqual(contract.MissingRequiredOutputs, []string{"dm_machine_ip"}) {
+		t.Errorf("Expected only 'dm_machine_ip' to be missing (got %v)", contract.MissingRequiredOutputs)
+	}
+
+	module.Outputs["dm_machine_ip"] = &config.Output{Name: "dm_machine_ip"}
+	contract = describeContract(module)
+	if !contract.Complete {
+		t.Errorf("Expected the contract to be complete when 'dm_machine_ip' is supplied")
+	}
+}
+
+func TestToPlainValue(t *testing.T) {
+	value := map[string]interface{}{
+		"count":  json.Number("3"),
+		"ratio":  json.Number("0.5"),
+		"zones":  []interface{}{json.Number("1"), "b"},
+		"region": "syd",
+	}
+
+	expectedValue := map[string]interface{}{
+		"count":  int64(3),
+		"ratio":  0.5,
+		"zones":  []interface{}{int64(1), "b"},
+		"region": "syd",
+	}
+	plainValue := toPlainValue(value)
+	if !reflect.DeepEqual(plainValue, expectedValue) {
+		t.Errorf("Expected %#v (got %#v)", expectedValue, plainValue)
+	}
+}
+
+func TestDescribeModule(t *testing.T) {
+	configDir, err := ioutil.TempDir("", "inspect-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(configDir)
+
+	err = ioutil.WriteFile(path.Join(configDir, "main.tf"), []byte(`
+variable "dm_machine_name" {}
+
+variable "region" {
+  type        = string
+  description = "The target region"
+}
+
+variable "api_token" {
+  default   = "s3cr3t"
+  sensitive = true
+}
+
+variable "password" {
+  sensitive = true
+}
+
+output "dm_machine_ip" {
+  value = "10.0.0.1"
+}
+`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(path.Join(configDir, "sizes.tf.json"), []byte(`{
+  "variable": {
+    "size": { "default": 3 }
+  }
+}`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	module, err := config.LoadModule(configDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	description := describeModule("file::"+configDir, module)
+
+	expectedVariables := []variableDescription{
+		{Name: "api_token", Default: "<sensitive>", Sensitive: true},
+		{Name: "dm_machine_name", Required: true},
+		{Name: "password", Required: true, Sensitive: true},
+		{Name: "region", Type: "string", Required: true, Description: "The target region"},
+		{Name: "size", Default: int64(3)},
+	}
+	if !reflect.DeepEqual(description.Variables, expectedVariables) {
+		t.Errorf("Expected variables %+v (got %+v)", expectedVariables, description.Variables)
+	}
+
+	expectedOutputs := []outputDescription{
+		{Name: "dm_machine_ip"},
+	}
+	if !reflect.DeepEqual(description.Outputs, expectedOutputs) {
+		t.Errorf("Expected outputs %+v (got %+v)", expectedOutputs, description.Outputs)
+	}
+	if !description.Contract.Complete {
+		t.Errorf("Expected the contract to be complete")
+	}
+}
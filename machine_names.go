@@ -0,0 +1,83 @@
+package main
+
+/*
+ * Driver implementation (provider-safe names derived from the machine name)
+ * -------------------------------------------------------------------------
+ *
+ * Derivation rules:
+ *
+ * dm_machine_name_slug:  lower-case; each run of characters other than a-z and 0-9 becomes a single "-"; no leading or trailing "-".
+ * dm_machine_name_dns:   the slug, shortened (see below) to at most 63 characters so it is a valid RFC 1123 label.
+ * dm_machine_name_short: the slug, shortened (see below) to at most 15 characters (e.g. for Windows / NetBIOS computer names).
+ *
+ * A name that is too long is truncated, and a "-" and the first 6 hex digits of the SHA-256 hash of the (original) machine name are appended,
+ * so that long names with a common prefix still produce distinct values.
+ */
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+const (
+	// The maximum length of an RFC 1123 label (e.g. a DNS host name).
+	maxDNSLabelLength = 63
+
+	// The maximum length of a short machine name.
+	maxShortNameLength = 15
+
+	// The number of hex digits (from the hash of the machine name) appended to a truncated name.
+	nameHashLength = 6
+
+	// The slug used for a machine name that has no usable characters.
+	emptyNameSlug = "machine"
+)
+
+// Convert a machine name to a slug (lower-case letters, digits, and hyphens).
+func machineNameSlug(machineName string) string {
+	var slug []byte
+	pendingHyphen := false
+	for _, character := range []byte(strings.ToLower(machineName)) {
+		if (character >= 'a' && character <= 'z') || (character >= '0' && character <= '9') {
+			if pendingHyphen && len(slug) > 0 {
+				slug = append(slug, '-')
+			}
+			pendingHyphen = false
+
+			slug = append(slug, character)
+		} else {
+			pendingHyphen = true
+		}
+	}
+	if len(slug) == 0 {
+		return emptyNameSlug
+	}
+
+	return string(slug)
+}
+
+// Convert a machine name to a valid RFC 1123 label (at most 63 characters).
+func machineNameDNSLabel(machineName string) string {
+	return shortenMachineName(machineName, maxDNSLabelLength)
+}
+
+// Convert a machine name to a short name (at most 15 characters).
+func machineNameShort(machineName string) string {
+	return shortenMachineName(machineName, maxShortNameLength)
+}
+
+// Convert a machine name to a slug of no more than the specified length.
+func shortenMachineName(machineName string, maxLength int) string {
+	slug := machineNameSlug(machineName)
+	if len(slug) <= maxLength {
+		return slug
+	}
+
+	hash := sha256.Sum256([]byte(machineName))
+	suffix := hex.EncodeToString(hash[:])[:nameHashLength]
+
+	prefix := strings.TrimRight(slug[:maxLength-nameHashLength-1], "-")
+
+	return prefix + "-" + suffix
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// Matches a valid RFC 1123 label.
+var dnsLabelPattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?$`)
+
+func TestMachineNameSlug(t *testing.T) {
+	testCases := map[string]string{
+		"my-machine":       "my-machine",
+		"My_Machine.01":    "my-machine-01",
+		"UPPER_CASE":       "upper-case",
+		"--leading":        "leading",
+		"trailing--":       "trailing",
+		"-_both_-":         "both",
+		"a--b__c":          "a-b-c",
+		"___":              "machine",
+		"-.-":              "machine",
+		"":                 "machine",
+		"ÄÖ-über":          "ber",
+		"web server (dev)": "web-server-dev",
+	}
+	for machineName, expectedSlug := range testCases {
+		slug := machineNameSlug(machineName)
+		if slug != expectedSlug {
+			t.Errorf("Expected slug for '%s' to be '%s' (got '%s')", machineName, expectedSlug, slug)
+		}
+	}
+}
+
+func TestMachineNameDNSLabel(t *testing.T) {
+	shortName := "My_Machine.01"
+	if label := machineNameDNSLabel(shortName); label != "my-machine-01" {
+		t.Fatalf("Expected short name to be unchanged apart from slugging (got '%s')", label)
+	}
+
+	exactName := strings.Repeat("a", maxDNSLabelLength)
+	if label := machineNameDNSLabel(exactName); label != exactName {
+		t.Fatalf("Expected %d-character name to be unchanged (got '%s')", maxDNSLabelLength, label)
+	}
+
+	longName := strings.Repeat("abcdefghij-", 7)
+	label := machineNameDNSLabel(longName)
+	if len(label) != maxDNSLabelLength {
+		t.Fatalf("Expected label to be %d characters long (got %d: '%s')", maxDNSLabelLength, len(label), label)
+	}
+	if !dnsLabelPattern.MatchString(label) {
+		t.Fatalf("Label '%s' is not a valid RFC 1123 label", label)
+	}
+	if !strings.HasPrefix(label, "abcdefghij-abcdefghij-") {
+		t.Fatalf("Expected label to start with the machine name (got '%s')", label)
+	}
+}
+
+func TestMachineNameShort(t *testing.T) {
+	if name := machineNameShort("Web_01"); name != "web-01" {
+		t.Fatalf("Expected short name to be 'web-01' (got '%s')", name)
+	}
+
+	name := machineNameShort("Very-Long-Machine-Name-For-Production_Cluster_01")
+	if name != "very-lon-ad526c" {
+		t.Fatalf("Expected short name to be 'very-lon-ad526c' (got '%s')", name)
+	}
+
+	// Truncation must not leave a hyphen before the hash suffix.
+	name = machineNameShort("abcdefg-hijklmnop")
+	if len(name) > maxShortNameLength || strings.Contains(name, "--") || !dnsLabelPattern.MatchString(name) {
+		t.Fatalf("Invalid short name '%s'", name)
+	}
+}
+
+func TestShortenedMachineNamesAreUnique(t *testing.T) {
+	machineNames := []string{
+		"production-cluster-node-01",
+		"production-cluster-node-02",
+		"Production-Cluster-Node-01", // Same slug as the first name, but a different machine name
+	}
+
+	shortNames := make(map[string]string)
+	for _, machineName := range machineNames {
+		shortName := machineNameShort(machineName)
+		if len(shortName) > maxShortNameLength {
+			t.Fatalf("Short name '%s' is longer than %d characters", shortName, maxShortNameLength)
+		}
+		if !strings.HasPrefix(shortName, "producti-") {
+			t.Fatalf("Expected short name to start with the machine name (got '%s')", shortName)
+		}
+
+		if otherMachineName, ok := shortNames[shortName]; ok {
+			t.Fatalf("Machine names '%s' and '%s' have the same short name '%s'", otherMachineName, machineName, shortName)
+		}
+		shortNames[shortName] = machineName
+	}
+}
@@ -20,6 +20,26 @@ func main() {
 		return
 	}
 
+	if len(os.Args) >= 2 && os.Args[1] == "state-history" {
+		err := runStateHistoryCommand(os.Args[2:])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+
+		return
+	}
+
+	if len(os.Args) >= 2 && os.Args[1] == "inspect" {
+		err := runInspectCommand(os.Args[2:])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+
+		return
+	}
+
 	plugin.RegisterDriver(
 		&Driver{BaseDriver: &drivers.BaseDriver{
 			SSHUser: "root",
@@ -0,0 +1,110 @@
+package main
+
+/*
+ * Driver implementation (mapping of variable and output names)
+ * ------------------------------------------------------------
+ *
+ * Allows existing Terraform modules to be used without renaming their variables and outputs to match the driver's (dm_xxx) contract.
+ * For example, --terraform-variable-map dm_machine_name=name passes the value of dm_machine_name to Terraform as "name",
+ * and --terraform-output-map dm_machine_ip=public_ip reads the machine's IP address from the output "public_ip".
+ */
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/docker/machine/libmachine/log"
+	"github.com/tintoy/docker-machine-driver-terraform/terraform"
+)
+
+// Parse name mappings of the form "from=to".
+func parseNameMap(argumentName string, items []string) (map[string]string, error) {
+	nameMap := make(map[string]string)
+	mappedNames := make(map[string]string)
+	for _, item := range items {
+		fromAndTo := strings.SplitN(item, "=", 2)
+		if len(fromAndTo) != 2 || fromAndTo[0] == "" || fromAndTo[1] == "" {
+			return nil, fmt.Errorf("Invalid argument: %s '%s' must be of the form 'from=to'", argumentName, item)
+		}
+
+		from, to := fromAndTo[0], fromAndTo[1]
+		if _, ok := nameMap[from]; ok {
+			return nil, fmt.Errorf("Invalid argument: %s maps '%s' more than once", argumentName, from)
+		}
+		if otherFrom, ok := mappedNames[to]; ok {
+			return nil, fmt.Errorf("Invalid argument: %s maps both '%s' and '%s' to '%s'", argumentName, otherFrom, from, to)
+		}
+
+		nameMap[from] = to
+		mappedNames[to] = from
+	}
+
+	return nameMap, nil
+}
+
+// Rename variables according to the variable map (--terraform-variable-map).
+func (driver *Driver) applyVariableMap(variables terraform.ConfigVariables) terraform.ConfigVariables {
+	if len(driver.VariableMap) == 0 {
+		return variables
+	}
+
+	mappedVariables := make(terraform.ConfigVariables)
+	for variableName, variableValue := range variables {
+		if _, ok := driver.VariableMap[variableName]; ok {
+			continue
+		}
+
+		mappedVariables[variableName] = variableValue
+	}
+
+	fromNames := make([]string, 0, len(driver.VariableMap))
+	for from := range driver.VariableMap {
+		fromNames = append(fromNames, from)
+	}
+	sort.Strings(fromNames)
+
+	for _, from := range fromNames {
+		variableValue, ok := variables[from]
+		if !ok {
+			continue
+		}
+
+		to := driver.VariableMap[from]
+		if _, ok := mappedVariables[to]; ok {
+			log.Warnf("Variable '%s' is mapped to '%s', and replaces the value supplied for '%s'.", from, to, to)
+		}
+		mappedVariables[to] = variableValue
+	}
+
+	return mappedVariables
+}
+
+// Get the name of the Terraform output that supplies the specified contract output (e.g. dm_machine_ip).
+func (driver *Driver) getOutputName(contractOutputName string) string {
+	outputName, ok := driver.OutputMap[contractOutputName]
+	if !ok {
+		return contractOutputName
+	}
+
+	return outputName
+}
+
+// Validate the output map (only the driver's contract outputs can be mapped).
+func validateOutputMap(outputMap map[string]string) error {
+	for from := range outputMap {
+		isContractOutput := false
+		for _, item := range contractOutputs {
+			if item.Name == from {
+				isContractOutput = true
+
+				break
+			}
+		}
+		if !isContractOutput {
+			return fmt.Errorf("Invalid argument: --terraform-output-map '%s' is not an output used by the driver", from)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/tintoy/docker-machine-driver-terraform/terraform"
+)
+
+func TestParseNameMap(t *testing.T) {
+	nameMap, err := parseNameMap("--terraform-variable-map", []string{
+		"dm_machine_name=name",
+		"dm_ssh_user=admin_user",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedNameMap := map[string]string{
+		"dm_machine_name": "name",
+		"dm_ssh_user":     "admin_user",
+	}
+	if !reflect.DeepEqual(nameMap, expectedNameMap) {
+		t.Errorf("Expected %v (got %v)", expectedNameMap, nameMap)
+	}
+
+	invalidItems := map[string][]string{
+		"no separator":      {"dm_machine_name"},
+		"empty from":        {"=name"},
+		"empty to":          {"dm_machine_name="},
+		"duplicate from":    {"dm_machine_name=name", "dm_machine_name=hostname"},
+		"duplicate to":      {"dm_machine_name=name", "dm_machine_id=name"},
+		"valid then broken": {"dm_machine_name=name", "broken"},
+	}
+	for description, items := range invalidItems {
+		_, err = parseNameMap("--terraform-variable-map", items)
+		if err == nil {
+			t.Errorf("Expected an error for %s (%v)", description, items)
+		}
+	}
+}
+
+func TestApplyVariableMap(t *testing.T) {
+	variables := terraform.ConfigVariables{
+		"dm_machine_name": "web1",
+		"dm_ssh_user":     "ubuntu",
+		"region":          "syd",
+		"name":            "replaced",
+	}
+
+	driver := &Driver{
+		VariableMap: map[string]string{
+			"dm_machine_name": "name",
+			"dm_ssh_user":     "admin_user",
+			"dm_machine_id":   "id", // Not supplied, so not mapped
+		},
+	}
+	mappedVariables := driver.applyVariableMap(variables)
+
+	expectedVariables := terraform.ConfigVariables{
+		"name":       "web1",
+		"admin_user": "ubuntu",
+		"region":     "syd",
+	}
+	if !reflect.DeepEqual(mappedVariables, expectedVariables) {
+		t.Errorf("Expected %v (got %v)", expectedVariables, mappedVariables)
+	}
+
+	driver.VariableMap = nil
+	mappedVariables = driver.applyVariableMap(variables)
+	if !reflect.DeepEqual(mappedVariables, variables) {
+		t.Errorf("Expected variables to be unchanged without a variable map (got %v)", mappedVariables)
+	}
+}
+
+func TestGetOutputName(t *testing.T) {
+	driver := &Driver{
+		OutputMap: map[string]string{"dm_machine_ip": "public_ip"},
+	}
+
+	testCases := map[string]string{
+		"dm_machine_ip": "public_ip",
+		"dm_ssh_user":   "dm_ssh_user",
+	}
+	for contractOutputName, expectedOutputName := range testCases {
+		outputName := driver.getOutputName(contractOutputName)
+		if outputName != expectedOutputName {
+			t.Errorf("Expected output name for '%s' to be '%s' (got '%s')", contractOutputName, expectedOutputName, outputName)
+		}
+	}
+}
+
+func TestValidateOutputMap(t *testing.T) {
+	err := validateOutputMap(map[string]string{
+		"dm_machine_ip": "public_ip",
+		"dm_ssh_user":   "admin_user",
+	})
+	if err != nil {
+		t.Errorf("Unexpected error: %s", err.Error())
+	}
+
+	err = validateOutputMap(map[string]string{"dm_machine_name": "name"})
+	if err == nil {
+		t.Errorf("Expected an error when mapping an output that is not used by the driver")
+	}
+}
@@ -0,0 +1,31 @@
+package main
+
+/*
+ * Driver implementation (Terraform outputs)
+ * -----------------------------------------
+ */
+
+import (
+	"github.com/docker/machine/libmachine/log"
+	"github.com/tintoy/docker-machine-driver-terraform/terraform"
+)
+
+// Read outputs from the Terraform configuration.
+//
+// Outputs are read directly from Terraform state if possible, falling back to "terraform output" if required.
+func (driver *Driver) readOutputs() (terraform.Outputs, error) {
+	terraformer, err := driver.getTerraformer()
+	if err != nil {
+		return nil, err
+	}
+
+	outputs, err := terraformer.StateOutputs()
+	if err == nil && len(outputs) > 0 {
+		return outputs, nil
+	}
+	if err != nil {
+		log.Debugf("Unable to read outputs from Terraform state (%s); will use 'terraform output' instead.", err.Error())
+	}
+
+	return terraformer.Output()
+}
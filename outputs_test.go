@@ -0,0 +1,47 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/tintoy/docker-machine-driver-terraform/terraform"
+)
+
+func TestReadOutputsFallsBackToTerraformOutput(t *testing.T) {
+	testDir, err := ioutil.TempDir("", "outputs-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(testDir)
+
+	// A fake Terraform executable with no state, which supplies outputs via "terraform output".
+	executablePath := path.Join(testDir, "terraform")
+	err = ioutil.WriteFile(executablePath, []byte(`#!/bin/sh
+if [ "$1" = "output" ]; then
+  echo '{"dm_machine_ip": {"sensitive": false, "type": "string", "value": "10.0.0.7"}}'
+fi
+`), 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	driver := &Driver{
+		ConfigDir: testDir,
+		terraformer: &terraform.Terraformer{
+			ExecutablePath: executablePath,
+			ConfigDir:      testDir,
+			Redactor:       terraform.NewRedactor(),
+		},
+	}
+	outputs, err := driver.readOutputs()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	machineIP, err := outputs.GetString("dm_machine_ip")
+	if err != nil || machineIP != "10.0.0.7" {
+		t.Errorf("Expected output 'dm_machine_ip' to be '10.0.0.7' (got '%s', %v)", machineIP, err)
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+/*
+ * Driver implementation (redaction of sensitive values)
+ * -----------------------------------------------------
+ */
+
+import (
+	"github.com/docker/machine/libmachine/log"
+	"github.com/tintoy/docker-machine-driver-terraform/terraform"
+)
+
+// Built-in variables whose values are always sensitive.
+var builtInSensitiveVariables = []string{
+	"dm_onetime_password",
+}
+
+// Get the Redactor used to mask sensitive values before they are logged.
+func (driver *Driver) getRedactor() *terraform.Redactor {
+	if driver.redactor == nil {
+		driver.redactor = terraform.NewRedactor()
+	}
+
+	return driver.redactor
+}
+
+// Register the values of sensitive variables (built-in, or marked as sensitive by the user) with the driver's Redactor.
+func (driver *Driver) registerSensitiveVariables() {
+	redactor := driver.getRedactor()
+
+	for _, variableName := range builtInSensitiveVariables {
+		redactor.AddSecret(driver.ConfigVariables[variableName])
+	}
+	for _, variableName := range driver.SensitiveVariables {
+		variableValue := driver.ConfigVariables[variableName]
+		if stringValue, ok := variableValue.(string); ok && stringValue != "" && len(stringValue) < terraform.ShortSecretLength {
+			log.Warnf("The value of sensitive variable '%s' is very short; it will still be redacted, but so will any unrelated text that happens to contain it.", variableName)
+		}
+
+		redactor.AddSecret(variableValue)
+	}
+}
+
+// Redact sensitive values from the specified text.
+func (driver *Driver) redact(text string) string {
+	return driver.getRedactor().Redact(text)
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/tintoy/docker-machine-driver-terraform/terraform"
+)
+
+func TestRegisterSensitiveVariables(t *testing.T) {
+	driver := &Driver{
+		ConfigVariables: terraform.ConfigVariables{
+			"dm_onetime_password": "one-time-password",
+			"api_token":           "token-value",
+			"ssh_keys":            []interface{}{"ssh-rsa AAAA", "ssh-rsa BBBB"},
+			"region":              "ap-southeast-2",
+		},
+		SensitiveVariables: []string{"api_token", "ssh_keys", "undefined_variable"},
+	}
+	driver.registerSensitiveVariables()
+
+	testCases := map[string]string{
+		"password=one-time-password": "password=" + terraform.RedactedValue,
+		"token=token-value":          "token=" + terraform.RedactedValue,
+		"keys: ssh-rsa AAAA":         "keys: " + terraform.RedactedValue,
+		"key: ssh-rsa BBBB":          "key: " + terraform.RedactedValue,
+		"region=ap-southeast-2":      "region=ap-southeast-2",
+	}
+	for text, expectedText := range testCases {
+		redactedText := driver.redact(text)
+		if redactedText != expectedText {
+			t.Errorf("Expected '%s' to be redacted as '%s' (got '%s')", text, expectedText, redactedText)
+		}
+	}
+}
@@ -0,0 +1,371 @@
+package main
+
+/*
+ * Driver implementation (Terraform state history)
+ * -----------------------------------------------
+ *
+ * Before each operation that can modify Terraform state, a copy of the state is saved to <store>/state-history/<machine-name>.
+ * This is outside the machine's own directory, so snapshots survive "docker-machine rm" (which deletes that directory).
+ * Each snapshot is accompanied by a SHA-256 checksum file (in the same format as sha256sum) so it can be verified before it is restored.
+ */
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/docker/machine/libmachine/log"
+	"github.com/tintoy/docker-machine-driver-terraform/terraform"
+)
+
+const (
+	// The name of the directory (in the Docker Machine store) where state snapshots are kept.
+	stateHistoryDirName = "state-history"
+
+	// The default number of state snapshots to retain for each machine.
+	defaultStateHistoryLimit = 10
+
+	// The layout used for the timestamp portion of snapshot Ids.
+	stateSnapshotTimeLayout = "20060102T150405.000000000Z"
+
+	// The file extension for state snapshots.
+	stateSnapshotExtension = ".tfstate"
+
+	// The file extension for state snapshot checksums.
+	stateSnapshotChecksumExtension = ".sha256"
+)
+
+// A snapshot of a machine's Terraform state.
+type stateSnapshot struct {
+	// The snapshot Id (also the base name of the snapshot file).
+	ID string
+
+	// The name of the operation that the snapshot was taken before (e.g. apply, refresh, destroy).
+	Operation string
+
+	// The time (UTC) when the snapshot was taken.
+	Created time.Time
+
+	// The size (in bytes) of the snapshot.
+	Size int64
+
+	// The SHA-256 checksum recorded when the snapshot was taken.
+	Checksum string
+
+	// The full path of the snapshot file.
+	FileName string
+}
+
+// Verify that the snapshot's content matches the recorded checksum.
+func (snapshot *stateSnapshot) Verify() error {
+	if snapshot.Checksum == "" {
+		return fmt.Errorf("No checksum was recorded for state snapshot '%s'", snapshot.ID)
+	}
+
+	actualChecksum, err := checksumFile(snapshot.FileName)
+	if err != nil {
+		return err
+	}
+	if actualChecksum != snapshot.Checksum {
+		return fmt.Errorf("Checksum mismatch for state snapshot '%s' (expected '%s' but found '%s')",
+			snapshot.ID,
+			snapshot.Checksum,
+			actualChecksum,
+		)
+	}
+
+	return nil
+}
+
+// Snapshot the machine's Terraform state before performing the specified operation.
+func (driver *Driver) snapshotState(operation string) error {
+	if driver.StateHistoryLimit == 0 {
+		return nil // State history is disabled
+	}
+
+	localConfigDir, err := driver.getConfigDir()
+	if err != nil {
+		return err
+	}
+
+	historyDir, err := driver.getStateHistoryDir()
+	if err != nil {
+		return err
+	}
+
+	snapshot, err := saveStateSnapshot(localConfigDir, historyDir, operation)
+	if err != nil {
+		return fmt.Errorf("Unable to snapshot Terraform state before '%s': %s", operation, err.Error())
+	}
+	if snapshot == nil {
+		log.Debugf("No local Terraform state to snapshot before '%s'.", operation)
+
+		return nil
+	}
+
+	log.Debugf("Saved Terraform state snapshot '%s' (%d bytes, sha256:%s).",
+		snapshot.ID,
+		snapshot.Size,
+		snapshot.Checksum,
+	)
+
+	return pruneStateSnapshots(historyDir, driver.StateHistoryLimit)
+}
+
+// Get the directory where the machine's state snapshots are kept.
+func (driver *Driver) getStateHistoryDir() (string, error) {
+	return getStateHistoryDir(driver.StorePath, driver.MachineName)
+}
+
+// Get the directory where state snapshots are kept for the specified machine.
+//
+// This is deliberately outside the machine's own directory (which is deleted when the machine is removed).
+// The machine name must not contain path separators or "..", since it would otherwise be able to refer to a directory outside the state history directory.
+func getStateHistoryDir(storagePath string, machineName string) (string, error) {
+	if machineName == "" || machineName == "." || strings.Contains(machineName, "..") || strings.ContainsAny(machineName, `/\`) {
+		return "", fmt.Errorf("Invalid machine name '%s' (machine names cannot be empty, or contain path separators or '..')", machineName)
+	}
+
+	return path.Join(storagePath, stateHistoryDirName, machineName), nil
+}
+
+// Save a snapshot of the Terraform state in the specified configuration directory.
+//
+// Returns nil if there is no local state to snapshot.
+func saveStateSnapshot(configDir string, historyDir string, operation string) (*stateSnapshot, error) {
+	stateFile, err := os.Open(
+		path.Join(configDir, terraform.LocalStateFileName),
+	)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer stateFile.Close()
+
+	err = os.MkdirAll(historyDir, 0700 /* u=rwx,g=,o= */)
+	if err != nil {
+		return nil, err
+	}
+
+	created := time.Now().UTC()
+	snapshot := &stateSnapshot{
+		ID:        fmt.Sprintf("%s-%s", created.Format(stateSnapshotTimeLayout), operation),
+		Operation: operation,
+		Created:   created,
+	}
+	snapshot.FileName = path.Join(historyDir, snapshot.ID+stateSnapshotExtension)
+
+	snapshotFile, err := os.OpenFile(snapshot.FileName, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600 /* u=rw,g=,o= */)
+	if err != nil {
+		return nil, err
+	}
+	defer snapshotFile.Close()
+
+	hash := sha256.New()
+	snapshot.Size, err = io.Copy(
+		io.MultiWriter(snapshotFile, hash), stateFile,
+	)
+	if err != nil {
+		return nil, err
+	}
+	err = snapshotFile.Sync()
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot.Checksum = hex.EncodeToString(
+		hash.Sum(nil),
+	)
+	err = ioutil.WriteFile(
+		path.Join(historyDir, snapshot.ID+stateSnapshotChecksumExtension),
+		[]byte(fmt.Sprintf("%s  %s\n", snapshot.Checksum, path.Base(snapshot.FileName))),
+		0600, /* u=rw,g=,o= */
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return snapshot, nil
+}
+
+// List the state snapshots in the specified directory (oldest first).
+func listStateSnapshots(historyDir string) ([]stateSnapshot, error) {
+	entries, err := ioutil.ReadDir(historyDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var snapshots []stateSnapshot
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), stateSnapshotExtension) {
+			continue
+		}
+
+		snapshotID := strings.TrimSuffix(entry.Name(), stateSnapshotExtension)
+		idParts := strings.SplitN(snapshotID, "-", 2)
+		if len(idParts) != 2 {
+			continue // Not one of ours
+		}
+		created, err := time.Parse(stateSnapshotTimeLayout, idParts[0])
+		if err != nil {
+			continue // Not one of ours
+		}
+
+		snapshot := stateSnapshot{
+			ID:        snapshotID,
+			Operation: idParts[1],
+			Created:   created,
+			Size:      entry.Size(),
+			FileName:  path.Join(historyDir, entry.Name()),
+		}
+		snapshot.Checksum, err = readSnapshotChecksum(
+			path.Join(historyDir, snapshotID+stateSnapshotChecksumExtension),
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		snapshots = append(snapshots, snapshot)
+	}
+
+	// ReadDir sorts by name, and snapshot Ids start with a sortable timestamp.
+	return snapshots, nil
+}
+
+// Find the state snapshot with the specified Id.
+func findStateSnapshot(historyDir string, snapshotID string) (*stateSnapshot, error) {
+	snapshots, err := listStateSnapshots(historyDir)
+	if err != nil {
+		return nil, err
+	}
+
+	for index := range snapshots {
+		if snapshots[index].ID == snapshotID {
+			return &snapshots[index], nil
+		}
+	}
+
+	return nil, fmt.Errorf("State snapshot '%s' not found in '%s'", snapshotID, historyDir)
+}
+
+// Restore the specified state snapshot into the specified configuration directory.
+//
+// The snapshot's checksum is verified first, and the current state (if any) is itself snapshotted before being replaced.
+func restoreStateSnapshot(configDir string, historyDir string, snapshotID string) error {
+	snapshot, err := findStateSnapshot(historyDir, snapshotID)
+	if err != nil {
+		return err
+	}
+
+	err = snapshot.Verify()
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(configDir, 0700 /* u=rwx,g=,o= */)
+	if err != nil {
+		return err
+	}
+
+	preRestoreSnapshot, err := saveStateSnapshot(configDir, historyDir, "restore")
+	if err != nil {
+		return fmt.Errorf("Unable to snapshot current Terraform state before restore: %s", err.Error())
+	}
+	if preRestoreSnapshot != nil {
+		log.Infof("Current Terraform state saved as snapshot '%s'.", preRestoreSnapshot.ID)
+	}
+
+	snapshotData, err := ioutil.ReadFile(snapshot.FileName)
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file first, so we never leave a partially-written state file behind.
+	stateFilePath := path.Join(configDir, terraform.LocalStateFileName)
+	err = ioutil.WriteFile(stateFilePath+".restore", snapshotData, 0600 /* u=rw,g=,o= */)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(stateFilePath+".restore", stateFilePath)
+}
+
+// Remove the oldest state snapshots so that no more than the specified number are retained.
+func pruneStateSnapshots(historyDir string, limit int) error {
+	if limit <= 0 {
+		return nil
+	}
+
+	snapshots, err := listStateSnapshots(historyDir)
+	if err != nil {
+		return err
+	}
+
+	for len(snapshots) > limit {
+		snapshot := snapshots[0]
+		snapshots = snapshots[1:]
+
+		log.Debugf("Removing old Terraform state snapshot '%s'...", snapshot.ID)
+		err = os.Remove(snapshot.FileName)
+		if err != nil {
+			return err
+		}
+		err = os.Remove(
+			path.Join(historyDir, snapshot.ID+stateSnapshotChecksumExtension),
+		)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Read the checksum for a state snapshot.
+//
+// Returns an empty string if the checksum file does not exist.
+func readSnapshotChecksum(checksumFileName string) (string, error) {
+	checksumData, err := ioutil.ReadFile(checksumFileName)
+	if os.IsNotExist(err) {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+
+	checksumFields := strings.Fields(
+		string(checksumData),
+	)
+	if len(checksumFields) == 0 {
+		return "", nil
+	}
+
+	return checksumFields[0], nil
+}
+
+// Calculate the SHA-256 checksum of the specified file.
+func checksumFile(fileName string) (string, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	_, err = io.Copy(hash, file)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(
+		hash.Sum(nil),
+	), nil
+}
@@ -0,0 +1,155 @@
+package main
+
+/*
+ * Command-line interface (Terraform state history)
+ * ------------------------------------------------
+ *
+ * docker-machine-driver-terraform state-history list <machine-name>
+ * docker-machine-driver-terraform state-history [--target-dir DIR] restore <machine-name> <snapshot-id>
+ *
+ * Snapshots are still available after the machine has been removed; in that case, restore writes terraform.tfstate to --target-dir.
+ */
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"text/tabwriter"
+
+	"github.com/tintoy/docker-machine-driver-terraform/terraform"
+)
+
+// Run the "state-history" command.
+func runStateHistoryCommand(arguments []string) error {
+	commandFlags := flag.NewFlagSet("state-history", flag.ContinueOnError)
+	storagePath := commandFlags.String("storage-path", getDefaultMachineStoragePath(),
+		"The Docker Machine storage path (defaults to $MACHINE_STORAGE_PATH or ~/.docker/machine)",
+	)
+	targetDir := commandFlags.String("target-dir", "",
+		"The directory to restore terraform.tfstate into (defaults to the machine's Terraform configuration directory, or the current directory if the machine has been removed)",
+	)
+	commandFlags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage:")
+		fmt.Fprintln(os.Stderr, "  docker-machine-driver-terraform state-history [--storage-path PATH] list <machine-name>")
+		fmt.Fprintln(os.Stderr, "  docker-machine-driver-terraform state-history [--storage-path PATH] [--target-dir DIR] restore <machine-name> <snapshot-id>")
+		commandFlags.PrintDefaults()
+	}
+	err := commandFlags.Parse(arguments)
+	if err != nil {
+		return err
+	}
+
+	arguments = commandFlags.Args()
+	if len(arguments) < 2 {
+		commandFlags.Usage()
+
+		return errors.New("Insufficient arguments for state-history command")
+	}
+	if *storagePath == "" {
+		return errors.New("Unable to determine the Docker Machine storage path (please specify --storage-path)")
+	}
+
+	machineName := arguments[1]
+	historyDir, err := getStateHistoryDir(*storagePath, machineName)
+	if err != nil {
+		return err
+	}
+
+	switch arguments[0] {
+	case "list":
+		return listStateHistory(historyDir)
+	case "restore":
+		if len(arguments) != 3 {
+			commandFlags.Usage()
+
+			return errors.New("The state-history restore command requires a machine name and a snapshot Id")
+		}
+
+		restoreDir := *targetDir
+		if restoreDir == "" {
+			restoreDir, err = getDefaultRestoreDir(*storagePath, machineName)
+			if err != nil {
+				return err
+			}
+		}
+
+		err = restoreStateSnapshot(restoreDir, historyDir, arguments[2])
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Restored Terraform state for machine '%s' from snapshot '%s' to '%s'.\n",
+			machineName,
+			arguments[2],
+			path.Join(restoreDir, terraform.LocalStateFileName),
+		)
+
+		return nil
+	default:
+		commandFlags.Usage()
+
+		return fmt.Errorf("Unknown state-history command '%s'", arguments[0])
+	}
+}
+
+// Get the directory that a machine's state is restored into when no target directory is specified.
+//
+// This is the machine's Terraform configuration directory if the machine still exists; otherwise, it is the current directory.
+func getDefaultRestoreDir(storagePath string, machineName string) (string, error) {
+	configDir := path.Join(storagePath, "machines", machineName, "terraform-config")
+	_, err := os.Stat(configDir)
+	if err == nil {
+		return configDir, nil
+	} else if !os.IsNotExist(err) {
+		return "", err
+	}
+
+	return os.Getwd()
+}
+
+// Print the state snapshots in the specified directory.
+func listStateHistory(historyDir string) error {
+	snapshots, err := listStateSnapshots(historyDir)
+	if err != nil {
+		return err
+	}
+
+	output := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(output, "ID\tOPERATION\tCREATED\tSIZE\tCHECKSUM")
+	for _, snapshot := range snapshots {
+		checksumStatus := "OK"
+		if err := snapshot.Verify(); err != nil {
+			checksumStatus = "INVALID"
+		}
+
+		fmt.Fprintf(output, "%s\t%s\t%s\t%d\t%s\n",
+			snapshot.ID,
+			snapshot.Operation,
+			snapshot.Created.Local().Format("2006-01-02 15:04:05"),
+			snapshot.Size,
+			checksumStatus,
+		)
+	}
+
+	return output.Flush()
+}
+
+// Get the default Docker Machine storage path.
+func getDefaultMachineStoragePath() string {
+	storagePath := os.Getenv("MACHINE_STORAGE_PATH")
+	if storagePath != "" {
+		return storagePath
+	}
+
+	homeDir := os.Getenv("HOME")
+	if homeDir == "" {
+		homeDir = os.Getenv("USERPROFILE") // Windows
+	}
+	if homeDir == "" {
+		return ""
+	}
+
+	return path.Join(homeDir, ".docker", "machine")
+}
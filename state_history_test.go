@@ -0,0 +1,267 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/tintoy/docker-machine-driver-terraform/terraform"
+)
+
+// Create a configuration directory and a history directory for state history tests.
+func newStateHistoryTestDirs(t *testing.T) (testDir string, configDir string, historyDir string) {
+	testDir, err := ioutil.TempDir("", "state-history-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	configDir = path.Join(testDir, "config")
+	err = os.MkdirAll(configDir, 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return testDir, configDir, path.Join(testDir, "history")
+}
+
+// Write the local Terraform state in the specified configuration directory.
+func writeTestState(t *testing.T, configDir string, state string) {
+	err := ioutil.WriteFile(path.Join(configDir, terraform.LocalStateFileName), []byte(state), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSaveStateSnapshot(t *testing.T) {
+	testDir, configDir, historyDir := newStateHistoryTestDirs(t)
+	defer os.RemoveAll(testDir)
+
+	snapshot, err := saveStateSnapshot(configDir, historyDir, "apply")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snapshot != nil {
+		t.Fatalf("Expected no snapshot when there is no local state (got '%s')", snapshot.ID)
+	}
+
+	writeTestState(t, configDir, `{"serial": 1}`)
+	snapshot, err = saveStateSnapshot(configDir, historyDir, "apply")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snapshot == nil {
+		t.Fatal("Expected a snapshot")
+	}
+	if !strings.HasSuffix(snapshot.ID, "-apply") || snapshot.Operation != "apply" {
+		t.Errorf("Expected an 'apply' snapshot (got '%s')", snapshot.ID)
+	}
+	if snapshot.Size != int64(len(`{"serial": 1}`)) {
+		t.Errorf("Expected snapshot size %d (got %d)", len(`{"serial": 1}`), snapshot.Size)
+	}
+
+	checksumData, err := ioutil.ReadFile(path.Join(historyDir, snapshot.ID+stateSnapshotChecksumExtension))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedChecksumData := snapshot.Checksum + "  " + snapshot.ID + stateSnapshotExtension + "\n"
+	if string(checksumData) != expectedChecksumData {
+		t.Errorf("Expected checksum file '%s' (got '%s')", expectedChecksumData, string(checksumData))
+	}
+
+	snapshots, err := listStateSnapshots(historyDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshots) != 1 || snapshots[0].ID != snapshot.ID || snapshots[0].Checksum != snapshot.Checksum {
+		t.Fatalf("Expected to find snapshot '%s' (got %+v)", snapshot.ID, snapshots)
+	}
+	err = snapshots[0].Verify()
+	if err != nil {
+		t.Errorf("Unexpected error verifying snapshot: %s", err.Error())
+	}
+}
+
+func TestStateSnapshotChecksumMismatch(t *testing.T) {
+	testDir, configDir, historyDir := newStateHistoryTestDirs(t)
+	defer os.RemoveAll(testDir)
+
+	writeTestState(t, configDir, `{"serial": 1}`)
+	snapshot, err := saveStateSnapshot(configDir, historyDir, "apply")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Tamper with the snapshot after it was taken.
+	err = ioutil.WriteFile(snapshot.FileName, []byte(`{"serial": 2}`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	snapshot, err = findStateSnapshot(historyDir, snapshot.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = snapshot.Verify()
+	if err == nil || !strings.Contains(err.Error(), "Checksum mismatch") {
+		t.Errorf("Expected a checksum mismatch (got %v)", err)
+	}
+
+	err = restoreStateSnapshot(configDir, historyDir, snapshot.ID)
+	if err == nil {
+		t.Errorf("Expected restore to fail for a snapshot with a checksum mismatch")
+	}
+
+	// The checksum file is missing.
+	err = os.Remove(path.Join(historyDir, snapshot.ID+stateSnapshotChecksumExtension))
+	if err != nil {
+		t.Fatal(err)
+	}
+	snapshot, err = findStateSnapshot(historyDir, snapshot.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snapshot.Verify() == nil {
+		t.Errorf("Expected an error verifying a snapshot with no recorded checksum")
+	}
+}
+
+func TestPruneStateSnapshots(t *testing.T) {
+	testDir, configDir, historyDir := newStateHistoryTestDirs(t)
+	defer os.RemoveAll(testDir)
+
+	var snapshotIDs []string
+	for _, operation := range []string{"apply", "refresh", "apply", "destroy"} {
+		writeTestState(t, configDir, `{"operation": "`+operation+`"}`)
+		snapshot, err := saveStateSnapshot(configDir, historyDir, operation)
+		if err != nil {
+			t.Fatal(err)
+		}
+		snapshotIDs = append(snapshotIDs, snapshot.ID)
+	}
+
+	// Files that are not snapshots are left alone.
+	err := ioutil.WriteFile(path.Join(historyDir, "notes.tfstate"), []byte("{}"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = pruneStateSnapshots(historyDir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	snapshots, err := listStateSnapshots(historyDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshots) != 4 {
+		t.Fatalf("Expected no snapshots to be pruned when the limit is 0 (got %d snapshots)", len(snapshots))
+	}
+
+	err = pruneStateSnapshots(historyDir, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	snapshots, err = listStateSnapshots(historyDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshots) != 2 || snapshots[0].ID != snapshotIDs[2] || snapshots[1].ID != snapshotIDs[3] {
+		t.Fatalf("Expected the 2 newest snapshots to be retained (got %+v)", snapshots)
+	}
+
+	for _, prunedID := range snapshotIDs[:2] {
+		for _, extension := range []string{stateSnapshotExtension, stateSnapshotChecksumExtension} {
+			_, err = os.Stat(path.Join(historyDir, prunedID+extension))
+			if !os.IsNotExist(err) {
+				t.Errorf("Expected '%s%s' to be removed", prunedID, extension)
+			}
+		}
+	}
+	_, err = os.Stat(path.Join(historyDir, "notes.tfstate"))
+	if err != nil {
+		t.Errorf("Expected 'notes.tfstate' to be retained: %s", err.Error())
+	}
+}
+
+func TestRestoreStateSnapshot(t *testing.T) {
+	testDir, configDir, historyDir := newStateHistoryTestDirs(t)
+	defer os.RemoveAll(testDir)
+
+	writeTestState(t, configDir, `{"serial": 1}`)
+	snapshot, err := saveStateSnapshot(configDir, historyDir, "apply")
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTestState(t, configDir, `{"serial": 2}`)
+
+	err = restoreStateSnapshot(configDir, historyDir, snapshot.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	state, err := ioutil.ReadFile(path.Join(configDir, terraform.LocalStateFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(state) != `{"serial": 1}` {
+		t.Errorf("Expected the restored state to be '%s' (got '%s')", `{"serial": 1}`, string(state))
+	}
+
+	// The state that was replaced is itself snapshotted.
+	snapshots, err := listStateSnapshots(historyDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshots) != 2 || snapshots[1].Operation != "restore" {
+		t.Fatalf("Expected a 'restore' snapshot of the replaced state (got %+v)", snapshots)
+	}
+	replacedState, err := ioutil.ReadFile(snapshots[1].FileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(replacedState) != `{"serial": 2}` {
+		t.Errorf("Expected the replaced state to be '%s' (got '%s')", `{"serial": 2}`, string(replacedState))
+	}
+
+	err = restoreStateSnapshot(configDir, historyDir, "20190701T021345.000000000Z-apply")
+	if err == nil {
+		t.Errorf("Expected an error restoring a snapshot that does not exist")
+	}
+}
+
+func TestGetStateHistoryDir(t *testing.T) {
+	testCases := []struct {
+		machineName        string
+		expectedHistoryDir string
+	}{
+		{"web-server-1", "/store/state-history/web-server-1"},
+		{"web.server", "/store/state-history/web.server"},
+		{"", ""},
+		{".", ""},
+		{"..", ""},
+		{"../machines/other", ""},
+		{"web..server", ""},
+		{"machines/other", ""},
+		{`machines\other`, ""},
+	}
+	for _, testCase := range testCases {
+		historyDir, err := getStateHistoryDir("/store", testCase.machineName)
+		if testCase.expectedHistoryDir == "" {
+			if err == nil {
+				t.Errorf("Expected an error for machine name '%s' (got history directory '%s')", testCase.machineName, historyDir)
+			}
+
+			continue
+		}
+		if err != nil {
+			t.Errorf("Unexpected error for machine name '%s': %s", testCase.machineName, err.Error())
+
+			continue
+		}
+		if historyDir != testCase.expectedHistoryDir {
+			t.Errorf("Expected history directory '%s' for machine name '%s' (got '%s')", testCase.expectedHistoryDir, testCase.machineName, historyDir)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+/*
+ * Driver implementation (Terraform state locks)
+ * ---------------------------------------------
+ */
+
+import (
+	"time"
+
+	"github.com/docker/machine/libmachine/log"
+	"github.com/tintoy/docker-machine-driver-terraform/terraform"
+)
+
+// The default minimum age (in seconds) of a state lock before it can be forcibly released.
+const defaultForceUnlockAfter = 3600
+
+// Perform a Terraform operation, forcibly releasing a stale state lock (if permitted) and retrying once if required.
+func (driver *Driver) withStateLockRecovery(operation func() error) error {
+	err := operation()
+
+	lockError, ok := err.(*terraform.StateLockError)
+	if !ok {
+		return err
+	}
+
+	if !driver.ForceUnlock {
+		log.Warnf("If you are sure that no other Terraform operation is in progress, you can specify --terraform-force-unlock to forcibly release the lock.")
+
+		return err
+	}
+
+	if lockError.Lock.ID == "" {
+		log.Warnf("Cannot forcibly release Terraform state lock (unable to determine lock Id).")
+
+		return err
+	}
+
+	forceUnlockAfter := time.Duration(driver.ForceUnlockAfter) * time.Second
+	lockAge := lockError.Lock.Age()
+	if lockAge < forceUnlockAfter {
+		log.Warnf("Will not forcibly release Terraform state lock '%s' (lock is %s old, but must be at least %s old).",
+			lockError.Lock.ID,
+			lockAge.Truncate(time.Second),
+			forceUnlockAfter,
+		)
+
+		return err
+	}
+
+	terraformer, err := driver.getTerraformer()
+	if err != nil {
+		return err
+	}
+
+	log.Warnf("Forcibly releasing Terraform state lock '%s' (held by '%s')...",
+		lockError.Lock.ID,
+		lockError.Lock.Who,
+	)
+	err = terraformer.ForceUnlock(lockError.Lock.ID)
+	if err != nil {
+		return err
+	}
+
+	log.Infof("Retrying 'terraform %s'...", lockError.Command)
+
+	return operation()
+}
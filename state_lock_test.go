@@ -0,0 +1,137 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tintoy/docker-machine-driver-terraform/terraform"
+)
+
+func TestWithStateLockRecovery(t *testing.T) {
+	staleLock := terraform.StateLock{ID: "1234", Who: "ops@build-01", Created: time.Now().Add(-2 * time.Hour)}
+	recentLock := terraform.StateLock{ID: "1234", Who: "ops@build-01", Created: time.Now().Add(-time.Minute)}
+	otherError := errors.New("Execute Terraform [apply]: Failed (exit status 1)")
+
+	testCases := []struct {
+		name                string
+		forceUnlock         bool
+		forceUnlockScript   string
+		errors              []error
+		expectedError       bool
+		expectedOperations  int
+		expectedForceUnlock bool
+	}{
+		{
+			name:               "success",
+			forceUnlock:        true,
+			errors:             []error{nil},
+			expectedOperations: 1,
+		},
+		{
+			name:               "other error",
+			forceUnlock:        true,
+			errors:             []error{otherError},
+			expectedError:      true,
+			expectedOperations: 1,
+		},
+		{
+			name:               "force-unlock not enabled",
+			errors:             []error{&terraform.StateLockError{Command: "apply", Lock: staleLock}},
+			expectedError:      true,
+			expectedOperations: 1,
+		},
+		{
+			name:               "unknown lock Id",
+			forceUnlock:        true,
+			errors:             []error{&terraform.StateLockError{Command: "apply"}},
+			expectedError:      true,
+			expectedOperations: 1,
+		},
+		{
+			name:               "lock is too recent",
+			forceUnlock:        true,
+			errors:             []error{&terraform.StateLockError{Command: "apply", Lock: recentLock}},
+			expectedError:      true,
+			expectedOperations: 1,
+		},
+		{
+			name:                "stale lock is released and operation is retried",
+			forceUnlock:         true,
+			errors:              []error{&terraform.StateLockError{Command: "apply", Lock: staleLock}, nil},
+			expectedOperations:  2,
+			expectedForceUnlock: true,
+		},
+		{
+			name:                "operation is only retried once",
+			forceUnlock:         true,
+			errors:              []error{&terraform.StateLockError{Command: "apply", Lock: staleLock}, &terraform.StateLockError{Command: "apply", Lock: staleLock}},
+			expectedError:       true,
+			expectedOperations:  2,
+			expectedForceUnlock: true,
+		},
+		{
+			name:                "force-unlock fails",
+			forceUnlock:         true,
+			forceUnlockScript:   "exit 1",
+			errors:              []error{&terraform.StateLockError{Command: "apply", Lock: staleLock}},
+			expectedError:       true,
+			expectedOperations:  1,
+			expectedForceUnlock: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		testDir, err := ioutil.TempDir("", "state-lock-test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(testDir)
+
+		// A fake Terraform executable that records its arguments.
+		executablePath := path.Join(testDir, "terraform")
+		argumentsFile := path.Join(testDir, "args.log")
+		err = ioutil.WriteFile(executablePath, []byte(fmt.Sprintf("#!/bin/sh\necho \"$@\" >> '%s'\n%s\n", argumentsFile, testCase.forceUnlockScript)), 0700)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		driver := &Driver{
+			ConfigDir:        testDir,
+			ForceUnlock:      testCase.forceUnlock,
+			ForceUnlockAfter: defaultForceUnlockAfter,
+			terraformer: &terraform.Terraformer{
+				ExecutablePath: executablePath,
+				ConfigDir:      testDir,
+				Redactor:       terraform.NewRedactor(),
+			},
+		}
+
+		operations := 0
+		err = driver.withStateLockRecovery(func() error {
+			operationError := testCase.errors[operations]
+			operations++
+
+			return operationError
+		})
+		if testCase.expectedError && err == nil {
+			t.Errorf("%s: expected an error", testCase.name)
+		} else if !testCase.expectedError && err != nil {
+			t.Errorf("%s: unexpected error: %s", testCase.name, err.Error())
+		}
+		if operations != testCase.expectedOperations {
+			t.Errorf("%s: expected the operation to be performed %d time(s) (got %d)", testCase.name, testCase.expectedOperations, operations)
+		}
+
+		arguments, _ := ioutil.ReadFile(argumentsFile)
+		forceUnlocked := strings.TrimSpace(string(arguments)) == "force-unlock -force 1234"
+		if forceUnlocked != testCase.expectedForceUnlock {
+			t.Errorf("%s: expected force-unlock to be performed: %t (Terraform was invoked with '%s')", testCase.name, testCase.expectedForceUnlock, strings.TrimSpace(string(arguments)))
+		}
+	}
+}
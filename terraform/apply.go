@@ -5,12 +5,20 @@ import (
 )
 
 // Apply invokes Terraform's "apply" command.
+//
+// If the state is locked, the returned error will be a *StateLockError.
 func (terraformer *Terraformer) Apply() (success bool, err error) {
-	success, err = terraformer.RunStreamed("apply",
+	variableFileArguments, cleanup, err := terraformer.variableFileArguments()
+	if err != nil {
+		return
+	}
+	defer cleanup()
+
+	arguments := append([]string{
 		"-input=false", // non-interactive
 		"-no-color",
-		"-var-file=tfvars.json",
-	)
+	}, variableFileArguments...)
+	success, err = terraformer.runStreamedWithLock("apply", arguments...)
 	if err != nil {
 		return
 	}
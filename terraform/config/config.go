@@ -0,0 +1,325 @@
+// Package config reads the declarations (variables and outputs) in a Terraform configuration.
+//
+// Both the native syntax (HCL 1 or HCL 2, in .tf files) and the JSON syntax (.tf.json files) are supported.
+// Only declarations are read; the configuration is not otherwise validated (Terraform itself will do that).
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/tintoy/docker-machine-driver-terraform/terraform"
+)
+
+// Variable represents a variable declared by a Terraform configuration.
+type Variable struct {
+	// The variable name.
+	Name string
+
+	// The variable's type (e.g. "string", "list(string)"), or an empty string if no type was declared.
+	Type string
+
+	// The variable's default value (if any).
+	Default interface{}
+
+	// Does the variable have a default value?
+	HasDefault bool
+
+	// The variable's description (if any).
+	Description string
+
+	// Is the variable's value sensitive?
+	Sensitive bool
+
+	// The name of the file where the variable is declared.
+	FileName string
+
+	// The line (in FileName) where the variable is declared.
+	Line int
+}
+
+// Required determines whether a value must be supplied for the variable.
+func (variable *Variable) Required() bool {
+	return !variable.HasDefault
+}
+
+// Output represents an output declared by a Terraform configuration.
+type Output struct {
+	// The output name.
+	Name string
+
+	// The output's description (if any).
+	Description string
+
+	// Is the output's value sensitive?
+	Sensitive bool
+
+	// The name of the file where the output is declared.
+	FileName string
+
+	// The line (in FileName) where the output is declared.
+	Line int
+}
+
+// Module represents the declarations in a Terraform module (i.e. the configuration files in a single directory).
+type Module struct {
+	// The module directory.
+	Dir string
+
+	// The variables declared by the module (keyed by name).
+	Variables map[string]*Variable
+
+	// The outputs declared by the module (keyed by name).
+	Outputs map[string]*Output
+}
+
+// LoadModule reads the declarations from the configuration files (*.tf and *.tf.json) in the specified directory.
+//
+// Override files (override.tf, *_override.tf, and their JSON equivalents) are ignored.
+func LoadModule(moduleDir string) (*Module, error) {
+	entries, err := ioutil.ReadDir(moduleDir)
+	if err != nil {
+		return nil, err
+	}
+
+	module := &Module{
+		Dir:       moduleDir,
+		Variables: make(map[string]*Variable),
+		Outputs:   make(map[string]*Output),
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || isOverrideFile(entry.Name()) {
+			continue
+		}
+
+		switch {
+		case strings.HasSuffix(entry.Name(), ".tf"):
+			err = module.readNativeFile(path.Join(moduleDir, entry.Name()))
+		case strings.HasSuffix(entry.Name(), ".tf.json"):
+			err = module.readJSONFile(path.Join(moduleDir, entry.Name()))
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Unable to read Terraform configuration file '%s': %s", entry.Name(), err.Error())
+		}
+	}
+
+	return module, nil
+}
+
+// VariableNames gets the names of the module's variables (in alphabetical order).
+func (module *Module) VariableNames() []string {
+	names := make([]string, 0, len(module.Variables))
+	for name := range module.Variables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// OutputNames gets the names of the module's outputs (in alphabetical order).
+func (module *Module) OutputNames() []string {
+	names := make([]string, 0, len(module.Outputs))
+	for name := range module.Outputs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Read the declarations from a configuration file in native (HCL) syntax.
+func (module *Module) readNativeFile(fileName string) error {
+	source, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return err
+	}
+
+	blocks, err := scanBlocks(source)
+	if err != nil {
+		return err
+	}
+
+	for _, block := range blocks {
+		if len(block.Labels) != 1 {
+			continue
+		}
+
+		switch block.Type {
+		case "variable":
+			module.addNativeVariable(block, fileName)
+		case "output":
+			module.Outputs[block.Labels[0]] = &Output{
+				Name:        block.Labels[0],
+				Description: expressionToString(block.Attributes["description"]),
+				Sensitive:   block.Attributes["sensitive"] == "true",
+				FileName:    path.Base(fileName),
+				Line:        block.Line,
+			}
+		}
+	}
+
+	return nil
+}
+
+// Add a variable declared in native (HCL) syntax.
+func (module *Module) addNativeVariable(block rawBlock, fileName string) {
+	variable := &Variable{
+		Name:        block.Labels[0],
+		Description: expressionToString(block.Attributes["description"]),
+		Sensitive:   block.Attributes["sensitive"] == "true",
+		FileName:    path.Base(fileName),
+		Line:        block.Line,
+	}
+	if typeExpression, ok := block.Attributes["type"]; ok {
+		variable.Type = normalizeTypeExpression(
+			unquote(typeExpression),
+		)
+	}
+	if defaultExpression, ok := block.Attributes["default"]; ok && defaultExpression != "null" {
+		variable.HasDefault = true
+		variable.Default = terraform.ParseVariableValue(defaultExpression)
+	}
+
+	module.Variables[variable.Name] = variable
+}
+
+// A variable declaration in a configuration file in JSON syntax.
+type jsonVariable struct {
+	Type        string           `json:"type"`
+	Default     *json.RawMessage `json:"default"`
+	Description string           `json:"description"`
+	Sensitive   bool             `json:"sensitive"`
+}
+
+// An output declaration in a configuration file in JSON syntax.
+type jsonOutput struct {
+	Description string `json:"description"`
+	Sensitive   bool   `json:"sensitive"`
+}
+
+// Read the declarations from a configuration file in JSON syntax.
+func (module *Module) readJSONFile(fileName string) error {
+	source, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return err
+	}
+
+	var configuration map[string]json.RawMessage
+	err = json.Unmarshal(source, &configuration)
+	if err != nil {
+		return err
+	}
+
+	variableDeclarations, err := readJSONBlocks(configuration["variable"])
+	if err != nil {
+		return err
+	}
+	for name, declaration := range variableDeclarations {
+		var jsonDeclaration jsonVariable
+		err = json.Unmarshal(declaration, &jsonDeclaration)
+		if err != nil {
+			return fmt.Errorf("Invalid declaration for variable '%s': %s", name, err.Error())
+		}
+
+		variable := &Variable{
+			Name:        name,
+			Type:        normalizeTypeExpression(jsonDeclaration.Type),
+			Description: jsonDeclaration.Description,
+			Sensitive:   jsonDeclaration.Sensitive,
+			FileName:    path.Base(fileName),
+		}
+		if jsonDeclaration.Default != nil && string(*jsonDeclaration.Default) != "null" {
+			variable.HasDefault = true
+			variable.Default = terraform.ParseVariableValue(string(*jsonDeclaration.Default))
+		}
+
+		module.Variables[variable.Name] = variable
+	}
+
+	outputDeclarations, err := readJSONBlocks(configuration["output"])
+	if err != nil {
+		return err
+	}
+	for name, declaration := range outputDeclarations {
+		var jsonDeclaration jsonOutput
+		err = json.Unmarshal(declaration, &jsonDeclaration)
+		if err != nil {
+			return fmt.Errorf("Invalid declaration for output '%s': %s", name, err.Error())
+		}
+
+		module.Outputs[name] = &Output{
+			Name:        name,
+			Description: jsonDeclaration.Description,
+			Sensitive:   jsonDeclaration.Sensitive,
+			FileName:    path.Base(fileName),
+		}
+	}
+
+	return nil
+}
+
+// Read the blocks of a given type from a configuration file in JSON syntax.
+//
+// Blocks can be represented as a single object (keyed by block label) or an array of such objects.
+func readJSONBlocks(blocksJSON json.RawMessage) (map[string]json.RawMessage, error) {
+	blocks := make(map[string]json.RawMessage)
+	if len(blocksJSON) == 0 {
+		return blocks, nil
+	}
+
+	var blockList []map[string]json.RawMessage
+	err := json.Unmarshal(blocksJSON, &blockList)
+	if err != nil {
+		var blockMap map[string]json.RawMessage
+		err = json.Unmarshal(blocksJSON, &blockMap)
+		if err != nil {
+			return nil, err
+		}
+		blockList = append(blockList, blockMap)
+	}
+
+	for _, blockMap := range blockList {
+		for label, block := range blockMap {
+			blocks[label] = block
+		}
+	}
+
+	return blocks, nil
+}
+
+// Convert the source text of an expression to a string (if it represents one).
+func expressionToString(expression string) string {
+	if expression == "" {
+		return ""
+	}
+
+	value, ok := terraform.ParseVariableValue(expression).(string)
+	if !ok {
+		return expression
+	}
+
+	return value
+}
+
+// Normalise a type expression (e.g. "list( string )" becomes "list(string)").
+func normalizeTypeExpression(typeExpression string) string {
+	return strings.Join(
+		strings.Fields(typeExpression), "",
+	)
+}
+
+// Determine whether the specified file is an override file.
+func isOverrideFile(fileName string) bool {
+	baseName := strings.TrimSuffix(
+		strings.TrimSuffix(fileName, ".json"), ".tf",
+	)
+
+	return baseName == "override" || strings.HasSuffix(baseName, "_override")
+}
@@ -0,0 +1,403 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// A top-level block (e.g. variable "foo" { ... }) found in a Terraform configuration file.
+type rawBlock struct {
+	// The block type (e.g. "variable").
+	Type string
+
+	// The block's labels (e.g. the variable name).
+	Labels []string
+
+	// The source text of each of the block's attribute expressions, keyed by attribute name.
+	//
+	// Nested blocks (e.g. "validation") are skipped.
+	Attributes map[string]string
+
+	// The line number where the block starts.
+	Line int
+}
+
+// Scans just enough of the Terraform language (HCL 1 or HCL 2) to find top-level blocks and their attributes.
+//
+// Expressions are not parsed; the scanner only needs to know where each one ends (which means understanding brackets, strings, heredocs, and comments).
+type blockScanner struct {
+	source   []byte
+	position int
+}
+
+// Scan the top-level blocks in the specified configuration source.
+func scanBlocks(source []byte) ([]rawBlock, error) {
+	scanner := &blockScanner{
+		source: source,
+	}
+
+	var blocks []rawBlock
+	for {
+		scanner.skipSpaceAndComments(true)
+		if scanner.atEnd() {
+			break
+		}
+
+		blockLine := scanner.line()
+		blockType := scanner.readIdentifier()
+		if blockType == "" {
+			return nil, scanner.errorf("Unexpected character '%c'", scanner.current())
+		}
+
+		var labels []string
+		for {
+			scanner.skipSpaceAndComments(false)
+			if scanner.current() == '"' {
+				label, err := scanner.readQuotedString()
+				if err != nil {
+					return nil, err
+				}
+				labels = append(labels, label)
+			} else if isIdentifierStart(scanner.current()) {
+				labels = append(labels, scanner.readIdentifier())
+			} else {
+				break
+			}
+		}
+
+		switch scanner.current() {
+		case '{':
+			attributes, err := scanner.readBody()
+			if err != nil {
+				return nil, err
+			}
+
+			blocks = append(blocks, rawBlock{
+				Type:       blockType,
+				Labels:     labels,
+				Attributes: attributes,
+				Line:       blockLine,
+			})
+		case '=':
+			scanner.position++
+			scanner.readExpression() // Top-level attributes are ignored.
+		default:
+			return nil, scanner.errorf("Expected '{' after '%s'", blockType)
+		}
+	}
+
+	return blocks, nil
+}
+
+// Read the attributes from a block body (the scanner must be positioned on the opening brace).
+func (scanner *blockScanner) readBody() (map[string]string, error) {
+	bodyLine := scanner.line()
+	scanner.position++ // Opening brace
+
+	attributes := make(map[string]string)
+	for {
+		scanner.skipSpaceAndComments(true)
+		if scanner.atEnd() {
+			return nil, fmt.Errorf("line %d: Block is not closed", bodyLine)
+		}
+
+		switch scanner.current() {
+		case '}':
+			scanner.position++
+
+			return attributes, nil
+		case ',':
+			scanner.position++ // HCL 1 permits commas between attributes
+
+			continue
+		}
+
+		var name string
+		if scanner.current() == '"' {
+			var err error
+			name, err = scanner.readQuotedString()
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			name = scanner.readIdentifier()
+		}
+		if name == "" {
+			return nil, scanner.errorf("Unexpected character '%c'", scanner.current())
+		}
+
+		scanner.skipSpaceAndComments(false)
+		if scanner.current() == '=' || scanner.current() == ':' {
+			scanner.position++
+			attributes[name] = scanner.readExpression()
+
+			continue
+		}
+
+		// A nested block; skip its labels and body.
+		for !scanner.atEnd() && scanner.current() != '{' && scanner.current() != '\n' {
+			if scanner.current() == '"' {
+				scanner.skipQuotedString()
+			} else {
+				scanner.position++
+			}
+		}
+		if scanner.current() != '{' {
+			return nil, scanner.errorf("Expected '=' or '{' after '%s'", name)
+		}
+		scanner.skipBalanced()
+	}
+}
+
+// Read the source text of an expression (up to the end of the line, a comma, or the end of the enclosing block).
+func (scanner *blockScanner) readExpression() string {
+	start := scanner.position
+	depth := 0
+
+	for !scanner.atEnd() {
+		switch character := scanner.current(); {
+		case character == '"':
+			scanner.skipQuotedString()
+
+			continue
+		case scanner.startsWith("<<"):
+			scanner.skipHeredoc()
+
+			continue
+		case scanner.startsWith("/*"):
+			scanner.skipBlockComment()
+
+			continue
+		case character == '#' || scanner.startsWith("//"):
+			if depth == 0 {
+				return strings.TrimSpace(string(scanner.source[start:scanner.position]))
+			}
+			scanner.skipLineComment()
+
+			continue
+		case character == '(' || character == '[' || character == '{':
+			depth++
+		case character == ')' || character == ']' || character == '}':
+			if depth == 0 {
+				return strings.TrimSpace(string(scanner.source[start:scanner.position]))
+			}
+			depth--
+		case character == '\n' || character == ',':
+			if depth == 0 {
+				return strings.TrimSpace(string(scanner.source[start:scanner.position]))
+			}
+		}
+
+		scanner.position++
+	}
+
+	return strings.TrimSpace(string(scanner.source[start:scanner.position]))
+}
+
+// Skip a bracketed section of source (the scanner must be positioned on the opening bracket).
+func (scanner *blockScanner) skipBalanced() {
+	depth := 0
+	for !scanner.atEnd() {
+		switch character := scanner.current(); {
+		case character == '"':
+			scanner.skipQuotedString()
+
+			continue
+		case scanner.startsWith("<<"):
+			scanner.skipHeredoc()
+
+			continue
+		case scanner.startsWith("/*"):
+			scanner.skipBlockComment()
+
+			continue
+		case character == '#' || scanner.startsWith("//"):
+			scanner.skipLineComment()
+
+			continue
+		case character == '(' || character == '[' || character == '{':
+			depth++
+		case character == ')' || character == ']' || character == '}':
+			depth--
+			if depth == 0 {
+				scanner.position++
+
+				return
+			}
+		}
+
+		scanner.position++
+	}
+}
+
+// Read a quoted string, and return its (unescaped) value.
+func (scanner *blockScanner) readQuotedString() (string, error) {
+	start := scanner.position
+	stringLine := scanner.line()
+	if !scanner.skipQuotedString() {
+		return "", fmt.Errorf("line %d: String is not terminated", stringLine)
+	}
+
+	return unquote(string(scanner.source[start:scanner.position])), nil
+}
+
+// Skip a quoted string (including any template interpolations it contains).
+//
+// Returns false if the string is not terminated.
+func (scanner *blockScanner) skipQuotedString() bool {
+	scanner.position++ // Opening quote
+	for !scanner.atEnd() {
+		switch {
+		case scanner.current() == '\\':
+			scanner.position += 2
+
+			continue
+		case scanner.current() == '"':
+			scanner.position++
+
+			return true
+		case scanner.current() == '\n':
+			return false
+		case scanner.startsWith("${") || scanner.startsWith("%{"):
+			scanner.position++
+			scanner.skipBalanced()
+
+			continue
+		}
+
+		scanner.position++
+	}
+
+	return false
+}
+
+// Skip a heredoc (e.g. <<EOF ... EOF, or <<-EOF ... EOF).
+func (scanner *blockScanner) skipHeredoc() {
+	scanner.position += 2
+	if scanner.current() == '-' {
+		scanner.position++
+	}
+	marker := scanner.readIdentifier()
+	if marker == "" {
+		return // Not a heredoc
+	}
+
+	scanner.skipLineComment() // Rest of the opening line
+	for !scanner.atEnd() {
+		lineStart := scanner.position
+		scanner.skipLineComment()
+		line := scanner.source[lineStart:scanner.position]
+		if strings.TrimSpace(string(line)) == marker {
+			// Stop before the closing line's line break (which ends the expression that contains the heredoc).
+			if bytes.HasSuffix(line, []byte("\n")) {
+				scanner.position--
+			}
+
+			return
+		}
+	}
+}
+
+// Skip whitespace and comments (optionally including line breaks).
+func (scanner *blockScanner) skipSpaceAndComments(includeNewLines bool) {
+	for !scanner.atEnd() {
+		switch character := scanner.current(); {
+		case character == ' ' || character == '\t' || character == '\r':
+			scanner.position++
+		case character == '\n':
+			if !includeNewLines {
+				return
+			}
+			scanner.position++
+		case character == '#' || scanner.startsWith("//"):
+			if !includeNewLines {
+				return
+			}
+			scanner.skipLineComment()
+		case scanner.startsWith("/*"):
+			scanner.skipBlockComment()
+		default:
+			return
+		}
+	}
+}
+
+// Skip to the start of the next line.
+func (scanner *blockScanner) skipLineComment() {
+	lineEnd := bytes.IndexByte(scanner.source[scanner.position:], '\n')
+	if lineEnd == -1 {
+		scanner.position = len(scanner.source)
+	} else {
+		scanner.position += lineEnd + 1
+	}
+}
+
+// Skip a block comment (/* ... */).
+func (scanner *blockScanner) skipBlockComment() {
+	commentEnd := bytes.Index(scanner.source[scanner.position+2:], []byte("*/"))
+	if commentEnd == -1 {
+		scanner.position = len(scanner.source)
+	} else {
+		scanner.position += commentEnd + 4
+	}
+}
+
+// Read an identifier (returns an empty string if the scanner is not positioned on one).
+func (scanner *blockScanner) readIdentifier() string {
+	start := scanner.position
+	if scanner.atEnd() || !isIdentifierStart(scanner.current()) {
+		return ""
+	}
+	for !scanner.atEnd() && isIdentifierPart(scanner.current()) {
+		scanner.position++
+	}
+
+	return string(scanner.source[start:scanner.position])
+}
+
+func (scanner *blockScanner) atEnd() bool {
+	return scanner.position >= len(scanner.source)
+}
+
+func (scanner *blockScanner) current() byte {
+	if scanner.atEnd() {
+		return 0
+	}
+
+	return scanner.source[scanner.position]
+}
+
+func (scanner *blockScanner) startsWith(prefix string) bool {
+	return bytes.HasPrefix(scanner.source[scanner.position:], []byte(prefix))
+}
+
+// The (1-based) line number of the scanner's current position.
+func (scanner *blockScanner) line() int {
+	return bytes.Count(scanner.source[:scanner.position], []byte("\n")) + 1
+}
+
+func (scanner *blockScanner) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("line %d: %s", scanner.line(), fmt.Sprintf(format, args...))
+}
+
+func isIdentifierStart(character byte) bool {
+	return character == '_' || (character >= 'a' && character <= 'z') || (character >= 'A' && character <= 'Z')
+}
+
+func isIdentifierPart(character byte) bool {
+	return isIdentifierStart(character) || character == '-' || (character >= '0' && character <= '9')
+}
+
+// Remove the quotes (and escapes) from a quoted string.
+//
+// Strings that Go cannot unquote (e.g. because they contain HCL-specific escapes) simply have their quotes removed.
+func unquote(quoted string) string {
+	unquoted, err := strconv.Unquote(quoted)
+	if err != nil {
+		return strings.TrimSuffix(strings.TrimPrefix(quoted, `"`), `"`)
+	}
+
+	return unquoted
+}
@@ -0,0 +1,124 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestScanBlocks(t *testing.T) {
+	testCases := []struct {
+		description        string
+		source             string
+		expectedAttributes map[string]string
+	}{
+		{
+			"heredoc followed by another attribute",
+			"variable \"region\" {\n  description = <<EOT\nThe target region\nEOT\n  default = \"syd\"\n}\n",
+			map[string]string{
+				"description": "<<EOT\nThe target region\nEOT",
+				"default":     `"syd"`,
+			},
+		},
+		{
+			"indented heredoc followed by other attributes",
+			"variable \"region\" {\n  description = <<-EOT\n    The target region\n    EOT\n  type    = string\n  default = \"syd\"\n}\n",
+			map[string]string{
+				"description": "<<-EOT\n    The target region\n    EOT",
+				"type":        "string",
+				"default":     `"syd"`,
+			},
+		},
+		{
+			"heredoc at the end of the block",
+			"variable \"region\" {\n  default = 1\n  description = <<EOT\nEOT is not the end\nEOT\n}\n",
+			map[string]string{
+				"default":     "1",
+				"description": "<<EOT\nEOT is not the end\nEOT",
+			},
+		},
+		{
+			"heredoc at the end of the file",
+			"variable \"region\" {\n  description = <<EOT\nhi\nEOT",
+			nil,
+		},
+		{
+			"nested block",
+			"variable \"size\" {\n  type = number\n  validation {\n    condition     = var.size > 0\n    error_message = \"Size must be positive.\"\n  }\n  default = 3\n}\n",
+			map[string]string{
+				"type":    "number",
+				"default": "3",
+			},
+		},
+		{
+			"comments",
+			"variable \"size\" {\n  # The size\n  type = number // A number\n  /* default = 1 */\n  default = /* three */ 3 # Not 4\n}\n",
+			map[string]string{
+				"type":    "number",
+				"default": "/* three */ 3",
+			},
+		},
+		{
+			"HCL 1 commas and colons",
+			"variable \"zones\" {\n  type = \"list\", default: [\"a\", \"b\"]\n}\n",
+			map[string]string{
+				"type":    `"list"`,
+				"default": `["a", "b"]`,
+			},
+		},
+	}
+	for _, testCase := range testCases {
+		blocks, err := scanBlocks([]byte(testCase.source))
+		if testCase.expectedAttributes == nil {
+			if err == nil {
+				t.Errorf("%s: expected an error", testCase.description)
+			}
+
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", testCase.description, err.Error())
+
+			continue
+		}
+		if len(blocks) != 1 {
+			t.Errorf("%s: expected 1 block (got %d)", testCase.description, len(blocks))
+
+			continue
+		}
+		if !reflect.DeepEqual(blocks[0].Attributes, testCase.expectedAttributes) {
+			t.Errorf("%s: expected attributes %q (got %q)", testCase.description, testCase.expectedAttributes, blocks[0].Attributes)
+		}
+	}
+}
+
+func TestScanBlocksAfterHeredoc(t *testing.T) {
+	blocks, err := scanBlocks([]byte(`
+variable "region" {
+  description = <<EOT
+The target region
+EOT
+}
+
+variable "size" {
+  default = 3
+}
+
+output "ip" {
+  value = <<-EOT
+    ${aws_instance.machine.public_ip}
+  EOT
+}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedBlocks := []rawBlock{
+		{Type: "variable", Labels: []string{"region"}, Attributes: map[string]string{"description": "<<EOT\nThe target region\nEOT"}, Line: 2},
+		{Type: "variable", Labels: []string{"size"}, Attributes: map[string]string{"default": "3"}, Line: 8},
+		{Type: "output", Labels: []string{"ip"}, Attributes: map[string]string{"value": "<<-EOT\n    ${aws_instance.machine.public_ip}\n  EOT"}, Line: 12},
+	}
+	if !reflect.DeepEqual(blocks, expectedBlocks) {
+		t.Errorf("Expected blocks %+v (got %+v)", expectedBlocks, blocks)
+	}
+}
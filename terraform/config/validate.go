@@ -0,0 +1,274 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// VariableProblemKind represents a kind of problem with the variables supplied for a configuration.
+type VariableProblemKind int
+
+const (
+	// UnknownVariable indicates that a value was supplied for a variable the configuration does not declare.
+	UnknownVariable VariableProblemKind = iota
+
+	// MissingVariable indicates that no value was supplied for a required variable.
+	MissingVariable
+
+	// VariableTypeMismatch indicates that the value supplied for a variable does not match its declared type.
+	VariableTypeMismatch
+)
+
+// VariableProblem represents a problem with the variables supplied for a configuration.
+type VariableProblem struct {
+	// The kind of problem.
+	Kind VariableProblemKind
+
+	// The name of the variable.
+	Variable string
+
+	// A description of the problem.
+	Message string
+}
+
+// ValidateVariables checks the supplied variable values against the module's variable declarations.
+//
+// Problems are returned in order of variable name.
+func (module *Module) ValidateVariables(values map[string]interface{}) []VariableProblem {
+	var problems []VariableProblem
+
+	for _, name := range sortedKeys(values) {
+		variable, ok := module.Variables[name]
+		if !ok {
+			message := fmt.Sprintf("Variable '%s' is not declared by the Terraform configuration", name)
+			suggestion := module.SuggestVariable(name)
+			if suggestion != "" {
+				message += fmt.Sprintf(" (did you mean '%s'?)", suggestion)
+			}
+
+			problems = append(problems, VariableProblem{
+				Kind:     UnknownVariable,
+				Variable: name,
+				Message:  message,
+			})
+
+			continue
+		}
+
+		if !IsValueOfType(values[name], variable.Type) {
+			problems = append(problems, VariableProblem{
+				Kind:     VariableTypeMismatch,
+				Variable: name,
+				Message: fmt.Sprintf("The value supplied for variable '%s' (%s) is not compatible with its declared type '%s' (%s)",
+					name,
+					describeValue(values[name]),
+					variable.Type,
+					variable.location(),
+				),
+			})
+		}
+	}
+
+	for _, name := range module.VariableNames() {
+		variable := module.Variables[name]
+		if _, ok := values[name]; ok || !variable.Required() {
+			continue
+		}
+
+		problems = append(problems, VariableProblem{
+			Kind:     MissingVariable,
+			Variable: name,
+			Message:  fmt.Sprintf("Required variable '%s' (%s) has not been supplied", name, variable.location()),
+		})
+	}
+
+	return problems
+}
+
+// SuggestVariable finds the declared variable whose name is most similar to the specified name.
+//
+// Returns an empty string if no declared variable is similar enough.
+func (module *Module) SuggestVariable(name string) string {
+	maxDistance := len(name) / 3
+	if maxDistance < 2 {
+		maxDistance = 2
+	}
+
+	suggestion := ""
+	bestDistance := maxDistance + 1
+	for _, candidate := range module.VariableNames() {
+		distance := editDistance(
+			strings.ToLower(name), strings.ToLower(candidate),
+		)
+		if distance < bestDistance {
+			suggestion = candidate
+			bestDistance = distance
+		}
+	}
+
+	return suggestion
+}
+
+// IsValueOfType determines whether a value is (obviously) compatible with the specified Terraform type.
+//
+// Values are only rejected if Terraform could not convert them to the specified type (e.g. the string "3" is a valid number).
+// Unknown or unspecified types accept any value.
+func IsValueOfType(value interface{}, typeExpression string) bool {
+	if value == nil {
+		return true
+	}
+
+	typeName, elementType := splitTypeExpression(typeExpression)
+	switch typeName {
+	case "string":
+		return !isCollection(value)
+	case "number":
+		switch typedValue := value.(type) {
+		case int, int64, float64, json.Number:
+			return true
+		case string:
+			_, err := strconv.ParseFloat(typedValue, 64)
+
+			return err == nil
+		default:
+			return false
+		}
+	case "bool":
+		switch typedValue := value.(type) {
+		case bool:
+			return true
+		case string:
+			return typedValue == "true" || typedValue == "false"
+		default:
+			return false
+		}
+	case "list", "set", "tuple":
+		elements, ok := value.([]interface{})
+		if !ok {
+			return false
+		}
+		if typeName == "tuple" {
+			return true
+		}
+		for _, element := range elements {
+			if !IsValueOfType(element, elementType) {
+				return false
+			}
+		}
+
+		return true
+	case "map", "object":
+		elements, ok := value.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		if typeName == "object" {
+			return true
+		}
+		for _, element := range elements {
+			if !IsValueOfType(element, elementType) {
+				return false
+			}
+		}
+
+		return true
+	default:
+		return true // e.g. "any"
+	}
+}
+
+// Split a type expression into the type name and element type (e.g. "list(string)" becomes "list" and "string").
+func splitTypeExpression(typeExpression string) (typeName string, elementType string) {
+	openParen := strings.Index(typeExpression, "(")
+	if openParen == -1 || !strings.HasSuffix(typeExpression, ")") {
+		return typeExpression, ""
+	}
+
+	return typeExpression[:openParen], typeExpression[openParen+1 : len(typeExpression)-1]
+}
+
+// Get a description of the variable's location (for use in messages).
+func (variable *Variable) location() string {
+	if variable.Line == 0 {
+		return fmt.Sprintf("declared in '%s'", variable.FileName)
+	}
+
+	return fmt.Sprintf("declared in '%s' at line %d", variable.FileName, variable.Line)
+}
+
+// Describe the kind of a value (for use in messages).
+func describeValue(value interface{}) string {
+	switch value.(type) {
+	case []interface{}:
+		return "a list"
+	case map[string]interface{}:
+		return "a map"
+	case bool:
+		return "a boolean"
+	case string:
+		return "a string"
+	default:
+		return "a number"
+	}
+}
+
+func isCollection(value interface{}) bool {
+	switch value.(type) {
+	case []interface{}, map[string]interface{}:
+		return true
+	default:
+		return false
+	}
+}
+
+func sortedKeys(values map[string]interface{}) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// Calculate the Levenshtein distance between two strings.
+func editDistance(first string, second string) int {
+	previousRow := make([]int, len(second)+1)
+	currentRow := make([]int, len(second)+1)
+	for index := range previousRow {
+		previousRow[index] = index
+	}
+
+	for firstIndex := 1; firstIndex <= len(first); firstIndex++ {
+		currentRow[0] = firstIndex
+		for secondIndex := 1; secondIndex <= len(second); secondIndex++ {
+			substitutionCost := 1
+			if first[firstIndex-1] == second[secondIndex-1] {
+				substitutionCost = 0
+			}
+
+			currentRow[secondIndex] = minInt(
+				previousRow[secondIndex]+1,                  // Deletion
+				currentRow[secondIndex-1]+1,                 // Insertion
+				previousRow[secondIndex-1]+substitutionCost, // Substitution
+			)
+		}
+		previousRow, currentRow = currentRow, previousRow
+	}
+
+	return previousRow[len(second)]
+}
+
+func minInt(values ...int) int {
+	result := values[0]
+	for _, value := range values[1:] {
+		if value < result {
+			result = value
+		}
+	}
+
+	return result
+}
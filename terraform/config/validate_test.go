@@ -0,0 +1,191 @@
+package config
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+)
+
+func TestIsValueOfType(t *testing.T) {
+	testCases := []struct {
+		value          interface{}
+		typeExpression string
+		expectedValid  bool
+	}{
+		{"syd", "string", true},
+		{int64(3), "string", true},
+		{[]interface{}{"a"}, "string", false},
+		{int64(3), "number", true},
+		{json.Number("3.5"), "number", true},
+		{"3", "number", true},
+		{"three", "number", false},
+		{true, "bool", true},
+		{"false", "bool", true},
+		{"yes", "bool", false},
+		{[]interface{}{"a", "b"}, "list(string)", true},
+		{[]interface{}{"a", []interface{}{"b"}}, "list(string)", false},
+		{[]interface{}{"1", int64(2)}, "list(number)", true},
+		{[]interface{}{"a", int64(2)}, "tuple([string, number])", true},
+		{"a", "list(string)", false},
+		{map[string]interface{}{"owner": "ops"}, "map(string)", true},
+		{map[string]interface{}{"count": "many"}, "map(number)", false},
+		{map[string]interface{}{"count": int64(1)}, "object({count = number})", true},
+		{[]interface{}{"a"}, "map(string)", false},
+		{nil, "number", true},
+		{[]interface{}{"a"}, "any", true},
+		{"syd", "", true},
+	}
+	for _, testCase := range testCases {
+		valid := IsValueOfType(testCase.value, testCase.typeExpression)
+		if valid != testCase.expectedValid {
+			t.Errorf("Expected IsValueOfType(%#v, '%s') to be %t", testCase.value, testCase.typeExpression, testCase.expectedValid)
+		}
+	}
+}
+
+func TestValidateVariables(t *testing.T) {
+	module := &Module{
+		Variables: map[string]*Variable{
+			"region":   {Name: "region", Type: "string", FileName: "variables.tf", Line: 1},
+			"size":     {Name: "size", Type: "number", HasDefault: true, FileName: "variables.tf", Line: 5},
+			"password": {Name: "password", Type: "string", FileName: "variables.tf", Line: 9},
+		},
+	}
+
+	problems := module.ValidateVariables(map[string]interface{}{
+		"regoin": "syd",
+		"size":   "large",
+	})
+
+	expectedProblems := []struct {
+		kind     VariableProblemKind
+		variable string
+	}{
+		{UnknownVariable, "regoin"},
+		{VariableTypeMismatch, "size"},
+		{MissingVariable, "password"},
+		{MissingVariable, "region"},
+	}
+	if len(problems) != len(expectedProblems) {
+		t.Fatalf("Expected %d problems (got %#v)", len(expectedProblems), problems)
+	}
+	for index, expectedProblem := range expectedProblems {
+		problem := problems[index]
+		if problem.Kind != expectedProblem.kind || problem.Variable != expectedProblem.variable {
+			t.Errorf("Expected problem %d to be kind %d for '%s' (got kind %d for '%s')",
+				index, expectedProblem.kind, expectedProblem.variable, problem.Kind, problem.Variable,
+			)
+		}
+	}
+
+	expectedMessage := "Variable 'regoin' is not declared by the Terraform configuration (did you mean 'region'?)"
+	if problems[0].Message != expectedMessage {
+		t.Errorf("Expected message '%s' (got '%s')", expectedMessage, problems[0].Message)
+	}
+
+	problems = module.ValidateVariables(map[string]interface{}{
+		"region":   "syd",
+		"password": "hunter2",
+	})
+	if len(problems) != 0 {
+		t.Errorf("Expected no problems (got %#v)", problems)
+	}
+}
+
+func TestSuggestVariable(t *testing.T) {
+	module := &Module{
+		Variables: map[string]*Variable{
+			"region":        {Name: "region"},
+			"instance_type": {Name: "instance_type"},
+		},
+	}
+
+	testCases := map[string]string{
+		"regoin":       "region",
+		"Region":       "region",
+		"instanceType": "instance_type",
+		"zone":         "",
+	}
+	for name, expectedSuggestion := range testCases {
+		suggestion := module.SuggestVariable(name)
+		if suggestion != expectedSuggestion {
+			t.Errorf("Expected suggestion for '%s' to be '%s' (got '%s')", name, expectedSuggestion, suggestion)
+		}
+	}
+}
+
+func TestLoadModule(t *testing.T) {
+	moduleDir, err := ioutil.TempDir("", "config-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(moduleDir)
+
+	files := map[string]string{
+		"variables.tf": `
+variable "region" {
+  type        = string
+  description = "The target region"
+}
+
+variable "zones" {
+  type    = list(string)
+  default = ["a", "b"]
+}
+
+variable "ami" {
+  description = <<EOT
+The machine image
+EOT
+  default = "ami-0123"
+}
+
+output "ip" {
+  value     = "${aws_instance.machine.public_ip}"
+  sensitive = true
+}
+`,
+		"extra.tf.json": `{
+  "variable": {
+    "size": { "default": 3 }
+  }
+}`,
+		"override.tf": `variable "ignored" {}`,
+	}
+	for fileName, content := range files {
+		err = ioutil.WriteFile(path.Join(moduleDir, fileName), []byte(content), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	module, err := LoadModule(moduleDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedVariableNames := []string{"ami", "region", "size", "zones"}
+	if !reflect.DeepEqual(module.VariableNames(), expectedVariableNames) {
+		t.Fatalf("Expected variables %v (got %v)", expectedVariableNames, module.VariableNames())
+	}
+	if !module.Variables["region"].Required() || module.Variables["zones"].Required() || module.Variables["size"].Required() || module.Variables["ami"].Required() {
+		t.Errorf("Expected only 'region' to be required")
+	}
+	if module.Variables["region"].Type != "string" {
+		t.Errorf("Expected 'region' to have type 'string' (got '%s')", module.Variables["region"].Type)
+	}
+	if module.Variables["region"].Description != "The target region" {
+		t.Errorf("Expected 'region' to have description 'The target region' (got '%s')", module.Variables["region"].Description)
+	}
+
+	expectedOutputNames := []string{"ip"}
+	if !reflect.DeepEqual(module.OutputNames(), expectedOutputNames) {
+		t.Fatalf("Expected outputs %v (got %v)", expectedOutputNames, module.OutputNames())
+	}
+	if !module.Outputs["ip"].Sensitive {
+		t.Errorf("Expected output 'ip' to be sensitive")
+	}
+}
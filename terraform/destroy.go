@@ -5,12 +5,20 @@ import (
 )
 
 // Destroy invokes Terraform's "destroy" command.
+//
+// If the state is locked, the returned error will be a *StateLockError.
 func (terraformer *Terraformer) Destroy() (success bool, err error) {
-	success, err = terraformer.RunStreamed("destroy",
+	variableFileArguments, cleanup, err := terraformer.variableFileArguments()
+	if err != nil {
+		return
+	}
+	defer cleanup()
+
+	arguments := append([]string{
 		"-force", "-input=false", // non-interactive
 		"-no-color",
-		"-var-file=tfvars.json",
-	)
+	}, variableFileArguments...)
+	success, err = terraformer.runStreamedWithLock("destroy", arguments...)
 	if err != nil {
 		return
 	}
@@ -0,0 +1,160 @@
+package terraform
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// EncryptedVariablesFileName is the name of the file (in the configuration directory) where variables are stored when they are encrypted.
+const EncryptedVariablesFileName = "tfvars.json.enc"
+
+// The size (in bytes) of the keys used to encrypt and decrypt data.
+const encryptionKeySize = 32
+
+// The prefix that identifies data encrypted by EncryptData (and the format version).
+const encryptedDataPrefix = "dmtf-aes256gcm-v1:"
+
+// EncryptionKeySource supplies the key used to encrypt and decrypt variables.
+//
+// It returns an error if the key is not available.
+type EncryptionKeySource func() ([]byte, error)
+
+// ParseEncryptionKey parses a 256-bit encryption key from the specified key material (e.g. the content of a key file).
+//
+// The key material must be exactly 32 bytes of random data, encoded as hex (64 characters) or base64 (44 characters).
+// Passphrases are not accepted (they would need a key-derivation function to be safe to use as keys).
+func ParseEncryptionKey(keyMaterial []byte) ([]byte, error) {
+	keyText := strings.TrimSpace(string(keyMaterial))
+
+	if len(keyText) == hex.EncodedLen(encryptionKeySize) {
+		key, err := hex.DecodeString(keyText)
+		if err == nil {
+			return key, nil
+		}
+	}
+	if len(keyText) == base64.StdEncoding.EncodedLen(encryptionKeySize) {
+		key, err := base64.StdEncoding.DecodeString(keyText)
+		if err == nil && len(key) == encryptionKeySize {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("Encryption key must be exactly %d bytes of random data (encoded as hex or base64; e.g. the output of 'openssl rand -base64 %d')",
+		encryptionKeySize,
+		encryptionKeySize,
+	)
+}
+
+// EncryptData encrypts the specified data (using AES-256-GCM) and returns it as printable text.
+func EncryptData(key []byte, plaintext []byte) (string, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return "", err
+	}
+
+	ciphertext := aead.Seal(nonce, nonce, plaintext, nil)
+
+	return encryptedDataPrefix + base64.StdEncoding.EncodeToString(ciphertext), nil
+}
+
+// DecryptData decrypts data that was encrypted using EncryptData.
+func DecryptData(key []byte, encryptedData string) ([]byte, error) {
+	encryptedData = strings.TrimSpace(encryptedData)
+	if !strings.HasPrefix(encryptedData, encryptedDataPrefix) {
+		return nil, errors.New("Data is not in a supported encrypted format")
+	}
+
+	ciphertext, err := base64.StdEncoding.DecodeString(
+		strings.TrimPrefix(encryptedData, encryptedDataPrefix),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, errors.New("Encrypted data is too short")
+	}
+
+	plaintext, err := aead.Open(nil, ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():], nil)
+	if err != nil {
+		return nil, errors.New("Unable to decrypt data (is the encryption key correct?)")
+	}
+
+	return plaintext, nil
+}
+
+// WriteEncrypted saves variables to the specified file in encrypted form, normalising their values using the specified policy.
+func (variables ConfigVariables) WriteEncrypted(fileName string, policy NormalizationPolicy, key []byte) error {
+	variablesJSON, err := json.Marshal(
+		variables.normalize(policy),
+	)
+	if err != nil {
+		return err
+	}
+
+	encryptedVariables, err := EncryptData(key, variablesJSON)
+	if err != nil {
+		return err
+	}
+
+	err = ioutil.WriteFile(fileName, []byte(encryptedVariables), 0600 /* u=rw,g=,o= */)
+	if err != nil {
+		return err
+	}
+
+	// WriteFile does not change the permissions of an existing file.
+	return os.Chmod(fileName, 0600 /* u=rw,g=,o= */)
+}
+
+// ReadEncryptedVariablesFile reads variables from a file written by ConfigVariables.WriteEncrypted.
+func ReadEncryptedVariablesFile(fileName string, key []byte) (ConfigVariables, error) {
+	encryptedVariables, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	variablesJSON, err := DecryptData(key, string(encryptedVariables))
+	if err != nil {
+		return nil, err
+	}
+
+	variables := make(ConfigVariables)
+	err = json.Unmarshal(variablesJSON, &variables)
+	if err != nil {
+		return nil, err
+	}
+
+	return variables, nil
+}
+
+// Create an AES-256-GCM cipher using the specified key.
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != encryptionKeySize {
+		return nil, errors.New("Encryption key must be 256 bits long")
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,137 @@
+package terraform
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+)
+
+func TestParseEncryptionKey(t *testing.T) {
+	expectedKey := []byte("0123456789abcdef0123456789abcdef")
+
+	validKeys := []string{
+		"3031323334353637383961626364656630313233343536373839616263646566",
+		"MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY=",
+		"MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY=\n",
+	}
+	for _, keyMaterial := range validKeys {
+		key, err := ParseEncryptionKey([]byte(keyMaterial))
+		if err != nil {
+			t.Fatalf("Unexpected error for key '%s': %s", keyMaterial, err.Error())
+		}
+		if !bytes.Equal(key, expectedKey) {
+			t.Fatalf("Incorrect key parsed from '%s'", keyMaterial)
+		}
+	}
+
+	invalidKeys := []string{
+		"hunter22",
+		"0123456789abcdef0123456789abcdef", // Passphrase (not encoded key material)
+		"30313233343536373839616263646566",
+		"MDEyMzQ1Njc4OWFiY2RlZg==",
+	}
+	for _, keyMaterial := range invalidKeys {
+		_, err := ParseEncryptionKey([]byte(keyMaterial))
+		if err == nil {
+			t.Fatalf("Expected an error for key '%s'", keyMaterial)
+		}
+	}
+}
+
+func TestEncryptDecryptData(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, encryptionKeySize)
+	encryptedData, err := EncryptData(key, []byte(`{"api_token":"secret"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plaintext, err := DecryptData(key, encryptedData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(plaintext) != `{"api_token":"secret"}` {
+		t.Fatalf("Unexpected plaintext '%s'", plaintext)
+	}
+
+	_, err = DecryptData(bytes.Repeat([]byte{2}, encryptionKeySize), encryptedData)
+	if err == nil {
+		t.Fatal("Expected an error when decrypting with the wrong key")
+	}
+}
+
+func TestWriteEncrypted(t *testing.T) {
+	testDir, err := ioutil.TempDir("", "encryption-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(testDir)
+
+	key := []byte("0123456789abcdef0123456789abcdef")
+	fileName := path.Join(testDir, EncryptedVariablesFileName)
+	variables := ConfigVariables{
+		"region":   "syd",
+		"password": "hunter2",
+	}
+	err = variables.WriteEncrypted(fileName, NormalizeLegacy, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encryptedData, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(encryptedData, []byte("hunter2")) {
+		t.Errorf("Expected variables to be encrypted at rest")
+	}
+	info, err := os.Stat(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected mode 0600 (got %o)", info.Mode().Perm())
+	}
+
+	decryptedVariables, err := ReadEncryptedVariablesFile(fileName, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decryptedVariables, variables) {
+		t.Errorf("Expected %v (got %v)", variables, decryptedVariables)
+	}
+
+	_, err = ReadEncryptedVariablesFile(fileName, []byte("fedcba9876543210fedcba9876543210"))
+	if err == nil {
+		t.Errorf("Expected an error decrypting variables with the wrong key")
+	}
+}
+
+func TestWriteVariablesRestrictsPermissions(t *testing.T) {
+	testDir, err := ioutil.TempDir("", "encryption-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(testDir)
+
+	// WriteFile does not change the permissions of an existing file.
+	fileName := path.Join(testDir, "tfvars.json")
+	err = ioutil.WriteFile(fileName, []byte("{}"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = ConfigVariables{"password": "hunter2"}.Write(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected mode 0600 (got %o)", info.Mode().Perm())
+	}
+}
@@ -0,0 +1,228 @@
+package terraform
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/machine/libmachine/log"
+)
+
+// The layout used by Terraform when it reports the time a state lock was created.
+const stateLockTimeLayout = "2006-01-02 15:04:05.999999999 -0700 MST"
+
+// Matches a single field from the "Lock Info" section of Terraform's output.
+var stateLockFieldPattern = regexp.MustCompile(`^\s*(ID|Path|Operation|Who|Version|Created|Info):\s*(.*)$`)
+
+// StateLock represents information about a lock held on Terraform state.
+type StateLock struct {
+	// The lock Id (required to forcibly release the lock).
+	ID string
+
+	// The path of the locked state.
+	Path string
+
+	// The operation being performed by the lock holder.
+	Operation string
+
+	// The user and host that hold the lock (e.g. "user@host").
+	Who string
+
+	// The version of Terraform used by the lock holder.
+	Version string
+
+	// The time when the lock was created (zero if unknown).
+	Created time.Time
+
+	// Additional information (if any) supplied by the lock holder.
+	Info string
+}
+
+// Age determines how long ago the lock was created.
+//
+// Returns zero if the lock's creation time is unknown.
+func (lock *StateLock) Age() time.Duration {
+	if lock.Created.IsZero() {
+		return 0
+	}
+
+	return time.Since(lock.Created)
+}
+
+// StateLockError is returned when a Terraform command fails because it could not acquire a lock on the state.
+type StateLockError struct {
+	// The Terraform command that failed.
+	Command string
+
+	// Information about the existing lock.
+	Lock StateLock
+}
+
+// Error creates a string representation of the StateLockError.
+func (err *StateLockError) Error() string {
+	message := fmt.Sprintf("Failed to execute 'terraform %s': the Terraform state is locked", err.Command)
+	if err.Lock.ID != "" {
+		message += fmt.Sprintf(" (lock Id '%s'", err.Lock.ID)
+		if err.Lock.Who != "" {
+			message += fmt.Sprintf(", held by '%s'", err.Lock.Who)
+		}
+		if !err.Lock.Created.IsZero() {
+			message += fmt.Sprintf(", created %s", err.Lock.Created.Format(time.RFC3339))
+		}
+		message += ")"
+	}
+
+	return message
+}
+
+// ParseStateLockError determines whether the output from a Terraform command indicates that it failed to acquire a state lock.
+//
+// Returns nil if the output does not represent a state-lock failure.
+func ParseStateLockError(command string, programOutput string) *StateLockError {
+	if !strings.Contains(programOutput, "Error acquiring the state lock") && !strings.Contains(programOutput, "Error locking state") {
+		return nil
+	}
+
+	lockError := &StateLockError{
+		Command: command,
+	}
+
+	lineScanner := bufio.NewScanner(
+		strings.NewReader(programOutput),
+	)
+	for lineScanner.Scan() {
+		match := stateLockFieldPattern.FindStringSubmatch(lineScanner.Text())
+		if match == nil {
+			continue
+		}
+
+		fieldValue := strings.TrimSpace(match[2])
+		switch match[1] {
+		case "ID":
+			lockError.Lock.ID = fieldValue
+		case "Path":
+			lockError.Lock.Path = fieldValue
+		case "Operation":
+			lockError.Lock.Operation = fieldValue
+		case "Who":
+			lockError.Lock.Who = fieldValue
+		case "Version":
+			lockError.Lock.Version = fieldValue
+		case "Created":
+			lockError.Lock.Created = parseStateLockTime(fieldValue)
+		case "Info":
+			lockError.Lock.Info = fieldValue
+		}
+	}
+
+	return lockError
+}
+
+// ForceUnlock invokes Terraform's "force-unlock" command to forcibly release the specified state lock.
+func (terraformer *Terraformer) ForceUnlock(lockID string) error {
+	if lockID == "" {
+		return errors.New("Cannot forcibly release a Terraform state lock without a lock Id")
+	}
+
+	success, programOutput, err := terraformer.Run("force-unlock",
+		"-force", // non-interactive
+		lockID,
+	)
+	log.Debugf("%s",
+		terraformer.Redactor.Redact(programOutput),
+	)
+	if err != nil {
+		return err
+	}
+	if !success {
+		return fmt.Errorf("Failed to execute 'terraform force-unlock'\n:Terraform output:\n%s",
+			terraformer.Redactor.Redact(programOutput),
+		)
+	}
+
+	return nil
+}
+
+// Get the arguments (if any) that tell Terraform how long to wait for a state lock.
+func (terraformer *Terraformer) lockArguments() []string {
+	if terraformer.LockTimeout <= 0 {
+		return nil
+	}
+
+	return []string{
+		fmt.Sprintf("-lock-timeout=%ds", int(terraformer.LockTimeout.Seconds())),
+	}
+}
+
+// Invoke a Terraform command that acquires a state lock, streaming its output to the Docker Machine log.
+//
+// If the command fails because the state is locked, the returned error will be a *StateLockError.
+func (terraformer *Terraformer) runStreamedWithLock(command string, arguments ...string) (success bool, err error) {
+	var (
+		outputLines     []string
+		outputLinesLock sync.Mutex
+	)
+	handler := func(outputLine string) {
+		log.Infof("%s", outputLine)
+
+		outputLinesLock.Lock()
+		defer outputLinesLock.Unlock()
+
+		outputLines = append(outputLines, outputLine)
+	}
+
+	arguments = append(terraformer.lockArguments(), arguments...)
+	success, err = terraformer.RunStreamedWithHandler(command, handler, arguments...)
+	if err != nil {
+		lockError := ParseStateLockError(command,
+			strings.Join(outputLines, "\n"),
+		)
+		if lockError != nil {
+			logStateLock(lockError)
+
+			err = lockError
+		}
+	}
+
+	return
+}
+
+// Log information about the holder of a state lock.
+func logStateLock(lockError *StateLockError) {
+	log.Warnf("Terraform state is locked (lock Id '%s').", lockError.Lock.ID)
+	log.Warnf("Lock is held by '%s' (operation '%s', Terraform version '%s').",
+		lockError.Lock.Who,
+		lockError.Lock.Operation,
+		lockError.Lock.Version,
+	)
+	if !lockError.Lock.Created.IsZero() {
+		log.Warnf("Lock was created at %s (%s ago).",
+			lockError.Lock.Created.Format(time.RFC3339),
+			lockError.Lock.Age().Truncate(time.Second),
+		)
+	}
+}
+
+// Parse the creation time of a state lock.
+//
+// Returns the zero time if the value cannot be parsed.
+func parseStateLockTime(value string) time.Time {
+	// Strip the monotonic clock reading (if any) that Go appends to time.Time.String().
+	monotonicIndex := strings.Index(value, " m=")
+	if monotonicIndex != -1 {
+		value = value[:monotonicIndex]
+	}
+
+	created, err := time.Parse(stateLockTimeLayout, value)
+	if err != nil {
+		log.Debugf("Unable to parse state lock creation time '%s': %s", value, err.Error())
+
+		return time.Time{}
+	}
+
+	return created
+}
@@ -0,0 +1,217 @@
+package terraform
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseStateLockError(t *testing.T) {
+	testCases := []struct {
+		Name          string
+		Output        string
+		ExpectedLock  *StateLock
+		ExpectedError string
+	}{
+		{
+			Name: "Terraform 0.11",
+			Output: `Error locking state: Error acquiring the state lock: ConditionalCheckFailedException: The conditional request failed
+Lock Info:
+  ID:        9db590f1-b6fe-c5f2-2678-8804f089deba
+  Path:      my-bucket/machines/terraform.tfstate
+  Operation: OperationTypeApply
+  Who:       ops@build-01
+  Version:   0.11.14
+  Created:   2019-07-01 02:13:45.123456789 +0000 UTC
+  Info:
+
+Terraform acquires a state lock to protect the state from being written
+by multiple users at the same time.`,
+			ExpectedLock: &StateLock{
+				ID:        "9db590f1-b6fe-c5f2-2678-8804f089deba",
+				Path:      "my-bucket/machines/terraform.tfstate",
+				Operation: "OperationTypeApply",
+				Who:       "ops@build-01",
+				Version:   "0.11.14",
+				Created:   time.Date(2019, 7, 1, 2, 13, 45, 123456789, time.UTC),
+			},
+			ExpectedError: "lock Id '9db590f1-b6fe-c5f2-2678-8804f089deba', held by 'ops@build-01', created 2019-07-01T02:13:45Z",
+		},
+		{
+			Name: "Monotonic clock reading",
+			Output: `Error: Error acquiring the state lock
+
+Lock Info:
+  ID:        2c4cb6c2-0b0a-4c8b-9f4f-3b8e5c4f1a2d
+  Who:       ops@build-02
+  Created:   2023-02-03 04:05:06.5 +1100 AEDT m=+0.123456789
+  Info:      nightly rebuild`,
+			ExpectedLock: &StateLock{
+				ID:      "2c4cb6c2-0b0a-4c8b-9f4f-3b8e5c4f1a2d",
+				Who:     "ops@build-02",
+				Created: time.Date(2023, 2, 3, 4, 5, 6, 500000000, time.FixedZone("AEDT", 11*60*60)),
+				Info:    "nightly rebuild",
+			},
+			ExpectedError: "held by 'ops@build-02'",
+		},
+		{
+			Name: "Unparseable creation time",
+			Output: `Error acquiring the state lock
+  ID:        1234
+  Created:   yesterday`,
+			ExpectedLock: &StateLock{
+				ID: "1234",
+			},
+			ExpectedError: "(lock Id '1234')",
+		},
+		{
+			Name:          "No lock information",
+			Output:        "Error locking state: Error acquiring the state lock: timeout",
+			ExpectedLock:  &StateLock{},
+			ExpectedError: "the Terraform state is locked",
+		},
+		{
+			Name:   "Other error",
+			Output: "Error: Invalid reference\n  ID: not-a-lock",
+		},
+	}
+
+	for _, testCase := range testCases {
+		lockError := ParseStateLockError("apply", testCase.Output)
+		if testCase.ExpectedLock == nil {
+			if lockError != nil {
+				t.Errorf("%s: expected no state lock error (got %+v)", testCase.Name, lockError.Lock)
+			}
+
+			continue
+		}
+		if lockError == nil {
+			t.Errorf("%s: expected a state lock error", testCase.Name)
+
+			continue
+		}
+
+		actualLock := lockError.Lock
+		expectedLock := *testCase.ExpectedLock
+		if !actualLock.Created.Equal(expectedLock.Created) {
+			t.Errorf("%s: expected lock creation time %s (got %s)", testCase.Name, expectedLock.Created, actualLock.Created)
+		}
+		actualLock.Created, expectedLock.Created = time.Time{}, time.Time{}
+		if actualLock != expectedLock {
+			t.Errorf("%s: expected lock %+v (got %+v)", testCase.Name, expectedLock, actualLock)
+		}
+		if !strings.Contains(lockError.Error(), testCase.ExpectedError) {
+			t.Errorf("%s: expected error message to contain '%s' (got '%s')", testCase.Name, testCase.ExpectedError, lockError.Error())
+		}
+	}
+}
+
+// Terraform output when a state lock cannot be acquired.
+const testStateLockOutput = `Error: Error acquiring the state lock
+
+Lock Info:
+  ID:        9db590f1-b6fe-c5f2-2678-8804f089deba
+  Who:       ops@build-01
+  Created:   2019-07-01 02:13:45.123456789 +0000 UTC`
+
+// Create a fake Terraform executable (a shell script) that records its arguments in args.log (in the same directory).
+func newFakeTerraformer(t *testing.T, script string) (terraformer *Terraformer, argumentsFile string) {
+	testDir, err := ioutil.TempDir("", "terraform-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	executablePath := path.Join(testDir, "terraform")
+	argumentsFile = path.Join(testDir, "args.log")
+	err = ioutil.WriteFile(executablePath, []byte(fmt.Sprintf("#!/bin/sh\necho \"$@\" >> '%s'\n%s\n", argumentsFile, script)), 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	terraformer, err = NewWithExecutable(executablePath, testDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return terraformer, argumentsFile
+}
+
+// Read the arguments recorded by a fake Terraform executable (one line per invocation).
+func readFakeTerraformArguments(t *testing.T, argumentsFile string) []string {
+	arguments, err := ioutil.ReadFile(argumentsFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return strings.Split(strings.TrimSpace(string(arguments)), "\n")
+}
+
+func TestRunStreamedWithLock(t *testing.T) {
+	terraformer, argumentsFile := newFakeTerraformer(t, "cat <<'EOF'\n"+testStateLockOutput+"\nEOF\nexit 1")
+	defer os.RemoveAll(terraformer.ConfigDir)
+	terraformer.LockTimeout = 30 * time.Second
+
+	_, err := terraformer.runStreamedWithLock("apply", "-auto-approve")
+	lockError, ok := err.(*StateLockError)
+	if !ok {
+		t.Fatalf("Expected a *StateLockError (got %#v)", err)
+	}
+	if lockError.Command != "apply" || lockError.Lock.ID != "9db590f1-b6fe-c5f2-2678-8804f089deba" {
+		t.Errorf("Expected lock '9db590f1-b6fe-c5f2-2678-8804f089deba' for 'apply' (got %+v)", lockError)
+	}
+
+	expectedArguments := []string{"apply -lock-timeout=30s -auto-approve"}
+	arguments := readFakeTerraformArguments(t, argumentsFile)
+	if !reflect.DeepEqual(arguments, expectedArguments) {
+		t.Errorf("Expected arguments %v (got %v)", expectedArguments, arguments)
+	}
+
+	// Other failures are not state-lock errors.
+	terraformer, _ = newFakeTerraformer(t, "echo 'Error: Invalid reference'\nexit 1")
+	defer os.RemoveAll(terraformer.ConfigDir)
+
+	_, err = terraformer.runStreamedWithLock("apply")
+	if err == nil {
+		t.Fatal("Expected an error")
+	}
+	if _, ok := err.(*StateLockError); ok {
+		t.Errorf("Did not expect a *StateLockError (got %s)", err.Error())
+	}
+}
+
+func TestForceUnlock(t *testing.T) {
+	terraformer, argumentsFile := newFakeTerraformer(t, "exit 0")
+	defer os.RemoveAll(terraformer.ConfigDir)
+
+	err := terraformer.ForceUnlock("1234")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedArguments := []string{"force-unlock -force 1234"}
+	arguments := readFakeTerraformArguments(t, argumentsFile)
+	if !reflect.DeepEqual(arguments, expectedArguments) {
+		t.Errorf("Expected arguments %v (got %v)", expectedArguments, arguments)
+	}
+
+	err = terraformer.ForceUnlock("")
+	if err == nil {
+		t.Errorf("Expected an error when no lock Id is specified")
+	}
+
+	terraformer, _ = newFakeTerraformer(t, "exit 1")
+	defer os.RemoveAll(terraformer.ConfigDir)
+
+	err = terraformer.ForceUnlock("1234")
+	if err == nil {
+		t.Errorf("Expected an error when 'terraform force-unlock' fails")
+	}
+}
@@ -10,16 +10,19 @@ import (
 // Output represents an output from Terraform's "output" command.
 type Output struct {
 	Name      string      `json:""`
-	DataType  string      `json:"type"`
+	DataType  OutputType  `json:"type"`
 	Value     interface{} `json:"value"`
 	Sensitive bool        `json:"sensitive"`
 }
 
 // Outputs is a map of Terraform outputs, keyed by name.
+//
+// Use GetString, GetInt, GetBool, GetStringList, or GetMap to retrieve output values as a specific type.
 type Outputs map[string]Output
 
 // Output invokes Terraform's "output" command and parse the results.
 //
+// The values of sensitive outputs are registered with the Terraformer's Redactor.
 // Returns a map of outputs, keyed by name.
 func (terraformer *Terraformer) Output() (outputs Outputs, err error) {
 	var (
@@ -27,12 +30,13 @@ func (terraformer *Terraformer) Output() (outputs Outputs, err error) {
 		programOutput string
 	)
 	success, programOutput, err = terraformer.Run("output", "-json", "-no-color")
-	log.Debugf(programOutput)
 	if err != nil {
 		return
 	}
 	if !success {
-		err = fmt.Errorf("Failed to execute 'terraform output'\n:Terraform output:\n%s", programOutput)
+		err = fmt.Errorf("Failed to execute 'terraform output'\n:Terraform output:\n%s",
+			terraformer.Redactor.Redact(programOutput),
+		)
 
 		return
 	}
@@ -48,5 +52,15 @@ func (terraformer *Terraformer) Output() (outputs Outputs, err error) {
 		return
 	}
 
+	// Ensure the values of sensitive outputs never make it into the log.
+	for _, output := range outputs {
+		if output.Sensitive {
+			terraformer.Redactor.AddSecret(output.Value)
+		}
+	}
+	log.Debugf("%s",
+		terraformer.Redactor.Redact(programOutput),
+	)
+
 	return
 }
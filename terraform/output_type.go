@@ -0,0 +1,115 @@
+package terraform
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// OutputType represents the data type of a Terraform output.
+//
+// Older versions of Terraform describe types using a simple name (e.g. "string", "list", "map"),
+// while newer versions use a JSON type expression (e.g. "string", ["list","string"], ["object",{"a":"string"}]).
+// Both forms are supported.
+type OutputType struct {
+	// The kind of type (e.g. string, number, bool, list, set, map, tuple, object).
+	Kind string
+
+	// The type of the elements of a list, set, or map (nil if not applicable or unknown).
+	ElementType *OutputType
+
+	// The original JSON representation of the type.
+	raw json.RawMessage
+}
+
+// IsCollection determines whether the type represents a collection (list, set, tuple, map, or object).
+func (outputType OutputType) IsCollection() bool {
+	switch outputType.Kind {
+	case "list", "set", "tuple", "map", "object":
+		return true
+	default:
+		return false
+	}
+}
+
+// String creates a string representation of the type (e.g. "string", "list(string)").
+func (outputType OutputType) String() string {
+	if outputType.Kind == "" {
+		return "unknown"
+	}
+	if outputType.ElementType != nil {
+		return fmt.Sprintf("%s(%s)", outputType.Kind, outputType.ElementType.String())
+	}
+
+	return outputType.Kind
+}
+
+// MarshalJSON converts the type to JSON (using its original representation, if available).
+func (outputType OutputType) MarshalJSON() ([]byte, error) {
+	if len(outputType.raw) != 0 {
+		return outputType.raw, nil
+	}
+
+	return json.Marshal(outputType.Kind)
+}
+
+// UnmarshalJSON parses either a legacy (string) or modern (type expression) representation of an output type.
+func (outputType *OutputType) UnmarshalJSON(data []byte) error {
+	parsed, err := parseOutputType(data)
+	if err != nil {
+		return err
+	}
+
+	*outputType = parsed
+
+	return nil
+}
+
+// Parse a legacy (string) or modern (type expression) representation of an output type.
+func parseOutputType(data []byte) (OutputType, error) {
+	outputType := OutputType{
+		raw: append(json.RawMessage(nil), data...),
+	}
+
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 || bytes.Equal(data, []byte("null")) {
+		return outputType, nil
+	}
+
+	switch data[0] {
+	case '"':
+		err := json.Unmarshal(data, &outputType.Kind)
+		if err != nil {
+			return outputType, err
+		}
+	case '[':
+		var typeExpression []json.RawMessage
+		err := json.Unmarshal(data, &typeExpression)
+		if err != nil {
+			return outputType, err
+		}
+		if len(typeExpression) == 0 {
+			return outputType, fmt.Errorf("Invalid Terraform type expression '%s'", data)
+		}
+
+		err = json.Unmarshal(typeExpression[0], &outputType.Kind)
+		if err != nil {
+			return outputType, fmt.Errorf("Invalid Terraform type expression '%s': %s", data, err.Error())
+		}
+
+		switch outputType.Kind {
+		case "list", "set", "map":
+			if len(typeExpression) == 2 {
+				elementType, err := parseOutputType(typeExpression[1])
+				if err != nil {
+					return outputType, err
+				}
+				outputType.ElementType = &elementType
+			}
+		}
+	default:
+		return outputType, fmt.Errorf("Unsupported Terraform type '%s'", data)
+	}
+
+	return outputType, nil
+}
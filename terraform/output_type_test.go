@@ -0,0 +1,115 @@
+package terraform
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestParseOutputType(t *testing.T) {
+	testCases := map[string]struct {
+		ExpectedString       string
+		ExpectedIsCollection bool
+	}{
+		`"string"`:                             {"string", false},
+		`"list"`:                               {"list", true},
+		`"map"`:                                {"map", true},
+		`["list","string"]`:                    {"list(string)", true},
+		`["set","number"]`:                     {"set(number)", true},
+		`["map",["list","string"]]`:            {"map(list(string))", true},
+		`["object",{"a":"string","b":"bool"}]`: {"object", true},
+		`["tuple",["string","number"]]`:        {"tuple", true},
+		`null`:                                 {"unknown", false},
+	}
+	for typeJSON, expected := range testCases {
+		outputType, err := parseOutputType([]byte(typeJSON))
+		if err != nil {
+			t.Errorf("Unexpected error for type '%s': %s", typeJSON, err.Error())
+
+			continue
+		}
+		if outputType.String() != expected.ExpectedString {
+			t.Errorf("Expected type '%s' to be '%s' (got '%s')", typeJSON, expected.ExpectedString, outputType.String())
+		}
+		if outputType.IsCollection() != expected.ExpectedIsCollection {
+			t.Errorf("Expected IsCollection() for type '%s' to be %t", typeJSON, expected.ExpectedIsCollection)
+		}
+
+		// The original representation is retained.
+		roundTripped, err := json.Marshal(outputType)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(roundTripped) != typeJSON {
+			t.Errorf("Expected type '%s' to be marshaled as-is (got '%s')", typeJSON, roundTripped)
+		}
+	}
+
+	for _, typeJSON := range []string{`[]`, `[1]`, `42`, `{"kind":"string"}`} {
+		_, err := parseOutputType([]byte(typeJSON))
+		if err == nil {
+			t.Errorf("Expected an error for type '%s'", typeJSON)
+		}
+	}
+}
+
+func TestOutputAccessors(t *testing.T) {
+	// Outputs in the formats used by older ("list", "map") and newer (type expression) versions of Terraform.
+	outputsJSON := `{
+		"ip":            {"sensitive": false, "type": "string", "value": "10.0.0.1"},
+		"port":          {"sensitive": false, "type": "number", "value": 2376},
+		"port_string":   {"sensitive": false, "type": "string", "value": "2376"},
+		"fraction":      {"sensitive": false, "type": "number", "value": 1.5},
+		"enabled":       {"sensitive": false, "type": "bool", "value": true},
+		"enabled_legacy":{"sensitive": false, "type": "string", "value": "1"},
+		"zones":         {"sensitive": false, "type": ["list", "string"], "value": ["a", "b"]},
+		"zones_legacy":  {"sensitive": false, "type": "list", "value": ["a", 2]},
+		"tags":          {"sensitive": false, "type": ["map", "string"], "value": {"owner": "ops"}},
+		"tags_legacy":   {"sensitive": false, "type": "list", "value": [{"owner": "ops"}]}
+	}`
+	var outputs Outputs
+	err := json.Unmarshal([]byte(outputsJSON), &outputs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if value, err := outputs.GetString("ip"); err != nil || value != "10.0.0.1" {
+		t.Errorf("GetString(ip): got '%s' (%v)", value, err)
+	}
+	if value, err := outputs.GetString("port"); err != nil || value != "2376" {
+		t.Errorf("GetString(port): got '%s' (%v)", value, err)
+	}
+	for _, name := range []string{"port", "port_string"} {
+		if value, err := outputs.GetInt(name); err != nil || value != 2376 {
+			t.Errorf("GetInt(%s): got %d (%v)", name, value, err)
+		}
+	}
+	if _, err := outputs.GetInt("fraction"); err == nil {
+		t.Errorf("GetInt(fraction): expected an error")
+	}
+	for _, name := range []string{"enabled", "enabled_legacy"} {
+		if value, err := outputs.GetBool(name); err != nil || !value {
+			t.Errorf("GetBool(%s): got %t (%v)", name, value, err)
+		}
+	}
+	if value, err := outputs.GetStringList("zones"); err != nil || !reflect.DeepEqual(value, []string{"a", "b"}) {
+		t.Errorf("GetStringList(zones): got %v (%v)", value, err)
+	}
+	if value, err := outputs.GetStringList("zones_legacy"); err != nil || !reflect.DeepEqual(value, []string{"a", "2"}) {
+		t.Errorf("GetStringList(zones_legacy): got %v (%v)", value, err)
+	}
+	if value, err := outputs.GetStringList("ip"); err != nil || !reflect.DeepEqual(value, []string{"10.0.0.1"}) {
+		t.Errorf("GetStringList(ip): got %v (%v)", value, err)
+	}
+	for _, name := range []string{"tags", "tags_legacy"} {
+		if value, err := outputs.GetMap(name); err != nil || value["owner"] != "ops" {
+			t.Errorf("GetMap(%s): got %v (%v)", name, value, err)
+		}
+	}
+	if _, err := outputs.GetString("zones"); err == nil {
+		t.Errorf("GetString(zones): expected an error")
+	}
+	if _, err := outputs.GetString("missing"); err == nil {
+		t.Errorf("GetString(missing): expected an error")
+	}
+}
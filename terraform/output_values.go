@@ -0,0 +1,212 @@
+package terraform
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// Has determines whether the outputs include the specified output.
+func (outputs Outputs) Has(name string) bool {
+	_, ok := outputs[name]
+
+	return ok
+}
+
+// Get retrieves the specified output.
+func (outputs Outputs) Get(name string) (Output, error) {
+	output, ok := outputs[name]
+	if !ok {
+		return output, fmt.Errorf("Terraform output '%s' was not found", name)
+	}
+
+	return output, nil
+}
+
+// GetString retrieves the value of the specified output as a string.
+//
+// Numbers and booleans are converted to their string representations.
+func (outputs Outputs) GetString(name string) (string, error) {
+	output, err := outputs.Get(name)
+	if err != nil {
+		return "", err
+	}
+
+	value, err := coerceToString(output.Value)
+	if err != nil {
+		return "", outputValueError(name, output, "a string", err)
+	}
+
+	return value, nil
+}
+
+// GetInt retrieves the value of the specified output as an integer.
+//
+// Strings are parsed; numbers must not have a fractional component.
+func (outputs Outputs) GetInt(name string) (int, error) {
+	output, err := outputs.Get(name)
+	if err != nil {
+		return 0, err
+	}
+
+	var value int
+	switch typedValue := output.Value.(type) {
+	case int:
+		value = typedValue
+	case float64:
+		if typedValue != math.Trunc(typedValue) {
+			err = fmt.Errorf("%v is not a whole number", typedValue)
+		}
+		value = int(typedValue)
+	case json.Number:
+		value, err = strconv.Atoi(typedValue.String())
+	case string:
+		value, err = strconv.Atoi(typedValue)
+	default:
+		err = fmt.Errorf("unsupported value type %T", output.Value)
+	}
+	if err != nil {
+		return 0, outputValueError(name, output, "an integer", err)
+	}
+
+	return value, nil
+}
+
+// GetBool retrieves the value of the specified output as a boolean.
+//
+// Strings such as "true", "false", "1" and "0" are parsed, as are the numbers 1 and 0.
+func (outputs Outputs) GetBool(name string) (bool, error) {
+	output, err := outputs.Get(name)
+	if err != nil {
+		return false, err
+	}
+
+	var value bool
+	switch typedValue := output.Value.(type) {
+	case bool:
+		value = typedValue
+	case string:
+		value, err = strconv.ParseBool(typedValue)
+	case float64:
+		if typedValue != 0 && typedValue != 1 {
+			err = fmt.Errorf("%v is not 0 or 1", typedValue)
+		}
+		value = typedValue == 1
+	default:
+		err = fmt.Errorf("unsupported value type %T", output.Value)
+	}
+	if err != nil {
+		return false, outputValueError(name, output, "a boolean", err)
+	}
+
+	return value, nil
+}
+
+// GetStringList retrieves the value of the specified output as a list of strings.
+//
+// A single (non-list) value is treated as a list with one element.
+func (outputs Outputs) GetStringList(name string) ([]string, error) {
+	output, err := outputs.Get(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var elements []interface{}
+	switch typedValue := output.Value.(type) {
+	case []interface{}:
+		elements = typedValue
+	case []string:
+		return typedValue, nil
+	case map[string]interface{}:
+		return nil, outputValueError(name, output, "a list of strings",
+			fmt.Errorf("value is a map"),
+		)
+	default:
+		elements = []interface{}{typedValue}
+	}
+
+	values := make([]string, len(elements))
+	for index, element := range elements {
+		values[index], err = coerceToString(element)
+		if err != nil {
+			return nil, outputValueError(name, output, "a list of strings",
+				fmt.Errorf("element %d: %s", index, err.Error()),
+			)
+		}
+	}
+
+	return values, nil
+}
+
+// GetMap retrieves the value of the specified output as a map.
+//
+// Older versions of Terraform sometimes represent a map as a single-element list containing the map; this is also supported.
+func (outputs Outputs) GetMap(name string) (map[string]interface{}, error) {
+	output, err := outputs.Get(name)
+	if err != nil {
+		return nil, err
+	}
+
+	switch typedValue := output.Value.(type) {
+	case map[string]interface{}:
+		return typedValue, nil
+	case []interface{}:
+		if len(typedValue) == 1 {
+			mapValue, ok := typedValue[0].(map[string]interface{})
+			if ok {
+				return mapValue, nil
+			}
+		}
+	}
+
+	return nil, outputValueError(name, output, "a map",
+		fmt.Errorf("unsupported value type %T", output.Value),
+	)
+}
+
+// Names retrieves the names of all outputs (in alphabetical order).
+func (outputs Outputs) Names() []string {
+	names := make([]string, 0, len(outputs))
+	for name := range outputs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Convert a scalar value to a string.
+func coerceToString(value interface{}) (string, error) {
+	switch typedValue := value.(type) {
+	case string:
+		return typedValue, nil
+	case bool:
+		return strconv.FormatBool(typedValue), nil
+	case int:
+		return strconv.Itoa(typedValue), nil
+	case float64:
+		return strconv.FormatFloat(typedValue, 'f', -1, 64), nil
+	case json.Number:
+		return typedValue.String(), nil
+	case nil:
+		return "", fmt.Errorf("value is null")
+	case []interface{}:
+		return "", fmt.Errorf("value is a list")
+	case map[string]interface{}:
+		return "", fmt.Errorf("value is a map")
+	default:
+		return "", fmt.Errorf("unsupported value type %T", value)
+	}
+}
+
+// Create a descriptive error for an output value that cannot be converted to the requested type.
+func outputValueError(name string, output Output, expected string, err error) error {
+	return fmt.Errorf("Terraform output '%s' (type %s) cannot be used as %s: %s",
+		name,
+		output.DataType.String(),
+		expected,
+		err.Error(),
+	)
+}
@@ -0,0 +1,114 @@
+package terraform
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// RedactedValue is the placeholder that replaces sensitive values in redacted text.
+const RedactedValue = "<sensitive>"
+
+// ShortSecretLength is the length below which a sensitive value is likely to also match unrelated text (which will then be redacted too).
+const ShortSecretLength = 4
+
+// Redactor masks sensitive values (e.g. passwords, sensitive outputs) in text before it is logged.
+//
+// A nil Redactor is valid, and does not redact anything.
+type Redactor struct {
+	stateLock sync.RWMutex
+	secrets   []string
+}
+
+// NewRedactor creates a new Redactor with no sensitive values.
+func NewRedactor() *Redactor {
+	return &Redactor{}
+}
+
+// AddSecret registers a sensitive value that should be redacted.
+//
+// If the value is a list or map, each of its elements is registered.
+func (redactor *Redactor) AddSecret(value interface{}) {
+	if redactor == nil {
+		return
+	}
+
+	switch typedValue := value.(type) {
+	case nil:
+		return
+	case string:
+		redactor.addSecretString(typedValue)
+	case []interface{}:
+		for _, element := range typedValue {
+			redactor.AddSecret(element)
+		}
+	case map[string]interface{}:
+		for _, element := range typedValue {
+			redactor.AddSecret(element)
+		}
+	default:
+		redactor.addSecretString(
+			fmt.Sprintf("%v", typedValue),
+		)
+	}
+}
+
+// Redact replaces all registered sensitive values in the specified text.
+func (redactor *Redactor) Redact(text string) string {
+	if redactor == nil {
+		return text
+	}
+
+	redactor.stateLock.RLock()
+	defer redactor.stateLock.RUnlock()
+
+	for _, secret := range redactor.secrets {
+		text = strings.Replace(text, secret, RedactedValue, -1)
+	}
+
+	return text
+}
+
+// Register a sensitive string value (and its JSON-escaped form, if different).
+//
+// Every non-empty value is redacted, however short (see ShortSecretLength).
+func (redactor *Redactor) addSecretString(secret string) {
+	if secret == "" {
+		return
+	}
+
+	redactor.stateLock.Lock()
+	defer redactor.stateLock.Unlock()
+
+	redactor.addSecretStringLocked(secret)
+
+	encodedSecret, err := json.Marshal(secret)
+	if err == nil {
+		redactor.addSecretStringLocked(
+			strings.TrimSuffix(strings.TrimPrefix(string(encodedSecret), `"`), `"`),
+		)
+	}
+
+	// Replace longer values first, so that one secret containing another is fully redacted.
+	sort.Sort(byDescendingLength(redactor.secrets))
+}
+
+// Register a sensitive string value (the caller must hold the state lock).
+func (redactor *Redactor) addSecretStringLocked(secret string) {
+	for _, existingSecret := range redactor.secrets {
+		if existingSecret == secret {
+			return
+		}
+	}
+
+	redactor.secrets = append(redactor.secrets, secret)
+}
+
+// Sorts strings by length, longest first.
+type byDescendingLength []string
+
+func (values byDescendingLength) Len() int           { return len(values) }
+func (values byDescendingLength) Swap(i, j int)      { values[i], values[j] = values[j], values[i] }
+func (values byDescendingLength) Less(i, j int) bool { return len(values[i]) > len(values[j]) }
@@ -0,0 +1,33 @@
+package terraform
+
+import (
+	"testing"
+)
+
+func TestRedactor(t *testing.T) {
+	redactor := NewRedactor()
+	redactor.AddSecret("hunter2")
+	redactor.AddSecret("x1")
+	redactor.AddSecret(`pa"ss`)
+	redactor.AddSecret([]interface{}{"list-secret", 42})
+	redactor.AddSecret("")
+
+	testCases := map[string]string{
+		"password=hunter2":       "password=" + RedactedValue,
+		"pin=x1":                 "pin=" + RedactedValue,
+		`{"value":"pa\"ss"}`:     `{"value":"` + RedactedValue + `"}`,
+		"list-secret and 42":     RedactedValue + " and " + RedactedValue,
+		"nothing sensitive here": "nothing sensitive here",
+	}
+	for text, expectedText := range testCases {
+		redactedText := redactor.Redact(text)
+		if redactedText != expectedText {
+			t.Errorf("Expected '%s' to be redacted as '%s' (got '%s')", text, expectedText, redactedText)
+		}
+	}
+
+	var nilRedactor *Redactor
+	if nilRedactor.Redact("hunter2") != "hunter2" {
+		t.Errorf("A nil Redactor should not redact anything")
+	}
+}
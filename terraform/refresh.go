@@ -6,18 +6,38 @@ import (
 )
 
 // Refresh invokes Terraform's "refresh" command.
+//
+// If the state is locked, the returned error will be a *StateLockError.
 func (terraformer *Terraformer) Refresh() error {
-	success, programOutput, err := terraformer.Run("refresh",
+	variableFileArguments, cleanup, err := terraformer.variableFileArguments()
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	arguments := append(terraformer.lockArguments(),
 		"-input=false", // non-interactive
 		"-no-color",
-		"-var-file=tfvars.json",
 	)
-	log.Print(programOutput)
+	arguments = append(arguments, variableFileArguments...)
+	success, programOutput, err := terraformer.Run("refresh", arguments...)
+	log.Print(
+		terraformer.Redactor.Redact(programOutput),
+	)
 	if err != nil {
+		lockError := ParseStateLockError("refresh", programOutput)
+		if lockError != nil {
+			logStateLock(lockError)
+
+			return lockError
+		}
+
 		return err
 	}
 	if !success {
-		return fmt.Errorf("Failed to execute 'terraform refresh'\n:Terraform output:\n%s", programOutput)
+		return fmt.Errorf("Failed to execute 'terraform refresh'\n:Terraform output:\n%s",
+			terraformer.Redactor.Redact(programOutput),
+		)
 	}
 
 	return nil
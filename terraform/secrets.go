@@ -0,0 +1,213 @@
+package terraform
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"runtime"
+	"strings"
+
+	"github.com/docker/machine/libmachine/log"
+)
+
+// The prefixes that identify the kind of a reference to a secret (see ResolveSecretReference).
+//
+// Secret references are only ever supplied explicitly (never inferred from a variable's value),
+// since a command reference runs an arbitrary command.
+const (
+	// SecretReferenceEnvironment identifies a reference to an environment variable (e.g. "env:API_TOKEN").
+	SecretReferenceEnvironment = "env:"
+
+	// SecretReferenceFile identifies a reference to the content of a file (e.g. "file:/path/to/token").
+	SecretReferenceFile = "file:"
+
+	// SecretReferenceCommand identifies a reference to the output of a command (e.g. "cmd:pass show api-token").
+	SecretReferenceCommand = "cmd:"
+)
+
+// ValidateSecretReference determines whether the specified reference to a secret (e.g. "env:API_TOKEN") is valid.
+//
+// The reference is not resolved.
+func ValidateSecretReference(reference string) error {
+	for _, prefix := range []string{SecretReferenceEnvironment, SecretReferenceFile, SecretReferenceCommand} {
+		if strings.HasPrefix(reference, prefix) {
+			if reference == prefix {
+				return fmt.Errorf("'%s' is not a valid secret reference (nothing follows '%s')", reference, prefix)
+			}
+
+			return nil
+		}
+	}
+
+	return fmt.Errorf("'%s' is not a valid secret reference (expected %s, %s, or %s)",
+		reference,
+		SecretReferenceEnvironment,
+		SecretReferenceFile,
+		SecretReferenceCommand,
+	)
+}
+
+// ResolveSecretReference resolves a reference to a secret (e.g. "env:API_TOKEN") to its value.
+//
+// Trailing line-breaks are removed from the content of files and the output of commands.
+func ResolveSecretReference(reference string) (string, error) {
+	switch {
+	case strings.HasPrefix(reference, SecretReferenceEnvironment):
+		variableName := strings.TrimPrefix(reference, SecretReferenceEnvironment)
+		value, ok := os.LookupEnv(variableName)
+		if !ok {
+			return "", fmt.Errorf("Environment variable '%s' is not defined", variableName)
+		}
+
+		return value, nil
+	case strings.HasPrefix(reference, SecretReferenceFile):
+		fileName := strings.TrimPrefix(reference, SecretReferenceFile)
+		content, err := ioutil.ReadFile(fileName)
+		if err != nil {
+			return "", fmt.Errorf("Unable to read secret from file '%s': %s", fileName, err.Error())
+		}
+
+		return strings.TrimRight(string(content), "\r\n"), nil
+	case strings.HasPrefix(reference, SecretReferenceCommand):
+		commandLine := strings.TrimPrefix(reference, SecretReferenceCommand)
+
+		var command *exec.Cmd
+		if runtime.GOOS == "windows" {
+			command = exec.Command("cmd", "/C", commandLine)
+		} else {
+			command = exec.Command("/bin/sh", "-c", commandLine)
+		}
+		command.Stderr = os.Stderr
+		output, err := command.Output()
+		if err != nil {
+			return "", fmt.Errorf("Unable to obtain secret from command '%s': %s", commandLine, err.Error())
+		}
+
+		return strings.TrimRight(string(output), "\r\n"), nil
+	default:
+		return "", fmt.Errorf("'%s' is not a valid secret reference (expected %s, %s, or %s)",
+			reference,
+			SecretReferenceEnvironment,
+			SecretReferenceFile,
+			SecretReferenceCommand,
+		)
+	}
+}
+
+// Get the "-var-file" arguments for a Terraform command that requires variables.
+//
+// If any secret variables have been configured, they are resolved and written to a short-lived (mode 0600) variables file.
+// Likewise, if variables are encrypted, they are decrypted and written to the same file.
+// The caller must call the returned cleanup function once Terraform has exited, to delete that file.
+func (terraformer *Terraformer) variableFileArguments() (arguments []string, cleanup func(), err error) {
+	cleanup = func() {}
+
+	resolvedVariables := make(ConfigVariables)
+	if terraformer.EncryptionKey != nil {
+		var key []byte
+		key, err = terraformer.EncryptionKey()
+		if err != nil {
+			return
+		}
+
+		var decryptedVariables ConfigVariables
+		decryptedVariables, err = ReadEncryptedVariablesFile(
+			path.Join(terraformer.ConfigDir, EncryptedVariablesFileName),
+			key,
+		)
+		if err != nil {
+			err = fmt.Errorf("Unable to decrypt Terraform variables: %s", err.Error())
+
+			return
+		}
+		for variableName, variableValue := range decryptedVariables {
+			resolvedVariables[variableName] = variableValue
+		}
+	} else {
+		arguments = []string{"-var-file=tfvars.json"}
+	}
+
+	for variableName, reference := range terraformer.SecretVariables {
+		referenceText, ok := reference.(string)
+		if !ok {
+			err = fmt.Errorf("Invalid secret reference for variable '%s'", variableName)
+
+			return
+		}
+
+		var secret string
+		secret, err = ResolveSecretReference(referenceText)
+		if err != nil {
+			err = fmt.Errorf("Unable to resolve secret for variable '%s': %s", variableName, err.Error())
+
+			return
+		}
+		terraformer.Redactor.AddSecret(secret)
+
+		resolvedVariables[variableName] = secret
+	}
+	if len(resolvedVariables) == 0 {
+		return
+	}
+
+	var secretsFileName string
+	secretsFileName, err = writeShortLivedVariablesFile(terraformer.ConfigDir, resolvedVariables)
+	if err != nil {
+		return
+	}
+	cleanup = func() {
+		removeError := os.Remove(secretsFileName)
+		if removeError != nil && !os.IsNotExist(removeError) {
+			log.Warnf("Unable to remove temporary variables file '%s': %s", secretsFileName, removeError.Error())
+		}
+	}
+	arguments = append(arguments, "-var-file="+path.Base(secretsFileName))
+
+	return
+}
+
+// Write variables to a new, uniquely-named, variables file (mode 0600) in the specified directory.
+//
+// Returns the full path of the new file.
+func writeShortLivedVariablesFile(directory string, variables ConfigVariables) (string, error) {
+	suffix := make([]byte, 8)
+	_, err := rand.Read(suffix)
+	if err != nil {
+		return "", err
+	}
+
+	// Terraform uses the file extension to determine the format of a variables file.
+	fileName := path.Join(directory,
+		fmt.Sprintf(".dm-%s.tfvars.json", hex.EncodeToString(suffix)),
+	)
+
+	variablesJSON, err := json.Marshal(variables)
+	if err != nil {
+		return "", err
+	}
+
+	file, err := os.OpenFile(fileName, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600 /* u=rw,g=,o= */)
+	if err != nil {
+		return "", err
+	}
+	_, err = file.Write(variablesJSON)
+	if err != nil {
+		file.Close()
+		os.Remove(fileName)
+
+		return "", err
+	}
+	err = file.Close()
+	if err != nil {
+		os.Remove(fileName)
+
+		return "", err
+	}
+
+	return fileName, nil
+}
@@ -0,0 +1,146 @@
+package terraform
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateSecretReference(t *testing.T) {
+	testCases := map[string]bool{
+		"env:API_TOKEN":       true,
+		"file:/path/to/token": true,
+		"cmd:pass show token": true,
+		"env:":                false,
+		"file:":               false,
+		"API_TOKEN":           false,
+		"vault:secret/token":  false,
+		"":                    false,
+	}
+	for reference, expectedValid := range testCases {
+		err := ValidateSecretReference(reference)
+		if expectedValid && err != nil {
+			t.Errorf("Unexpected error for reference '%s': %s", reference, err.Error())
+		} else if !expectedValid && err == nil {
+			t.Errorf("Expected an error for reference '%s'", reference)
+		}
+	}
+}
+
+func TestResolveSecretReference(t *testing.T) {
+	os.Setenv("DM_TEST_SECRET", "hunter2")
+	defer os.Unsetenv("DM_TEST_SECRET")
+
+	secretFile, err := ioutil.TempFile("", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(secretFile.Name())
+	secretFile.WriteString("hunter3\n")
+	secretFile.Close()
+
+	testCases := map[string]string{
+		"env:DM_TEST_SECRET":        "hunter2",
+		"file:" + secretFile.Name(): "hunter3",
+	}
+	for reference, expectedSecret := range testCases {
+		secret, err := ResolveSecretReference(reference)
+		if err != nil {
+			t.Fatalf("Unexpected error for reference '%s': %s", reference, err.Error())
+		}
+		if secret != expectedSecret {
+			t.Errorf("Expected '%s' to resolve to '%s' (got '%s')", reference, expectedSecret, secret)
+		}
+	}
+
+	_, err = ResolveSecretReference("env:DM_TEST_UNDEFINED_SECRET")
+	if err == nil {
+		t.Errorf("Expected an error for an undefined environment variable")
+	}
+}
+
+func TestRefreshResolvesSecretsIntoShortLivedFile(t *testing.T) {
+	os.Setenv("DM_TEST_SECRET", "hunter2")
+	defer os.Unsetenv("DM_TEST_SECRET")
+
+	// Capture the content of the short-lived variables file while Terraform is running.
+	terraformer, argumentsFile := newFakeTerraformer(t, `for argument in "$@"; do
+  case "$argument" in
+    -var-file=.dm-*) cat "${argument#-var-file=}" > secrets.captured ;;
+  esac
+done`)
+	defer os.RemoveAll(terraformer.ConfigDir)
+	terraformer.SecretVariables = ConfigVariables{
+		"api_token": "env:DM_TEST_SECRET",
+	}
+
+	err := terraformer.Refresh()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	arguments := readFakeTerraformArguments(t, argumentsFile)
+	if len(arguments) != 1 || !strings.Contains(arguments[0], "-var-file=tfvars.json -var-file=.dm-") {
+		t.Fatalf("Expected Terraform to be passed tfvars.json and a short-lived variables file (got %v)", arguments)
+	}
+
+	secretsJSON, err := ioutil.ReadFile(path.Join(terraformer.ConfigDir, "secrets.captured"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var secrets map[string]interface{}
+	err = json.Unmarshal(secretsJSON, &secrets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if secrets["api_token"] != "hunter2" {
+		t.Errorf("Expected the short-lived variables file to contain the resolved secret (got %s)", string(secretsJSON))
+	}
+
+	leftovers, err := filepath.Glob(path.Join(terraformer.ConfigDir, ".dm-*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(leftovers) != 0 {
+		t.Errorf("Expected the short-lived variables file to be removed (found %v)", leftovers)
+	}
+	if terraformer.Redactor.Redact("token=hunter2") != "token="+RedactedValue {
+		t.Errorf("Expected the resolved secret to be redacted")
+	}
+}
+
+func TestWriteShortLivedVariablesFile(t *testing.T) {
+	testDir, err := ioutil.TempDir("", "secrets-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(testDir)
+
+	fileName, err := writeShortLivedVariablesFile(testDir, ConfigVariables{"api_token": "hunter2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(fileName, ".tfvars.json") {
+		t.Errorf("Expected a .tfvars.json file (got '%s')", fileName)
+	}
+
+	info, err := os.Stat(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected mode 0600 (got %o)", info.Mode().Perm())
+	}
+
+	otherFileName, err := writeShortLivedVariablesFile(testDir, ConfigVariables{"api_token": "hunter2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if otherFileName == fileName {
+		t.Errorf("Expected each short-lived variables file to have a unique name (got '%s' twice)", fileName)
+	}
+}
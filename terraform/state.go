@@ -0,0 +1,88 @@
+package terraform
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/docker/machine/libmachine/log"
+	"github.com/tintoy/docker-machine-driver-terraform/terraform/state"
+)
+
+// LocalStateFileName is the name of the file (in the configuration directory) where Terraform keeps local state.
+const LocalStateFileName = "terraform.tfstate"
+
+// ReadState reads the current Terraform state.
+//
+// If a local state file exists, it is read directly (without invoking Terraform).
+// Otherwise (e.g. the configuration uses a remote backend) the state is retrieved using "terraform state pull".
+//
+// Returns nil (and no error) if there is no state.
+func (terraformer *Terraformer) ReadState() (*state.State, error) {
+	localStateFileName := path.Join(terraformer.ConfigDir, LocalStateFileName)
+	_, err := os.Stat(localStateFileName)
+	if err == nil {
+		log.Debugf("Reading Terraform state from '%s'...", localStateFileName)
+
+		return state.ReadFile(localStateFileName)
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	log.Debugf("No local Terraform state found in '%s'; will pull state from backend...", terraformer.ConfigDir)
+
+	success, programOutput, err := terraformer.Run("state", "pull")
+	if err != nil {
+		return nil, err
+	}
+	if !success {
+		return nil, fmt.Errorf("Failed to execute 'terraform state pull'\n:Terraform output:\n%s",
+			terraformer.Redactor.Redact(programOutput),
+		)
+	}
+	if strings.TrimSpace(programOutput) == "" {
+		return nil, nil // No state
+	}
+
+	return state.Parse(
+		[]byte(programOutput),
+	)
+}
+
+// StateOutputs reads the root module's outputs from the current Terraform state (without invoking "terraform output").
+//
+// The values of sensitive outputs are registered with the Terraformer's Redactor.
+// Returns an empty map if there is no state.
+func (terraformer *Terraformer) StateOutputs() (Outputs, error) {
+	currentState, err := terraformer.ReadState()
+	if err != nil {
+		return nil, err
+	}
+
+	outputs := make(Outputs)
+	if currentState == nil {
+		return outputs, nil
+	}
+
+	for outputName, stateOutput := range currentState.Outputs {
+		output := Output{
+			Name:      outputName,
+			Value:     stateOutput.Value,
+			Sensitive: stateOutput.Sensitive,
+		}
+		if len(stateOutput.Type) != 0 {
+			output.DataType, err = parseOutputType(stateOutput.Type)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid type for output '%s' in Terraform state: %s", outputName, err.Error())
+			}
+		}
+		if output.Sensitive {
+			terraformer.Redactor.AddSecret(output.Value)
+		}
+
+		outputs[outputName] = output
+	}
+
+	return outputs, nil
+}
@@ -0,0 +1,424 @@
+// Package state reads Terraform state (format versions 3 and 4) without invoking Terraform.
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+)
+
+// State represents the content of a Terraform state file.
+type State struct {
+	// The state format version (3 or 4).
+	Version int
+
+	// The version of Terraform that last wrote the state.
+	TerraformVersion string
+
+	// The state serial number (incremented each time the state changes).
+	Serial int64
+
+	// The state lineage (a unique Id assigned when the state was first created).
+	Lineage string
+
+	// The root module's outputs, keyed by name.
+	Outputs map[string]Output
+
+	// The resources recorded in the state.
+	Resources []Resource
+}
+
+// Output represents an output value recorded in Terraform state.
+type Output struct {
+	// The output value.
+	Value interface{}
+
+	// The JSON representation of the output's type (a string for version 3, a type expression for version 4).
+	Type json.RawMessage
+
+	// Is the output value sensitive?
+	Sensitive bool
+}
+
+// Resource represents a resource (or data source) recorded in Terraform state.
+type Resource struct {
+	// The address of the module that contains the resource (e.g. "module.network"; empty for the root module).
+	Module string
+
+	// The resource mode ("managed" or "data").
+	Mode string
+
+	// The resource type (e.g. "aws_instance").
+	Type string
+
+	// The resource name (e.g. "docker_machine").
+	Name string
+
+	// The name of the provider that manages the resource.
+	Provider string
+
+	// The resource's instances (there is more than one instance if the resource uses count or for_each).
+	Instances []Instance
+}
+
+// Address creates the resource's address (e.g. "module.network.aws_subnet.main").
+func (resource *Resource) Address() string {
+	address := resource.Type + "." + resource.Name
+	if resource.Mode == "data" {
+		address = "data." + address
+	}
+	if resource.Module != "" {
+		address = resource.Module + "." + address
+	}
+
+	return address
+}
+
+// Instance represents a single instance of a resource recorded in Terraform state.
+type Instance struct {
+	// The instance's index key (nil for resources that do not use count or for_each).
+	IndexKey interface{}
+
+	// The instance Id (if any).
+	ID string
+
+	// The instance attributes.
+	//
+	// For state format version 3, attributes are "flattened" (e.g. "tags.%", "tags.Name") and all values are strings.
+	Attributes map[string]interface{}
+}
+
+// ReadFile reads Terraform state from the specified file.
+func ReadFile(fileName string) (*State, error) {
+	stateJSON, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	state, err := Parse(stateJSON)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read Terraform state from '%s': %s", fileName, err.Error())
+	}
+
+	return state, nil
+}
+
+// Parse parses Terraform state from JSON.
+func Parse(stateJSON []byte) (*State, error) {
+	var header struct {
+		Version int `json:"version"`
+	}
+	err := json.Unmarshal(stateJSON, &header)
+	if err != nil {
+		return nil, err
+	}
+
+	switch header.Version {
+	case 3:
+		return parseV3(stateJSON)
+	case 4:
+		return parseV4(stateJSON)
+	default:
+		return nil, fmt.Errorf("Unsupported Terraform state format version %d", header.Version)
+	}
+}
+
+// ManagedResources retrieves the managed resources (i.e. not data sources) recorded in the state.
+func (state *State) ManagedResources() []Resource {
+	var managedResources []Resource
+	for _, resource := range state.Resources {
+		if resource.Mode == "managed" {
+			managedResources = append(managedResources, resource)
+		}
+	}
+
+	return managedResources
+}
+
+// IsEmpty determines whether the state contains no managed resource instances.
+func (state *State) IsEmpty() bool {
+	for _, resource := range state.ManagedResources() {
+		if len(resource.Instances) > 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// FindResource finds the resource with the specified address (e.g. "aws_instance.docker_machine").
+//
+// Returns nil if no resource was found with the specified address.
+func (state *State) FindResource(address string) *Resource {
+	for index := range state.Resources {
+		if state.Resources[index].Address() == address {
+			return &state.Resources[index]
+		}
+	}
+
+	return nil
+}
+
+// The JSON representation of Terraform state (format version 3).
+type stateV3 struct {
+	Version          int        `json:"version"`
+	TerraformVersion string     `json:"terraform_version"`
+	Serial           int64      `json:"serial"`
+	Lineage          string     `json:"lineage"`
+	Modules          []moduleV3 `json:"modules"`
+}
+
+type moduleV3 struct {
+	Path      []string              `json:"path"`
+	Outputs   map[string]outputV3   `json:"outputs"`
+	Resources map[string]resourceV3 `json:"resources"`
+}
+
+type outputV3 struct {
+	Sensitive bool            `json:"sensitive"`
+	Type      json.RawMessage `json:"type"`
+	Value     interface{}     `json:"value"`
+}
+
+type resourceV3 struct {
+	Type     string      `json:"type"`
+	Provider string      `json:"provider"`
+	Primary  *instanceV3 `json:"primary"`
+}
+
+type instanceV3 struct {
+	ID         string            `json:"id"`
+	Attributes map[string]string `json:"attributes"`
+}
+
+// Parse Terraform state (format version 3).
+func parseV3(stateJSON []byte) (*State, error) {
+	var stateData stateV3
+	err := json.Unmarshal(stateJSON, &stateData)
+	if err != nil {
+		return nil, err
+	}
+
+	state := &State{
+		Version:          stateData.Version,
+		TerraformVersion: stateData.TerraformVersion,
+		Serial:           stateData.Serial,
+		Lineage:          stateData.Lineage,
+		Outputs:          make(map[string]Output),
+	}
+
+	// Version 3 groups resources by address (possibly with several entries for each resource if it uses count).
+	resourcesByAddress := make(map[string]*Resource)
+	var addresses []string
+
+	for _, module := range stateData.Modules {
+		moduleAddress := moduleAddressV3(module.Path)
+		if moduleAddress == "" {
+			for outputName, output := range module.Outputs {
+				state.Outputs[outputName] = Output{
+					Value:     output.Value,
+					Type:      output.Type,
+					Sensitive: output.Sensitive,
+				}
+			}
+		}
+
+		for resourceKey, resourceData := range module.Resources {
+			mode, resourceType, resourceName, indexKey, err := parseResourceKeyV3(resourceKey)
+			if err != nil {
+				return nil, err
+			}
+			if resourceData.Type != "" {
+				resourceType = resourceData.Type
+			}
+
+			resource := &Resource{
+				Module:   moduleAddress,
+				Mode:     mode,
+				Type:     resourceType,
+				Name:     resourceName,
+				Provider: resourceData.Provider,
+			}
+			address := resource.Address()
+			existingResource, ok := resourcesByAddress[address]
+			if ok {
+				resource = existingResource
+			} else {
+				resourcesByAddress[address] = resource
+				addresses = append(addresses, address)
+			}
+
+			if resourceData.Primary == nil {
+				continue
+			}
+
+			instance := Instance{
+				IndexKey:   indexKey,
+				ID:         resourceData.Primary.ID,
+				Attributes: make(map[string]interface{}),
+			}
+			for attributeName, attributeValue := range resourceData.Primary.Attributes {
+				instance.Attributes[attributeName] = attributeValue
+			}
+			resource.Instances = append(resource.Instances, instance)
+		}
+	}
+
+	// Resources are stored in a map, so sort them to make the result deterministic.
+	sort.Strings(addresses)
+	for _, address := range addresses {
+		resource := resourcesByAddress[address]
+		sort.Sort(byIndexKey(resource.Instances))
+		state.Resources = append(state.Resources, *resource)
+	}
+
+	return state, nil
+}
+
+// Convert a version 3 module path (e.g. ["root", "network"]) to a module address (e.g. "module.network").
+func moduleAddressV3(modulePath []string) string {
+	var moduleAddress []string
+	for _, moduleName := range modulePath {
+		if moduleName == "root" && len(moduleAddress) == 0 {
+			continue
+		}
+		moduleAddress = append(moduleAddress, "module."+moduleName)
+	}
+
+	return strings.Join(moduleAddress, ".")
+}
+
+// Parse a version 3 resource key (e.g. "aws_instance.foo", "aws_instance.foo.1", "data.aws_ami.ubuntu").
+func parseResourceKeyV3(resourceKey string) (mode string, resourceType string, resourceName string, indexKey interface{}, err error) {
+	mode = "managed"
+
+	keyParts := strings.Split(resourceKey, ".")
+	if len(keyParts) > 0 && keyParts[0] == "data" {
+		mode = "data"
+		keyParts = keyParts[1:]
+	}
+	if len(keyParts) < 2 || len(keyParts) > 3 {
+		err = fmt.Errorf("Invalid resource key '%s' in Terraform state", resourceKey)
+
+		return
+	}
+
+	resourceType = keyParts[0]
+	resourceName = keyParts[1]
+	if len(keyParts) == 3 {
+		var index int
+		_, err = fmt.Sscanf(keyParts[2], "%d", &index)
+		if err != nil {
+			err = fmt.Errorf("Invalid resource key '%s' in Terraform state", resourceKey)
+
+			return
+		}
+		indexKey = index
+	}
+
+	return
+}
+
+// Sorts resource instances by numeric index key (instances without an index key come first).
+type byIndexKey []Instance
+
+func (instances byIndexKey) Len() int      { return len(instances) }
+func (instances byIndexKey) Swap(i, j int) { instances[i], instances[j] = instances[j], instances[i] }
+func (instances byIndexKey) Less(i, j int) bool {
+	index1, _ := instances[i].IndexKey.(int)
+	index2, _ := instances[j].IndexKey.(int)
+
+	return index1 < index2
+}
+
+// The JSON representation of Terraform state (format version 4).
+type stateV4 struct {
+	Version          int                 `json:"version"`
+	TerraformVersion string              `json:"terraform_version"`
+	Serial           int64               `json:"serial"`
+	Lineage          string              `json:"lineage"`
+	Outputs          map[string]outputV4 `json:"outputs"`
+	Resources        []resourceV4        `json:"resources"`
+}
+
+type outputV4 struct {
+	Value     interface{}     `json:"value"`
+	Type      json.RawMessage `json:"type"`
+	Sensitive bool            `json:"sensitive"`
+}
+
+type resourceV4 struct {
+	Module    string       `json:"module"`
+	Mode      string       `json:"mode"`
+	Type      string       `json:"type"`
+	Name      string       `json:"name"`
+	Provider  string       `json:"provider"`
+	Instances []instanceV4 `json:"instances"`
+}
+
+type instanceV4 struct {
+	IndexKey       interface{}            `json:"index_key"`
+	Attributes     map[string]interface{} `json:"attributes"`
+	AttributesFlat map[string]string      `json:"attributes_flat"`
+}
+
+// Parse Terraform state (format version 4).
+func parseV4(stateJSON []byte) (*State, error) {
+	var stateData stateV4
+	err := json.Unmarshal(stateJSON, &stateData)
+	if err != nil {
+		return nil, err
+	}
+
+	state := &State{
+		Version:          stateData.Version,
+		TerraformVersion: stateData.TerraformVersion,
+		Serial:           stateData.Serial,
+		Lineage:          stateData.Lineage,
+		Outputs:          make(map[string]Output),
+	}
+	for outputName, output := range stateData.Outputs {
+		state.Outputs[outputName] = Output{
+			Value:     output.Value,
+			Type:      output.Type,
+			Sensitive: output.Sensitive,
+		}
+	}
+
+	for _, resourceData := range stateData.Resources {
+		resource := Resource{
+			Module:   resourceData.Module,
+			Mode:     resourceData.Mode,
+			Type:     resourceData.Type,
+			Name:     resourceData.Name,
+			Provider: resourceData.Provider,
+		}
+		for _, instanceData := range resourceData.Instances {
+			instance := Instance{
+				IndexKey:   instanceData.IndexKey,
+				Attributes: instanceData.Attributes,
+			}
+
+			// Instances written by providers using an older schema version may only have flattened attributes.
+			if instance.Attributes == nil {
+				instance.Attributes = make(map[string]interface{})
+				for attributeName, attributeValue := range instanceData.AttributesFlat {
+					instance.Attributes[attributeName] = attributeValue
+				}
+			}
+
+			id, ok := instance.Attributes["id"].(string)
+			if ok {
+				instance.ID = id
+			}
+
+			resource.Instances = append(resource.Instances, instance)
+		}
+
+		state.Resources = append(state.Resources, resource)
+	}
+
+	return state, nil
+}
@@ -0,0 +1,160 @@
+package state
+
+import (
+	"encoding/json"
+	"path"
+	"reflect"
+	"testing"
+)
+
+// Read a state file from testdata.
+func readTestState(t *testing.T, fileName string) *State {
+	state, err := ReadFile(path.Join("testdata", fileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return state
+}
+
+func TestParseV3(t *testing.T) {
+	state := readTestState(t, "v3.tfstate")
+
+	if state.Version != 3 || state.TerraformVersion != "0.11.14" || state.Serial != 7 || state.Lineage != "0a1b2c3d-v3" {
+		t.Fatalf("Unexpected state header: %d / %s / %d / %s", state.Version, state.TerraformVersion, state.Serial, state.Lineage)
+	}
+
+	// Outputs (only the root module's outputs are included).
+	if len(state.Outputs) != 2 {
+		t.Fatalf("Expected 2 outputs, but found %d", len(state.Outputs))
+	}
+	machineIP := state.Outputs["dm_machine_ip"]
+	if machineIP.Value != "10.0.0.5" || machineIP.Sensitive || string(machineIP.Type) != `"string"` {
+		t.Fatalf("Unexpected output 'dm_machine_ip': %#v", machineIP)
+	}
+	if !state.Outputs["dm_onetime_password"].Sensitive {
+		t.Fatal("Output 'dm_onetime_password' should be sensitive")
+	}
+
+	// Resources (sorted by address, with instances sorted by index).
+	var addresses []string
+	for _, resource := range state.Resources {
+		addresses = append(addresses, resource.Address())
+	}
+	expectedAddresses := []string{"aws_instance.docker_machine", "data.aws_ami.ubuntu", "module.network.aws_subnet.main"}
+	if !reflect.DeepEqual(addresses, expectedAddresses) {
+		t.Fatalf("Expected resources %v, but found %v", expectedAddresses, addresses)
+	}
+
+	instances := state.FindResource("aws_instance.docker_machine").Instances
+	if len(instances) != 2 {
+		t.Fatalf("Expected 2 instances, but found %d", len(instances))
+	}
+	for index, instance := range instances {
+		if instance.IndexKey != index {
+			t.Fatalf("Expected instance %d to have index key %d, but found %v", index, index, instance.IndexKey)
+		}
+	}
+	if instances[0].ID != "i-0001" || instances[0].Attributes["private_ip"] != "10.0.0.5" || instances[0].Attributes["tags.Name"] != "docker-machine-0" {
+		t.Fatalf("Unexpected instance: %#v", instances[0])
+	}
+
+	if len(state.ManagedResources()) != 2 || state.IsEmpty() {
+		t.Fatal("Expected 2 managed resources")
+	}
+}
+
+func TestParseV4(t *testing.T) {
+	state := readTestState(t, "v4.tfstate")
+
+	if state.Version != 4 || state.TerraformVersion != "0.12.29" || state.Serial != 3 || state.Lineage != "0a1b2c3d-v4" {
+		t.Fatalf("Unexpected state header: %d / %s / %d / %s", state.Version, state.TerraformVersion, state.Serial, state.Lineage)
+	}
+
+	// Outputs.
+	if len(state.Outputs) != 3 {
+		t.Fatalf("Expected 3 outputs, but found %d", len(state.Outputs))
+	}
+	if state.Outputs["dm_machine_ip"].Value != "10.0.0.5" || state.Outputs["dm_machine_ip"].Sensitive {
+		t.Fatalf("Unexpected output 'dm_machine_ip': %#v", state.Outputs["dm_machine_ip"])
+	}
+	if !state.Outputs["dm_onetime_password"].Sensitive {
+		t.Fatal("Output 'dm_onetime_password' should be sensitive")
+	}
+	zones := state.Outputs["zones"]
+	if !reflect.DeepEqual(zones.Value, []interface{}{"a", "b"}) {
+		t.Fatalf("Unexpected value for output 'zones': %#v", zones.Value)
+	}
+	var zonesType interface{}
+	err := json.Unmarshal(zones.Type, &zonesType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(zonesType, []interface{}{"list", "string"}) {
+		t.Fatalf("Unexpected type for output 'zones': %s", string(zones.Type))
+	}
+
+	// Resources (in state order).
+	var addresses []string
+	for _, resource := range state.Resources {
+		addresses = append(addresses, resource.Address())
+	}
+	expectedAddresses := []string{"data.aws_ami.ubuntu", "aws_instance.docker_machine", "module.network.aws_subnet.main"}
+	if !reflect.DeepEqual(addresses, expectedAddresses) {
+		t.Fatalf("Expected resources %v, but found %v", expectedAddresses, addresses)
+	}
+
+	instance := state.FindResource("aws_instance.docker_machine").Instances[0]
+	if instance.ID != "i-0001" || instance.Attributes["private_ip"] != "10.0.0.5" {
+		t.Fatalf("Unexpected instance: %#v", instance)
+	}
+	if indexKey, ok := instance.IndexKey.(float64); !ok || indexKey != 0 {
+		t.Fatalf("Unexpected index key: %#v", instance.IndexKey)
+	}
+	tags, ok := instance.Attributes["tags"].(map[string]interface{})
+	if !ok || tags["Name"] != "docker-machine-0" {
+		t.Fatalf("Unexpected tags: %#v", instance.Attributes["tags"])
+	}
+
+	// Flattened attributes (older provider schema versions).
+	subnet := state.FindResource("module.network.aws_subnet.main").Instances[0]
+	if subnet.ID != "subnet-1234" || subnet.Attributes["tags.%"] != "0" {
+		t.Fatalf("Unexpected instance: %#v", subnet)
+	}
+
+	if len(state.ManagedResources()) != 2 || state.IsEmpty() {
+		t.Fatal("Expected 2 managed resources")
+	}
+}
+
+func TestParseEmpty(t *testing.T) {
+	state, err := Parse([]byte(`{"version": 4, "serial": 1, "lineage": "x", "outputs": {}, "resources": []}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !state.IsEmpty() {
+		t.Fatal("State should be empty")
+	}
+	if state.FindResource("aws_instance.docker_machine") != nil {
+		t.Fatal("Resource should not be found")
+	}
+}
+
+func TestParseUnsupportedVersion(t *testing.T) {
+	for _, stateJSON := range []string{`{"version": 2}`, `{"version": 5}`, `{}`} {
+		_, err := Parse([]byte(stateJSON))
+		if err == nil {
+			t.Fatalf("Expected an error for state '%s'", stateJSON)
+		}
+		if stateJSON == `{"version": 5}` && err.Error() != "Unsupported Terraform state format version 5" {
+			t.Fatalf("Unexpected error: %s", err.Error())
+		}
+	}
+}
+
+func TestParseInvalidResourceKeyV3(t *testing.T) {
+	_, err := Parse([]byte(`{"version": 3, "modules": [{"path": ["root"], "resources": {"aws_instance": {"type": "aws_instance"}}}]}`))
+	if err == nil {
+		t.Fatal("Expected an error for an invalid resource key")
+	}
+}
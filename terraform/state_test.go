@@ -0,0 +1,83 @@
+package terraform
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+)
+
+func TestStateOutputsFromLocalState(t *testing.T) {
+	// Terraform must not be invoked when there is local state.
+	terraformer, argumentsFile := newFakeTerraformer(t, "exit 1")
+	defer os.RemoveAll(terraformer.ConfigDir)
+
+	stateData, err := ioutil.ReadFile("state/testdata/v4.tfstate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(path.Join(terraformer.ConfigDir, LocalStateFileName), stateData, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	outputs, err := terraformer.StateOutputs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if readFakeTerraformArguments(t, argumentsFile) != nil {
+		t.Errorf("Terraform should not be invoked when there is local state")
+	}
+
+	machineIP, err := outputs.GetString("dm_machine_ip")
+	if err != nil || machineIP != "10.0.0.5" {
+		t.Errorf("Expected output 'dm_machine_ip' to be '10.0.0.5' (got '%s', %v)", machineIP, err)
+	}
+	zones, err := outputs.GetStringList("zones")
+	if err != nil || !reflect.DeepEqual(zones, []string{"a", "b"}) {
+		t.Errorf("Expected output 'zones' to be [a b] (got %v, %v)", zones, err)
+	}
+	if terraformer.Redactor.Redact("password=hunter2") != "password="+RedactedValue {
+		t.Errorf("Expected the value of sensitive output 'dm_onetime_password' to be redacted")
+	}
+}
+
+func TestReadStateFromBackend(t *testing.T) {
+	terraformer, argumentsFile := newFakeTerraformer(t, `cat <<'EOF'
+{
+  "version": 4,
+  "serial": 1,
+  "lineage": "remote",
+  "outputs": { "dm_machine_ip": { "value": "10.0.0.6", "type": "string" } },
+  "resources": []
+}
+EOF`)
+	defer os.RemoveAll(terraformer.ConfigDir)
+
+	currentState, err := terraformer.ReadState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if currentState == nil || currentState.Lineage != "remote" {
+		t.Fatalf("Expected state to be pulled from the backend (got %#v)", currentState)
+	}
+
+	expectedArguments := []string{"state pull"}
+	arguments := readFakeTerraformArguments(t, argumentsFile)
+	if !reflect.DeepEqual(arguments, expectedArguments) {
+		t.Errorf("Expected arguments %v (got %v)", expectedArguments, arguments)
+	}
+
+	// No state at all.
+	terraformer, _ = newFakeTerraformer(t, "exit 0")
+	defer os.RemoveAll(terraformer.ConfigDir)
+
+	currentState, err = terraformer.ReadState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if currentState != nil {
+		t.Errorf("Expected no state (got %#v)", currentState)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"os"
 	"os/exec"
 	"strings"
+	"time"
 
 	"github.com/docker/machine/libmachine/log"
 )
@@ -19,12 +20,34 @@ type Terraformer struct {
 
 	// The directory containing the Terraform configuration
 	ConfigDir string
+
+	// The maximum amount of time that Terraform should wait to acquire a state lock.
+	//
+	// If zero, Terraform will fail immediately if the state is locked.
+	LockTimeout time.Duration
+
+	// The Redactor used to mask sensitive values in Terraform's output before it is logged.
+	Redactor *Redactor
+
+	// Variables whose values are references to secrets (e.g. "env:API_TOKEN").
+	//
+	// These are resolved immediately before each Terraform command that requires variables, and are never written to tfvars.json.
+	SecretVariables ConfigVariables
+
+	// If specified, variables are stored in encrypted form (EncryptedVariablesFileName) rather than in tfvars.json.
+	//
+	// They are decrypted immediately before each Terraform command that requires them.
+	EncryptionKey EncryptionKeySource
+
+	// The Terraform version (populated the first time Version is called).
+	version string
 }
 
 // New creates a new Terraformer using the specified configuration directory.
 func New(configDir string) (*Terraformer, error) {
 	terraformer := &Terraformer{
 		ConfigDir: configDir,
+		Redactor:  NewRedactor(),
 	}
 	err := terraformer.resolveExecutablePath()
 	if err != nil {
@@ -39,6 +62,7 @@ func NewWithExecutable(executablePath string, configDir string) (*Terraformer, e
 	terraformer := &Terraformer{
 		ExecutablePath: executablePath,
 		ConfigDir:      configDir,
+		Redactor:       NewRedactor(),
 	}
 	err := terraformer.resolveExecutablePath()
 	if err != nil {
@@ -50,6 +74,8 @@ func NewWithExecutable(executablePath string, configDir string) (*Terraformer, e
 
 // Run invokes Terraform.
 //
+// The output is returned as-is; callers should use Terraformer.Redactor before logging it.
+//
 // command is the name of the Terraform command to execute (e.g. plan, apply, output,  destroy, etc)
 // arguments are any other arguments to pass to Terraform
 func (terraformer *Terraformer) Run(command string, arguments ...string) (success bool, output string, err error) {
@@ -123,6 +149,8 @@ type PipeHandler func(string)
 
 // RunStreamedWithHandler invokes Terraform and pipes its output to the specified OutputHandler.
 //
+// Sensitive values (see Terraformer.Redactor) are redacted before each line is passed to the handler.
+//
 // command is the name of the Terraform command to execute (e.g. plan, apply, output,  destroy, etc)
 // pipeOutput is a function called once for each line of output received
 // arguments are any other arguments to pass to Terraform
@@ -173,8 +201,13 @@ func (terraformer *Terraformer) RunStreamedWithHandler(command string, handler P
 		return
 	}
 
-	// Pipe output to the caller.
-	scanProcessPipes(stdoutPipe, stderrPipe, handler)
+	// Pipe output to the caller (and make sure we've seen all of it before waiting for the process to exit).
+	redactingHandler := func(outputLine string) {
+		handler(
+			terraformer.Redactor.Redact(outputLine),
+		)
+	}
+	scanProcessPipes(stdoutPipe, stderrPipe, redactingHandler).Wait()
 
 	// Pipes will be auto-closed once process is terminated.
 	err = terraformCommand.Wait()
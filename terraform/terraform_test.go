@@ -0,0 +1,66 @@
+package terraform
+
+import (
+	"os"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestRunStreamedWithHandlerRedactsOutput(t *testing.T) {
+	terraformer, _ := newFakeTerraformer(t, "echo 'password is hunter2'\necho 'hunter2 on stderr' >&2")
+	defer os.RemoveAll(terraformer.ConfigDir)
+	terraformer.Redactor.AddSecret("hunter2")
+
+	var outputLines []string
+	success, err := terraformer.RunStreamedWithHandler("apply", func(outputLine string) {
+		outputLines = append(outputLines, outputLine)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !success {
+		t.Fatal("Expected the command to succeed")
+	}
+
+	// The relative order of lines from stdout and stderr is not guaranteed.
+	sort.Strings(outputLines)
+	expectedOutputLines := []string{
+		RedactedValue + " on stderr",
+		"password is " + RedactedValue,
+	}
+	if !reflect.DeepEqual(outputLines, expectedOutputLines) {
+		t.Errorf("Expected output %q (got %q)", expectedOutputLines, outputLines)
+	}
+}
+
+func TestOutputRegistersSensitiveValues(t *testing.T) {
+	terraformer, _ := newFakeTerraformer(t, `cat <<'EOF'
+{
+  "password": { "sensitive": true, "type": "string", "value": "hunter2" },
+  "keys": { "sensitive": true, "type": "list", "value": ["key-one", "key-two"] },
+  "ip": { "sensitive": false, "type": "string", "value": "10.0.0.1" }
+}
+EOF`)
+	defer os.RemoveAll(terraformer.ConfigDir)
+
+	outputs, err := terraformer.Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if outputs["ip"].Value != "10.0.0.1" {
+		t.Errorf("Expected output 'ip' to be '10.0.0.1' (got '%v')", outputs["ip"].Value)
+	}
+
+	testCases := map[string]string{
+		"password=hunter2": "password=" + RedactedValue,
+		"key-one, key-two": RedactedValue + ", " + RedactedValue,
+		"machine 10.0.0.1": "machine 10.0.0.1",
+	}
+	for text, expectedText := range testCases {
+		redactedText := terraformer.Redactor.Redact(text)
+		if redactedText != expectedText {
+			t.Errorf("Expected '%s' to be redacted as '%s' (got '%s')", text, expectedText, redactedText)
+		}
+	}
+}
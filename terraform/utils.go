@@ -3,6 +3,7 @@ package terraform
 import (
 	"bufio"
 	"io"
+	"sync"
 
 	"github.com/docker/machine/libmachine/log"
 )
@@ -10,9 +11,21 @@ import (
 // Scan STDOUT and STDERR pipes for a process.
 //
 // Calls the supplied PipeHandler once for each line encountered.
-func scanProcessPipes(stdioPipe io.ReadCloser, stderrPipe io.ReadCloser, pipeOutput PipeHandler) {
-	go scanPipe(stdioPipe, pipeOutput, "STDOUT")
-	go scanPipe(stderrPipe, pipeOutput, "STDERR")
+// The returned WaitGroup completes once both pipes have been fully consumed.
+func scanProcessPipes(stdioPipe io.ReadCloser, stderrPipe io.ReadCloser, pipeOutput PipeHandler) *sync.WaitGroup {
+	scanners := &sync.WaitGroup{}
+	scanners.Add(2)
+
+	go func() {
+		defer scanners.Done()
+		scanPipe(stdioPipe, pipeOutput, "STDOUT")
+	}()
+	go func() {
+		defer scanners.Done()
+		scanPipe(stderrPipe, pipeOutput, "STDERR")
+	}()
+
+	return scanners
 }
 
 // Scan a process output pipe, and call the supplied PipeHandler once for each line encountered.
@@ -11,7 +11,9 @@ import (
 // Returns a map of outputs, keyed by name.
 func (terraformer *Terraformer) Validate() error {
 	success, programOutput, err := terraformer.Run("validate")
-	log.Info(programOutput)
+	log.Info(
+		terraformer.Redactor.Redact(programOutput),
+	)
 	if err != nil {
 		return err
 	}
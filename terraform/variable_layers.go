@@ -0,0 +1,91 @@
+package terraform
+
+import (
+	"sort"
+)
+
+// VariableLayer represents a single source of Terraform variables (e.g. a file, or the command line).
+type VariableLayer struct {
+	// A description of the source (e.g. "file 'vars.json'").
+	Source string
+
+	// The variables supplied by the source.
+	Variables ConfigVariables
+}
+
+// VariableProvenance records the source(s) that contributed to the final value of each variable, keyed by variable name.
+//
+// Sources are listed in order of increasing precedence (so the last source is the one that supplied the final value).
+// A variable has more than one source if it was supplied by several layers; if the values were maps, they have been merged.
+type VariableProvenance map[string][]string
+
+// Names retrieves the names of all variables in the provenance map (in alphabetical order).
+func (provenance VariableProvenance) Names() []string {
+	names := make([]string, 0, len(provenance))
+	for name := range provenance {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// MergeVariableLayers merges the specified layers of variables into a single set of variables.
+//
+// Layers are specified in order of increasing precedence (values from later layers override those from earlier layers).
+// If the values from both layers are maps, they are merged (recursively) rather than replaced.
+func MergeVariableLayers(layers ...VariableLayer) (ConfigVariables, VariableProvenance) {
+	merged := make(ConfigVariables)
+	provenance := make(VariableProvenance)
+
+	for _, layer := range layers {
+		for variableName, variableValue := range layer.Variables {
+			existingValue, exists := merged[variableName]
+			if !exists {
+				merged[variableName] = variableValue
+				provenance[variableName] = []string{layer.Source}
+
+				continue
+			}
+
+			mergedValue, wasMerged := deepMergeValues(existingValue, variableValue)
+			merged[variableName] = mergedValue
+			if wasMerged {
+				provenance[variableName] = append(provenance[variableName], layer.Source)
+			} else {
+				provenance[variableName] = []string{layer.Source}
+			}
+		}
+	}
+
+	return merged, provenance
+}
+
+// Merge one value over another.
+//
+// If both values are maps, they are merged (recursively) and wasMerged is true; otherwise, the override value replaces the base value.
+func deepMergeValues(baseValue interface{}, overrideValue interface{}) (mergedValue interface{}, wasMerged bool) {
+	baseMap, ok := baseValue.(map[string]interface{})
+	if !ok {
+		return overrideValue, false
+	}
+	overrideMap, ok := overrideValue.(map[string]interface{})
+	if !ok {
+		return overrideValue, false
+	}
+
+	merged := make(map[string]interface{})
+	for key, value := range baseMap {
+		merged[key] = value
+	}
+	for key, value := range overrideMap {
+		existingValue, exists := merged[key]
+		if exists {
+			merged[key], _ = deepMergeValues(existingValue, value)
+		} else {
+			merged[key] = value
+		}
+	}
+
+	return merged, true
+}
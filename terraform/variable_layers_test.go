@@ -0,0 +1,116 @@
+package terraform
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMergeVariableLayers(t *testing.T) {
+	testCases := []struct {
+		name               string
+		layers             []VariableLayer
+		expectedVariables  ConfigVariables
+		expectedProvenance VariableProvenance
+	}{
+		{
+			name: "later layers take precedence",
+			layers: []VariableLayer{
+				{Source: "defaults", Variables: ConfigVariables{"region": "syd", "size": "small"}},
+				{Source: "file", Variables: ConfigVariables{"region": "mel"}},
+				{Source: "command line", Variables: ConfigVariables{"region": "per"}},
+			},
+			expectedVariables: ConfigVariables{"region": "per", "size": "small"},
+			expectedProvenance: VariableProvenance{
+				"region": {"command line"},
+				"size":   {"defaults"},
+			},
+		},
+		{
+			name: "maps are merged recursively",
+			layers: []VariableLayer{
+				{Source: "defaults", Variables: ConfigVariables{
+					"tags": map[string]interface{}{
+						"owner": "ops",
+						"extra": map[string]interface{}{"tier": "web", "team": "a"},
+					},
+				}},
+				{Source: "file", Variables: ConfigVariables{
+					"tags": map[string]interface{}{
+						"env":   "prod",
+						"extra": map[string]interface{}{"tier": "db"},
+					},
+				}},
+			},
+			expectedVariables: ConfigVariables{
+				"tags": map[string]interface{}{
+					"owner": "ops",
+					"env":   "prod",
+					"extra": map[string]interface{}{"tier": "db", "team": "a"},
+				},
+			},
+			expectedProvenance: VariableProvenance{
+				"tags": {"defaults", "file"},
+			},
+		},
+		{
+			name: "non-map value replaces a map",
+			layers: []VariableLayer{
+				{Source: "defaults", Variables: ConfigVariables{"tags": map[string]interface{}{"owner": "ops"}}},
+				{Source: "file", Variables: ConfigVariables{"tags": map[string]interface{}{"env": "prod"}}},
+				{Source: "command line", Variables: ConfigVariables{"tags": "none"}},
+			},
+			expectedVariables: ConfigVariables{"tags": "none"},
+			expectedProvenance: VariableProvenance{
+				"tags": {"command line"},
+			},
+		},
+		{
+			name: "lists are replaced rather than merged",
+			layers: []VariableLayer{
+				{Source: "defaults", Variables: ConfigVariables{"zones": []interface{}{"a", "b"}}},
+				{Source: "file", Variables: ConfigVariables{"zones": []interface{}{"c"}}},
+			},
+			expectedVariables: ConfigVariables{"zones": []interface{}{"c"}},
+			expectedProvenance: VariableProvenance{
+				"zones": {"file"},
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		variables, provenance := MergeVariableLayers(testCase.layers...)
+		if !reflect.DeepEqual(variables, testCase.expectedVariables) {
+			t.Errorf("%s: expected variables %#v (got %#v)", testCase.name, testCase.expectedVariables, variables)
+		}
+		if !reflect.DeepEqual(provenance, testCase.expectedProvenance) {
+			t.Errorf("%s: expected provenance %#v (got %#v)", testCase.name, testCase.expectedProvenance, provenance)
+		}
+	}
+}
+
+func TestMergeVariableLayersDoesNotModifyLayers(t *testing.T) {
+	baseTags := map[string]interface{}{"owner": "ops"}
+	MergeVariableLayers(
+		VariableLayer{Source: "defaults", Variables: ConfigVariables{"tags": baseTags}},
+		VariableLayer{Source: "file", Variables: ConfigVariables{"tags": map[string]interface{}{"env": "prod"}}},
+	)
+
+	expectedTags := map[string]interface{}{"owner": "ops"}
+	if !reflect.DeepEqual(baseTags, expectedTags) {
+		t.Errorf("Expected the original layer to be unchanged %#v (got %#v)", expectedTags, baseTags)
+	}
+}
+
+func TestVariableProvenanceNames(t *testing.T) {
+	provenance := VariableProvenance{
+		"size":   {"defaults"},
+		"region": {"file"},
+		"ami":    {"command line"},
+	}
+
+	expectedNames := []string{"ami", "region", "size"}
+	names := provenance.Names()
+	if !reflect.DeepEqual(names, expectedNames) {
+		t.Errorf("Expected names %v (got %v)", expectedNames, names)
+	}
+}
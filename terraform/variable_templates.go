@@ -0,0 +1,90 @@
+package terraform
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// TemplateEscape is the sequence that represents a literal "{{" in a variable value (rather than the start of a template action).
+const TemplateEscape = `\{{`
+
+// Temporarily replaces escaped braces while a template is being expanded.
+const escapedBracesPlaceholder = "\x00dm-escaped-braces\x00"
+
+// ExpandTemplates expands Go templates (e.g. "{{ .MachineName }}-prod") in the string values of the variables, using the specified data.
+//
+// String values nested in lists and maps are also expanded. To include a literal "{{" in a value, escape it as TemplateEscape.
+func (variables ConfigVariables) ExpandTemplates(data interface{}) (ConfigVariables, error) {
+	expanded := make(ConfigVariables)
+	for variableName, variableValue := range variables {
+		expandedValue, err := expandTemplateValue(variableValue, data)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to expand template in variable '%s': %s", variableName, err.Error())
+		}
+
+		expanded[variableName] = expandedValue
+	}
+
+	return expanded, nil
+}
+
+// ExpandTemplate expands a Go template using the specified data.
+//
+// Text that does not contain "{{" is returned as-is (apart from the removal of escapes).
+func ExpandTemplate(text string, data interface{}) (string, error) {
+	if !strings.Contains(text, "{{") {
+		return text, nil
+	}
+
+	escapedText := strings.Replace(text, TemplateEscape, escapedBracesPlaceholder, -1)
+	if !strings.Contains(escapedText, "{{") {
+		return strings.Replace(escapedText, escapedBracesPlaceholder, "{{", -1), nil
+	}
+
+	valueTemplate, err := template.New("value").Option("missingkey=error").Parse(escapedText)
+	if err != nil {
+		return "", err
+	}
+
+	var expandedText bytes.Buffer
+	err = valueTemplate.Execute(&expandedText, data)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.Replace(expandedText.String(), escapedBracesPlaceholder, "{{", -1), nil
+}
+
+// Expand templates in a single value (recursively, for lists and maps).
+func expandTemplateValue(value interface{}, data interface{}) (interface{}, error) {
+	switch typedValue := value.(type) {
+	case string:
+		return ExpandTemplate(typedValue, data)
+	case []interface{}:
+		expanded := make([]interface{}, len(typedValue))
+		for index, elementValue := range typedValue {
+			expandedElement, err := expandTemplateValue(elementValue, data)
+			if err != nil {
+				return nil, err
+			}
+			expanded[index] = expandedElement
+		}
+
+		return expanded, nil
+	case map[string]interface{}:
+		expanded := make(map[string]interface{})
+		for key, elementValue := range typedValue {
+			expandedElement, err := expandTemplateValue(elementValue, data)
+			if err != nil {
+				return nil, err
+			}
+			expanded[key] = expandedElement
+		}
+
+		return expanded, nil
+	default:
+		return value, nil
+	}
+}
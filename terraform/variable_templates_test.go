@@ -0,0 +1,95 @@
+package terraform
+
+import (
+	"reflect"
+	"testing"
+)
+
+type testTemplateData struct {
+	MachineName string
+}
+
+func TestExpandTemplate(t *testing.T) {
+	data := testTemplateData{MachineName: "web1"}
+
+	testCases := map[string]string{
+		"plain":                           "plain",
+		"{{ .MachineName }}-prod":         "web1-prod",
+		`\{{ .MachineName }}`:             "{{ .MachineName }}",
+		`\{{ literal }} {{.MachineName}}`: "{{ literal }} web1",
+		`{{.MachineName}}-\{{x}}`:         "web1-{{x}}",
+		`a \{{ b`:                         "a {{ b",
+		`no braces \ here`:                `no braces \ here`,
+		"}} only closing":                 "}} only closing",
+	}
+	for text, expectedText := range testCases {
+		expandedText, err := ExpandTemplate(text, data)
+		if err != nil {
+			t.Errorf("Unexpected error expanding '%s': %s", text, err.Error())
+
+			continue
+		}
+		if expandedText != expectedText {
+			t.Errorf("Expected '%s' to expand to '%s' (got '%s')", text, expectedText, expandedText)
+		}
+	}
+}
+
+func TestExpandTemplateErrors(t *testing.T) {
+	data := testTemplateData{MachineName: "web1"}
+
+	testCases := []string{
+		"{{ .MachineName",
+		"{{ .NoSuchField }}",
+		`\{{ ok }} {{ .NoSuchField }}`,
+	}
+	for _, text := range testCases {
+		_, err := ExpandTemplate(text, data)
+		if err == nil {
+			t.Errorf("Expected an error expanding '%s'", text)
+		}
+	}
+
+	_, err := ExpandTemplate("{{ .missing }}", map[string]string{})
+	if err == nil {
+		t.Errorf("Expected an error for a missing map key")
+	}
+}
+
+func TestExpandTemplates(t *testing.T) {
+	variables := ConfigVariables{
+		"name":  "{{ .MachineName }}",
+		"count": int64(3),
+		"zones": []interface{}{"{{ .MachineName }}-a", `\{{ b }}`, true},
+		"tags": map[string]interface{}{
+			"Name":   "{{ .MachineName }}",
+			"Nested": map[string]interface{}{"Escaped": `\{{ .MachineName }}`},
+		},
+	}
+
+	expandedVariables, err := variables.ExpandTemplates(testTemplateData{MachineName: "web1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedVariables := ConfigVariables{
+		"name":  "web1",
+		"count": int64(3),
+		"zones": []interface{}{"web1-a", "{{ b }}", true},
+		"tags": map[string]interface{}{
+			"Name":   "web1",
+			"Nested": map[string]interface{}{"Escaped": "{{ .MachineName }}"},
+		},
+	}
+	if !reflect.DeepEqual(expandedVariables, expectedVariables) {
+		t.Errorf("Expected %#v (got %#v)", expectedVariables, expandedVariables)
+	}
+	if variables["name"] != "{{ .MachineName }}" {
+		t.Errorf("Expected the original variables to be unchanged (got '%v')", variables["name"])
+	}
+
+	_, err = ConfigVariables{"bad": "{{ .NoSuchField }}"}.ExpandTemplates(testTemplateData{})
+	if err == nil {
+		t.Errorf("Expected an error for a template that refers to an unknown field")
+	}
+}
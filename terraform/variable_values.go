@@ -0,0 +1,182 @@
+package terraform
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/hcl"
+)
+
+// NormalizationPolicy determines how variable values are represented when they are written to tfvars.json.
+type NormalizationPolicy int
+
+const (
+	// NormalizeLegacy converts all scalar values (including those in lists and maps) to strings.
+	//
+	// Terraform versions before 0.12 only support string, list, and map variables.
+	NormalizeLegacy NormalizationPolicy = iota
+
+	// NormalizeNative preserves the native type of all values (numbers, booleans, lists, maps, and nested values).
+	//
+	// Terraform 0.12 and later support typed variables.
+	NormalizeNative
+)
+
+// Matches the version number in the output of "terraform version" (e.g. "Terraform v0.12.3").
+var terraformVersionPattern = regexp.MustCompile(`Terraform v(\d+)\.(\d+)\.(\d+)`)
+
+// String creates a string representation of the policy.
+func (policy NormalizationPolicy) String() string {
+	switch policy {
+	case NormalizeLegacy:
+		return "legacy (values are converted to strings)"
+	case NormalizeNative:
+		return "native (value types are preserved)"
+	default:
+		return fmt.Sprintf("unknown (%d)", int(policy))
+	}
+}
+
+// NormalizationPolicyForVersion determines the appropriate NormalizationPolicy for the specified Terraform version (e.g. "0.11.14", "v1.5.7").
+//
+// If the version cannot be parsed, NormalizeLegacy is used.
+func NormalizationPolicyForVersion(version string) NormalizationPolicy {
+	major, minor, ok := parseTerraformVersion("Terraform v" + strings.TrimPrefix(version, "v"))
+	if !ok {
+		return NormalizeLegacy
+	}
+	if major > 0 || minor >= 12 {
+		return NormalizeNative
+	}
+
+	return NormalizeLegacy
+}
+
+// Version invokes Terraform's "version" command to determine the version of Terraform in use (e.g. "0.11.14").
+func (terraformer *Terraformer) Version() (string, error) {
+	if terraformer.version != "" {
+		return terraformer.version, nil
+	}
+
+	success, programOutput, err := terraformer.Run("version")
+	if err != nil {
+		return "", err
+	}
+	if !success {
+		return "", fmt.Errorf("Failed to execute 'terraform version'\n:Terraform output:\n%s", programOutput)
+	}
+
+	match := terraformVersionPattern.FindStringSubmatch(programOutput)
+	if match == nil {
+		return "", fmt.Errorf("Unable to determine Terraform version from output of 'terraform version':\n%s", programOutput)
+	}
+	terraformer.version = fmt.Sprintf("%s.%s.%s", match[1], match[2], match[3])
+
+	return terraformer.version, nil
+}
+
+// ParseVariableValue parses a variable value supplied as text (e.g. on the command line).
+//
+// The value can be a JSON literal (e.g. 3, true, "3", ["a","b"]) or an HCL literal (e.g. ["a", "b"], { a = "b" });
+// anything else (including "null", which Terraform would otherwise treat as unset) is treated as a plain string.
+// To force a value that looks like a number or boolean to be a string, enclose it in double quotes.
+func ParseVariableValue(text string) interface{} {
+	trimmedText := strings.TrimSpace(text)
+	if trimmedText == "" {
+		return text
+	}
+
+	decoder := json.NewDecoder(
+		strings.NewReader(trimmedText),
+	)
+	decoder.UseNumber() // Don't lose precision for large numbers (e.g. account Ids)
+
+	var value interface{}
+	err := decoder.Decode(&value)
+	if err == nil && value != nil {
+		_, err = decoder.Token()
+		if err == io.EOF { // No trailing content
+			return value
+		}
+	}
+
+	// Only collections (and heredocs) are parsed as HCL; HCL's rules for numbers (e.g. octal) would surprise people.
+	if strings.HasPrefix(trimmedText, "[") || strings.HasPrefix(trimmedText, "{") || strings.HasPrefix(trimmedText, "<<") {
+		var wrapper map[string]interface{}
+		err = hcl.Unmarshal([]byte("value = "+trimmedText), &wrapper)
+		if err == nil {
+			hclValue, ok := wrapper["value"]
+			if ok {
+				return normalizeHCLValue(hclValue)
+			}
+		}
+	}
+
+	return text
+}
+
+// Normalise a single value according to the policy.
+func (policy NormalizationPolicy) normalizeValue(value interface{}) interface{} {
+	switch typedValue := value.(type) {
+	case []interface{}:
+		normalized := make([]interface{}, len(typedValue))
+		for index, elementValue := range typedValue {
+			normalized[index] = policy.normalizeValue(elementValue)
+		}
+
+		return normalized
+	case []string:
+		return typedValue
+	case map[string]interface{}:
+		normalized := make(map[string]interface{})
+		for key, elementValue := range typedValue {
+			normalized[key] = policy.normalizeValue(elementValue)
+		}
+
+		return normalized
+	}
+
+	if policy == NormalizeNative {
+		return value
+	}
+
+	switch typedValue := value.(type) {
+	case int:
+		return strconv.Itoa(typedValue)
+	case int64:
+		return strconv.FormatInt(typedValue, 10)
+	case float64:
+		return strconv.FormatFloat(typedValue, 'f', -1, 64)
+	case json.Number:
+		return typedValue.String()
+	case bool:
+		return strconv.FormatBool(typedValue)
+	default:
+		return value
+	}
+}
+
+// Parse the major and minor version from a Terraform version string (e.g. "Terraform v0.11.14").
+func parseTerraformVersion(version string) (major int, minor int, ok bool) {
+	match := terraformVersionPattern.FindStringSubmatch(version)
+	if match == nil {
+		return
+	}
+
+	var err error
+	major, err = strconv.Atoi(match[1])
+	if err != nil {
+		return
+	}
+	minor, err = strconv.Atoi(match[2])
+	if err != nil {
+		return
+	}
+	ok = true
+
+	return
+}
@@ -0,0 +1,140 @@
+package terraform
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+)
+
+func TestParseVariableValue(t *testing.T) {
+	testCases := []struct {
+		Text          string
+		ExpectedValue interface{}
+	}{
+		{"hello", "hello"},
+		{"", ""},
+		{"  ", "  "},
+		{"3", json.Number("3")},
+		{"123456789012345678901", json.Number("123456789012345678901")},
+		{"true", true},
+		{`"3"`, "3"},
+		{"null", "null"},
+		{"file:foo", "file:foo"},
+		{"3 apples", "3 apples"},
+		{`["a","b"]`, []interface{}{"a", "b"}},
+		{`[1, null]`, []interface{}{json.Number("1"), nil}},
+		{`{"a":{"b":false}}`, map[string]interface{}{"a": map[string]interface{}{"b": false}}},
+		{`["a", "b",]`, []interface{}{"a", "b"}},          // HCL (trailing comma)
+		{`{ a = "b" }`, map[string]interface{}{"a": "b"}}, // HCL
+		{"[not a list", "[not a list"},
+	}
+	for _, testCase := range testCases {
+		value := ParseVariableValue(testCase.Text)
+		if !reflect.DeepEqual(value, testCase.ExpectedValue) {
+			t.Errorf("Expected '%s' to be parsed as %#v (got %#v)", testCase.Text, testCase.ExpectedValue, value)
+		}
+	}
+}
+
+func TestNormalizeValue(t *testing.T) {
+	value := map[string]interface{}{
+		"count":   json.Number("3"),
+		"enabled": true,
+		"zones":   []interface{}{"a", int64(2)},
+	}
+
+	legacyValue := NormalizeLegacy.normalizeValue(value)
+	expectedLegacyValue := map[string]interface{}{
+		"count":   "3",
+		"enabled": "true",
+		"zones":   []interface{}{"a", "2"},
+	}
+	if !reflect.DeepEqual(legacyValue, expectedLegacyValue) {
+		t.Errorf("Expected %#v (got %#v)", expectedLegacyValue, legacyValue)
+	}
+
+	nativeValue := NormalizeNative.normalizeValue(value)
+	if !reflect.DeepEqual(nativeValue, value) {
+		t.Errorf("Expected %#v (got %#v)", value, nativeValue)
+	}
+}
+
+func TestNormalizationPolicyForVersion(t *testing.T) {
+	testCases := map[string]NormalizationPolicy{
+		"0.11.14": NormalizeLegacy,
+		"0.12.0":  NormalizeNative,
+		"v1.5.7":  NormalizeNative,
+		"unknown": NormalizeLegacy,
+	}
+	for version, expectedPolicy := range testCases {
+		if policy := NormalizationPolicyForVersion(version); policy != expectedPolicy {
+			t.Errorf("Expected policy %s for version '%s' (got %s)", expectedPolicy, version, policy)
+		}
+	}
+}
+
+func TestWriteWithPolicy(t *testing.T) {
+	testDir, err := ioutil.TempDir("", "variable-values-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(testDir)
+
+	variables := ConfigVariables{
+		"count":   json.Number("3"),
+		"enabled": true,
+		"zones":   []interface{}{"a", "b"},
+	}
+
+	testCases := map[NormalizationPolicy]string{
+		NormalizeLegacy: `{"count":"3","enabled":"true","zones":["a","b"]}`,
+		NormalizeNative: `{"count":3,"enabled":true,"zones":["a","b"]}`,
+	}
+	for policy, expectedJSON := range testCases {
+		fileName := path.Join(testDir, policy.String()+".tfvars.json")
+		err = variables.WriteWithPolicy(fileName, policy)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		variablesJSON, err := ioutil.ReadFile(fileName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var compactJSON bytes.Buffer
+		err = json.Compact(&compactJSON, variablesJSON)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if compactJSON.String() != expectedJSON {
+			t.Errorf("Expected %s policy to write %s (got %s)", policy, expectedJSON, compactJSON.String())
+		}
+	}
+}
+
+func TestTerraformerVersion(t *testing.T) {
+	terraformer, argumentsFile := newFakeTerraformer(t, `echo 'Terraform v0.11.14'
+echo
+echo '+ provider.aws v2.17.0'`)
+	defer os.RemoveAll(terraformer.ConfigDir)
+
+	for attempt := 1; attempt <= 2; attempt++ {
+		version, err := terraformer.Version()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if version != "0.11.14" {
+			t.Errorf("Expected version '0.11.14' (got '%s')", version)
+		}
+	}
+
+	// The version is only determined once.
+	arguments := readFakeTerraformArguments(t, argumentsFile)
+	if len(arguments) != 1 || arguments[0] != "version" {
+		t.Errorf("Expected 'terraform version' to be run once (got %q)", arguments)
+	}
+}
@@ -1,11 +1,71 @@
 package terraform
 
 import (
+	"bufio"
+	"bytes"
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
-	"strconv"
+	"os"
+	"path"
+	"regexp"
+	"strings"
 )
 
+// VariablesFormat represents the format of a file containing Terraform variables.
+type VariablesFormat string
+
+const (
+	// VariablesFormatJSON represents variables in JSON format (e.g. tfvars.json).
+	VariablesFormatJSON = VariablesFormat("JSON")
+
+	// VariablesFormatHCL represents variables in HCL format (e.g. terraform.tfvars).
+	VariablesFormatHCL = VariablesFormat("HCL")
+
+	// VariablesFormatYAML represents variables in YAML format.
+	VariablesFormatYAML = VariablesFormat("YAML")
+)
+
+// Matches the first line of a YAML document (either a document marker or a "key: value" entry).
+var yamlFirstLinePattern = regexp.MustCompile(`^(---|[\w"'.-]+\s*:(\s|$))`)
+
+// DetectVariablesFormat determines the format of a file containing Terraform variables.
+//
+// The format is determined from the file extension if possible; otherwise, content that looks like a JSON object is treated as JSON,
+// content whose first line looks like YAML ("---" or "name: value") is treated as YAML, and anything else as HCL.
+func DetectVariablesFormat(fileName string, content []byte) VariablesFormat {
+	switch strings.ToLower(path.Ext(fileName)) {
+	case ".json":
+		return VariablesFormatJSON
+	case ".tfvars", ".hcl":
+		return VariablesFormatHCL
+	case ".yaml", ".yml":
+		return VariablesFormatYAML
+	}
+
+	if bytes.HasPrefix(bytes.TrimSpace(content), []byte("{")) {
+		return VariablesFormatJSON
+	}
+
+	// Find the first line that isn't blank or a comment.
+	lineScanner := bufio.NewScanner(
+		bytes.NewReader(content),
+	)
+	for lineScanner.Scan() {
+		line := strings.TrimSpace(lineScanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if yamlFirstLinePattern.MatchString(line) {
+			return VariablesFormatYAML
+		}
+
+		break
+	}
+
+	return VariablesFormatHCL
+}
+
 // ConfigVariables represents values for Terraform configuration variables, keyed by name.
 type ConfigVariables map[string]interface{}
 
@@ -18,21 +78,17 @@ func (variables ConfigVariables) Clear() {
 
 // Read variables from the specified file (normally tfvars.json).
 //
+// The file format (JSON, HCL, or YAML) is detected from the file extension or, if that is inconclusive, the file content.
+//
 // This operation is additive but does not overwrite existing values.
 // Call Clear before Read if this isn't what you want.
 func (variables ConfigVariables) Read(fileName string) error {
-	variablesJSON, err := ioutil.ReadFile(fileName)
-	if err != nil {
-		return err
-	}
-
-	var variablesFromJSON ConfigVariables
-	err = json.Unmarshal(variablesJSON, &variablesFromJSON)
+	variablesFromFile, err := ReadVariablesFile(fileName)
 	if err != nil {
 		return err
 	}
 
-	for variableName, variableValue := range variablesFromJSON {
+	for variableName, variableValue := range variablesFromFile {
 		_, ok := variables[variableName]
 		if ok {
 			continue // Don't overwrite existing values
@@ -43,16 +99,62 @@ func (variables ConfigVariables) Read(fileName string) error {
 	return nil
 }
 
+// ReadVariablesFile reads variables from the specified file.
+//
+// The file format (JSON, HCL, or YAML) is detected from the file extension or, if that is inconclusive, the file content.
+func ReadVariablesFile(fileName string) (ConfigVariables, error) {
+	variablesData, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	format := DetectVariablesFormat(fileName, variablesData)
+
+	var variables ConfigVariables
+	switch format {
+	case VariablesFormatJSON:
+		err = json.Unmarshal(variablesData, &variables)
+	case VariablesFormatHCL:
+		variables, err = parseVariablesHCL(variablesData)
+	case VariablesFormatYAML:
+		variables, err = parseVariablesYAML(variablesData)
+	default:
+		err = fmt.Errorf("Unsupported variables format '%s'", format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Unable to parse %s variables from '%s': %s", format, fileName, err.Error())
+	}
+	if variables == nil {
+		variables = make(ConfigVariables)
+	}
+
+	return variables, nil
+}
+
 // Save variables from the specified file (normally tfvars.json).
+//
+// Values are normalised using NormalizeLegacy; use WriteWithPolicy to choose a different policy.
 func (variables ConfigVariables) Write(fileName string) error {
-	normalizedVariables := variables.normalize()
+	return variables.WriteWithPolicy(fileName, NormalizeLegacy)
+}
+
+// WriteWithPolicy saves variables to the specified file (normally tfvars.json), normalising their values using the specified policy.
+func (variables ConfigVariables) WriteWithPolicy(fileName string, policy NormalizationPolicy) error {
+	normalizedVariables := variables.normalize(policy)
 
 	variablesJSON, err := json.MarshalIndent(normalizedVariables, "", "  ")
 	if err != nil {
 		return err
 	}
 
-	err = ioutil.WriteFile(fileName, variablesJSON, 0644 /* u=rw,g=r,o=r */)
+	// Variables may include credentials, so only the current user can read them.
+	err = ioutil.WriteFile(fileName, variablesJSON, 0600 /* u=rw,g=,o= */)
+	if err != nil {
+		return err
+	}
+
+	// WriteFile does not change the permissions of an existing file.
+	err = os.Chmod(fileName, 0600 /* u=rw,g=,o= */)
 	if err != nil {
 		return err
 	}
@@ -60,20 +162,11 @@ func (variables ConfigVariables) Write(fileName string) error {
 	return nil
 }
 
-// Make a copy of the configuration variables, but with normalised values.
-//
-// For example, numbers are converted to strings.
-func (variables ConfigVariables) normalize() ConfigVariables {
+// Make a copy of the configuration variables, but with values normalised according to the specified policy.
+func (variables ConfigVariables) normalize(policy NormalizationPolicy) ConfigVariables {
 	normalized := make(ConfigVariables)
 	for variableName, variableValue := range variables {
-		normalizedValue := variableValue
-
-		integerValue, ok := variableValue.(int)
-		if ok {
-			normalizedValue = strconv.Itoa(integerValue)
-		}
-
-		normalized[variableName] = normalizedValue
+		normalized[variableName] = policy.normalizeValue(variableValue)
 	}
 
 	return normalized
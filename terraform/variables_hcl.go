@@ -0,0 +1,57 @@
+package terraform
+
+import (
+	"github.com/hashicorp/hcl"
+)
+
+// Parse Terraform variables from HCL (i.e. the format used by .tfvars files).
+func parseVariablesHCL(variablesHCL []byte) (ConfigVariables, error) {
+	var variablesFromHCL map[string]interface{}
+	err := hcl.Unmarshal(variablesHCL, &variablesFromHCL)
+	if err != nil {
+		return nil, err
+	}
+
+	variables := make(ConfigVariables)
+	for variableName, variableValue := range variablesFromHCL {
+		variables[variableName] = normalizeHCLValue(variableValue)
+	}
+
+	return variables, nil
+}
+
+// Convert a value decoded from HCL to the equivalent value that would have been decoded from JSON.
+//
+// The HCL decoder represents each map as a list of maps (since HCL permits a block to be repeated);
+// these are merged back into a single map. Integers are converted to int64 (as they are when decoded from YAML).
+func normalizeHCLValue(value interface{}) interface{} {
+	switch typedValue := value.(type) {
+	case int:
+		return int64(typedValue)
+	case []map[string]interface{}:
+		merged := make(map[string]interface{})
+		for _, mapValue := range typedValue {
+			for key, elementValue := range mapValue {
+				merged[key] = normalizeHCLValue(elementValue)
+			}
+		}
+
+		return merged
+	case map[string]interface{}:
+		normalized := make(map[string]interface{})
+		for key, elementValue := range typedValue {
+			normalized[key] = normalizeHCLValue(elementValue)
+		}
+
+		return normalized
+	case []interface{}:
+		normalized := make([]interface{}, len(typedValue))
+		for index, elementValue := range typedValue {
+			normalized[index] = normalizeHCLValue(elementValue)
+		}
+
+		return normalized
+	default:
+		return value
+	}
+}
@@ -0,0 +1,72 @@
+package terraform
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseVariablesHCL(t *testing.T) {
+	variables, err := parseVariablesHCL([]byte(`
+# Comment
+region = "ap-southeast-2"
+count = 3
+ratio = 1.5
+enabled = true
+zones = ["a", "b"]
+tags = {
+  owner = "ops"
+  nested = {
+    level = 2
+  }
+}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedVariables := ConfigVariables{
+		"region":  "ap-southeast-2",
+		"count":   int64(3),
+		"ratio":   1.5,
+		"enabled": true,
+		"zones":   []interface{}{"a", "b"},
+		"tags": map[string]interface{}{
+			"owner": "ops",
+			"nested": map[string]interface{}{
+				"level": int64(2),
+			},
+		},
+	}
+	if !reflect.DeepEqual(variables, expectedVariables) {
+		t.Fatalf("Expected %#v (got %#v)", expectedVariables, variables)
+	}
+}
+
+func TestParseVariablesHCLSyntaxError(t *testing.T) {
+	_, err := parseVariablesHCL([]byte("region = \"syd\n"))
+	if err == nil {
+		t.Fatal("Expected a syntax error")
+	}
+}
+
+func TestDetectVariablesFormat(t *testing.T) {
+	testCases := []struct {
+		FileName       string
+		Content        string
+		ExpectedFormat VariablesFormat
+	}{
+		{"vars.json", `region = "syd"`, VariablesFormatJSON}, // Extension wins
+		{"vars.tfvars", `{"region": "syd"}`, VariablesFormatHCL},
+		{"VARS.HCL", "", VariablesFormatHCL},
+		{"vars", `  {"region": "syd"}`, VariablesFormatJSON},
+		{"vars", "# Comment\n\nregion = \"syd\"\n", VariablesFormatHCL},
+		{"vars", "tags {\n  owner = \"ops\"\n}\n", VariablesFormatHCL},
+		{"vars", "", VariablesFormatHCL},
+	}
+	for _, testCase := range testCases {
+		format := DetectVariablesFormat(testCase.FileName, []byte(testCase.Content))
+		if format != testCase.ExpectedFormat {
+			t.Errorf("Expected '%s' with content %q to be detected as %s (got %s)", testCase.FileName, testCase.Content, testCase.ExpectedFormat, format)
+		}
+	}
+}
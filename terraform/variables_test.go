@@ -0,0 +1,82 @@
+package terraform
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadVariablesFile(t *testing.T) {
+	testDir, err := ioutil.TempDir("", "variables-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(testDir)
+
+	testCases := map[string]string{
+		"vars.json":   `{"region": "syd", "count": 3}`,
+		"vars.tfvars": "region = \"syd\"\ncount = 3\n",
+		"vars":        "# No extension\nregion = \"syd\"\ncount = 3\n",
+		"vars.yaml":   "region: syd\ncount: 3\n",
+		"vars.yml":    "---\nregion: syd\ncount: 3\n",
+		"vars-yaml":   "# No extension\nregion: syd\ncount: 3\n",
+	}
+	for fileName, content := range testCases {
+		filePath := path.Join(testDir, fileName)
+		err = ioutil.WriteFile(filePath, []byte(content), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		variables, err := ReadVariablesFile(filePath)
+		if err != nil {
+			t.Errorf("Unexpected error reading '%s': %s", fileName, err.Error())
+
+			continue
+		}
+		if variables["region"] != "syd" {
+			t.Errorf("Expected 'region' from '%s' to be 'syd' (got %#v)", fileName, variables["region"])
+		}
+		if _, ok := variables["count"]; !ok {
+			t.Errorf("Expected a value for 'count' from '%s'", fileName)
+		}
+	}
+
+	filePath := path.Join(testDir, "broken.tfvars")
+	err = ioutil.WriteFile(filePath, []byte("region = \"syd\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = ReadVariablesFile(filePath)
+	if err == nil || !strings.Contains(err.Error(), filePath) {
+		t.Errorf("Expected an error that identifies '%s' (got %v)", filePath, err)
+	}
+}
+
+func TestReadVariablesDoesNotOverwrite(t *testing.T) {
+	testDir, err := ioutil.TempDir("", "variables-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(testDir)
+
+	filePath := path.Join(testDir, "vars.tfvars")
+	err = ioutil.WriteFile(filePath, []byte("region = \"mel\"\nsize = \"large\"\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	variables := ConfigVariables{"region": "syd"}
+	err = variables.Read(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedVariables := ConfigVariables{"region": "syd", "size": "large"}
+	if !reflect.DeepEqual(variables, expectedVariables) {
+		t.Errorf("Expected %v (got %v)", expectedVariables, variables)
+	}
+}
@@ -0,0 +1,230 @@
+package terraform
+
+import (
+	"encoding/base64"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Matches the position information in errors from the YAML parser (e.g. "yaml: line 3: did not find expected key").
+//
+// The parser only reports the line on which a syntax error occurred (the column is not available from its errors, or from any node), so only errors in values (see yamlNodeError) include a column.
+var yamlErrorPattern = regexp.MustCompile(`^yaml: line (\d+): (.*)$`)
+
+// Matches integer values (the YAML parser treats integers that are too large for 64 bits as floating-point values).
+var yamlIntegerPattern = regexp.MustCompile(`^[-+]?[0-9]+$`)
+
+// Parse Terraform variables from YAML.
+//
+// YAML-specific values (e.g. timestamps, anchors / aliases, merge keys) are converted to their JSON-compatible equivalents.
+func parseVariablesYAML(variablesYAML []byte) (ConfigVariables, error) {
+	var document yaml.Node
+	err := yaml.Unmarshal(variablesYAML, &document)
+	if err != nil {
+		match := yamlErrorPattern.FindStringSubmatch(err.Error())
+		if match != nil {
+			return nil, fmt.Errorf("line %s: syntax error: %s", match[1], match[2])
+		}
+
+		return nil, err
+	}
+
+	variables := make(ConfigVariables)
+	if len(document.Content) == 0 {
+		return variables, nil // Empty document
+	}
+
+	root := document.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, yamlNodeError(root, "expected a mapping of variable names to values")
+	}
+
+	rootValue, err := convertYAMLNode(root)
+	if err != nil {
+		return nil, err
+	}
+	for variableName, variableValue := range rootValue.(map[string]interface{}) {
+		variables[variableName] = variableValue
+	}
+
+	return variables, nil
+}
+
+// Convert a YAML node to the equivalent value that would have been decoded from JSON.
+func convertYAMLNode(node *yaml.Node) (interface{}, error) {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		if len(node.Content) == 0 {
+			return nil, nil
+		}
+
+		return convertYAMLNode(node.Content[0])
+	case yaml.AliasNode:
+		return convertYAMLNode(node.Alias)
+	case yaml.SequenceNode:
+		values := make([]interface{}, len(node.Content))
+		for index, elementNode := range node.Content {
+			value, err := convertYAMLNode(elementNode)
+			if err != nil {
+				return nil, err
+			}
+			values[index] = value
+		}
+
+		return values, nil
+	case yaml.MappingNode:
+		return convertYAMLMapping(node)
+	case yaml.ScalarNode:
+		return convertYAMLScalar(node)
+	default:
+		return nil, yamlNodeError(node, "unsupported YAML node")
+	}
+}
+
+// Convert a YAML mapping node to a map (resolving merge keys, if any).
+func convertYAMLMapping(node *yaml.Node) (map[string]interface{}, error) {
+	values := make(map[string]interface{})
+
+	// Merged values ("<<: *anchor") have lower precedence than values specified explicitly in the mapping.
+	var explicitKeys []string
+	explicitValues := make(map[string]interface{})
+
+	for index := 0; index+1 < len(node.Content); index += 2 {
+		keyNode := node.Content[index]
+		valueNode := node.Content[index+1]
+
+		if keyNode.Kind == yaml.ScalarNode && keyNode.ShortTag() == "!!merge" {
+			err := mergeYAMLMappings(values, valueNode)
+			if err != nil {
+				return nil, err
+			}
+
+			continue
+		}
+
+		if keyNode.Kind != yaml.ScalarNode {
+			return nil, yamlNodeError(keyNode, "mapping keys must be scalar values")
+		}
+
+		value, err := convertYAMLNode(valueNode)
+		if err != nil {
+			return nil, err
+		}
+		explicitKeys = append(explicitKeys, keyNode.Value)
+		explicitValues[keyNode.Value] = value
+	}
+
+	for _, key := range explicitKeys {
+		values[key] = explicitValues[key]
+	}
+
+	return values, nil
+}
+
+// Merge the mapping (or sequence of mappings) referenced by a YAML merge key into the specified map.
+func mergeYAMLMappings(values map[string]interface{}, node *yaml.Node) error {
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+
+	switch node.Kind {
+	case yaml.MappingNode:
+		mergedValues, err := convertYAMLMapping(node)
+		if err != nil {
+			return err
+		}
+		for key, value := range mergedValues {
+			values[key] = value
+		}
+	case yaml.SequenceNode:
+		// Earlier mappings in the sequence take precedence over later ones.
+		for index := len(node.Content) - 1; index >= 0; index-- {
+			err := mergeYAMLMappings(values, node.Content[index])
+			if err != nil {
+				return err
+			}
+		}
+	default:
+		return yamlNodeError(node, "merge key must refer to a mapping or a sequence of mappings")
+	}
+
+	return nil
+}
+
+// Convert a YAML scalar node to a JSON-compatible value.
+func convertYAMLScalar(node *yaml.Node) (interface{}, error) {
+	switch node.ShortTag() {
+	case "!!null":
+		return nil, nil
+	case "!!str":
+		return node.Value, nil
+	case "!!binary":
+		_, err := base64.StdEncoding.DecodeString(node.Value)
+		if err != nil {
+			return nil, yamlNodeError(node, "invalid binary value: "+err.Error())
+		}
+
+		return node.Value, nil // Leave base64-encoded
+	case "!!bool":
+		var value bool
+		err := node.Decode(&value)
+		if err != nil {
+			return nil, yamlNodeError(node, err.Error())
+		}
+
+		return value, nil
+	case "!!int":
+		// Decode integers as int64 (rather than float64) so that large values (e.g. account Ids) do not lose precision.
+		var value int64
+		err := node.Decode(&value)
+		if err != nil {
+			return nil, yamlNodeError(node, fmt.Sprintf("'%s' is not a valid 64-bit integer", node.Value))
+		}
+
+		return value, nil
+	case "!!float":
+		if node.Style&yaml.TaggedStyle == 0 && yamlIntegerPattern.MatchString(node.Value) {
+			return nil, yamlNodeError(node, fmt.Sprintf("'%s' is not a valid 64-bit integer", node.Value))
+		}
+
+		var value float64
+		err := node.Decode(&value)
+		if err != nil {
+			return nil, yamlNodeError(node, err.Error())
+		}
+		if math.IsInf(value, 0) || math.IsNaN(value) {
+			return nil, yamlNodeError(node, fmt.Sprintf("'%s' cannot be represented in JSON", node.Value))
+		}
+
+		return value, nil
+	case "!!timestamp":
+		var value time.Time
+		err := node.Decode(&value)
+		if err != nil {
+			return nil, yamlNodeError(node, err.Error())
+		}
+
+		// Preserve date-only values as dates; everything else becomes an RFC3339 timestamp.
+		if len(node.Value) == len("2006-01-02") {
+			return value.Format("2006-01-02"), nil
+		}
+
+		return value.Format(time.RFC3339Nano), nil
+	default:
+		return nil, yamlNodeError(node, fmt.Sprintf("unsupported YAML tag '%s'", node.Tag))
+	}
+}
+
+// Create an error that includes the position of the specified YAML node.
+func yamlNodeError(node *yaml.Node, message string) error {
+	return fmt.Errorf("line %s, column %s: %s",
+		strconv.Itoa(node.Line),
+		strconv.Itoa(node.Column),
+		message,
+	)
+}
@@ -0,0 +1,121 @@
+package terraform
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseVariablesYAML(t *testing.T) {
+	variables, err := parseVariablesYAML([]byte(`---
+defaults: &defaults
+  owner: ops
+  tier: web
+region: ap-southeast-2
+count: 3
+enabled: yes_please
+disabled: false
+quoted_number: "3"
+nothing: ~
+created: 2019-07-01
+updated: 2019-07-01T02:13:45Z
+zones: [a, b]
+tags:
+  <<: *defaults
+  tier: db
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedVariables := ConfigVariables{
+		"defaults":      map[string]interface{}{"owner": "ops", "tier": "web"},
+		"region":        "ap-southeast-2",
+		"count":         int64(3),
+		"enabled":       "yes_please",
+		"disabled":      false,
+		"quoted_number": "3",
+		"nothing":       nil,
+		"created":       "2019-07-01",
+		"updated":       "2019-07-01T02:13:45Z",
+		"zones":         []interface{}{"a", "b"},
+		"tags":          map[string]interface{}{"owner": "ops", "tier": "db"}, // Explicit values override merged ones
+	}
+	if !reflect.DeepEqual(variables, expectedVariables) {
+		t.Fatalf("Expected %#v (got %#v)", expectedVariables, variables)
+	}
+}
+
+func TestParseVariablesYAMLNotAMapping(t *testing.T) {
+	_, err := parseVariablesYAML([]byte("- a\n- b\n"))
+	if err == nil {
+		t.Fatal("Expected an error for a document that is not a mapping")
+	}
+
+	variables, err := parseVariablesYAML([]byte("# Nothing but a comment\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(variables) != 0 {
+		t.Fatalf("Expected no variables from an empty document (got %#v)", variables)
+	}
+}
+
+func TestDetectVariablesFormatYAML(t *testing.T) {
+	testCases := map[string]string{
+		"vars.yaml": "",
+		"vars.yml":  "",
+		"vars":      "---\nregion: syd\n",
+		"commented": "# Comment\nregion: syd\n",
+		"quoted":    "\"quoted-name\": syd\n",
+		"empty":     "empty:\n",
+	}
+	for fileName, content := range testCases {
+		format := DetectVariablesFormat(fileName, []byte(content))
+		if format != VariablesFormatYAML {
+			t.Errorf("Expected '%s' with content %q to be detected as YAML (got %s)", fileName, content, format)
+		}
+	}
+}
+
+func TestParseVariablesYAMLLargeInteger(t *testing.T) {
+	variables, err := parseVariablesYAML([]byte("account_id: 9007199254740993\nratio: 1.5\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	accountID, ok := variables["account_id"].(int64)
+	if !ok {
+		t.Fatalf("Expected account_id to be an int64 (got %T)", variables["account_id"])
+	}
+	if accountID != 9007199254740993 {
+		t.Fatalf("Expected account_id to be 9007199254740993 (got %d)", accountID)
+	}
+
+	ratio, ok := variables["ratio"].(float64)
+	if !ok || ratio != 1.5 {
+		t.Fatalf("Expected ratio to be 1.5 (got %#v)", variables["ratio"])
+	}
+}
+
+func TestParseVariablesYAMLIntegerOverflow(t *testing.T) {
+	for _, value := range []string{"18446744073709551615", "99999999999999999999"} {
+		_, err := parseVariablesYAML([]byte("a: 1\nb: " + value + "\n"))
+		if err == nil {
+			t.Fatalf("Expected an error for '%s' (which does not fit in 64 bits)", value)
+		}
+		if !strings.HasPrefix(err.Error(), "line 2, column 4: ") {
+			t.Fatalf("Expected error to include line and column (got '%s')", err.Error())
+		}
+	}
+}
+
+func TestParseVariablesYAMLSyntaxError(t *testing.T) {
+	_, err := parseVariablesYAML([]byte("a: b\n c: : d\n"))
+	if err == nil {
+		t.Fatal("Expected a syntax error")
+	}
+	if !strings.HasPrefix(err.Error(), "line 2: syntax error: ") {
+		t.Fatalf("Unexpected error format '%s'", err.Error())
+	}
+}
@@ -6,6 +6,8 @@ package main
  */
 
 import (
+	"time"
+
 	"github.com/tintoy/docker-machine-driver-terraform/terraform"
 )
 
@@ -32,6 +34,19 @@ func (driver *Driver) getTerraformer() (*terraform.Terraformer, error) {
 		if err != nil {
 			return nil, err
 		}
+
+		driver.terraformer.LockTimeout = time.Duration(driver.StateLockTimeout) * time.Second
+
+		driver.terraformer.Redactor = driver.getRedactor()
+		driver.registerSensitiveVariables()
+
+		driver.terraformer.SecretVariables, err = driver.getSecretReferences()
+		if err != nil {
+			return nil, err
+		}
+		if driver.EncryptVariables {
+			driver.terraformer.EncryptionKey = driver.getEncryptionKey
+		}
 	}
 
 	return driver.terraformer, nil
@@ -0,0 +1,58 @@
+package main
+
+/*
+ * Driver implementation (template expansion in variable values)
+ * -------------------------------------------------------------
+ */
+
+import (
+	"github.com/docker/machine/libmachine/log"
+	"github.com/tintoy/docker-machine-driver-terraform/terraform"
+)
+
+// The data available to templates in user-supplied variable values (e.g. "{{ .MachineName }}-prod").
+type variableTemplateData struct {
+	// The name of the machine being created.
+	MachineName string
+
+	// The SSH user name for the machine.
+	SSHUser string
+
+	// The client's public (external) IP address.
+	ClientIP string
+
+	// The Docker Machine storage path.
+	StorePath string
+
+	// The driver version.
+	DriverVersion string
+}
+
+// Get the data available to templates in user-supplied variable values.
+func (driver *Driver) getVariableTemplateData() variableTemplateData {
+	return variableTemplateData{
+		MachineName:   driver.MachineName,
+		SSHUser:       driver.SSHUser,
+		ClientIP:      driver.ClientIP,
+		StorePath:     driver.StorePath,
+		DriverVersion: DriverVersion,
+	}
+}
+
+// Expand templates in the values of the variables from the specified (user-supplied) layers.
+func (driver *Driver) expandVariableTemplates(layers []terraform.VariableLayer) error {
+	templateData := driver.getVariableTemplateData()
+
+	for index := range layers {
+		expandedVariables, err := layers[index].Variables.ExpandTemplates(templateData)
+		if err != nil {
+			log.Errorf("Failed to expand variables from %s.", layers[index].Source)
+
+			return err
+		}
+
+		layers[index].Variables = expandedVariables
+	}
+
+	return nil
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/tintoy/docker-machine-driver-terraform/terraform"
+)
+
+func TestResolveVariablesExpandsTemplates(t *testing.T) {
+	driver := &Driver{
+		BaseDriver: &drivers.BaseDriver{
+			MachineName: "web1",
+			SSHUser:     "ubuntu",
+		},
+		AdditionalVariablesInline: []string{
+			"name={{ .MachineName }}-prod",
+			"user={{ .SSHUser }}",
+			`literal=\{{ .MachineName }}`,
+		},
+	}
+	err := driver.resolveVariables(terraform.ConfigVariables{
+		"dm_machine_name": "{{ .MachineName }}", // Built-in values are never expanded
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedValues := map[string]string{
+		"name":            "web1-prod",
+		"user":            "ubuntu",
+		"literal":         "{{ .MachineName }}",
+		"dm_machine_name": "{{ .MachineName }}",
+	}
+	for variableName, expectedValue := range expectedValues {
+		if driver.ConfigVariables[variableName] != expectedValue {
+			t.Errorf("Expected '%s' to be '%s' (got '%v')", variableName, expectedValue, driver.ConfigVariables[variableName])
+		}
+	}
+
+	driver.AdditionalVariablesInline = []string{"name={{ .NoSuchField }}"}
+	err = driver.resolveVariables(terraform.ConfigVariables{})
+	if err == nil {
+		t.Errorf("Expected an error for a template that refers to an unknown field")
+	}
+}
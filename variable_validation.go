@@ -0,0 +1,118 @@
+package main
+
+/*
+ * Driver implementation (validation of variables against the configuration's declarations)
+ * ----------------------------------------------------------------------------------------
+ */
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/docker/machine/libmachine/log"
+	"github.com/tintoy/docker-machine-driver-terraform/terraform"
+	"github.com/tintoy/docker-machine-driver-terraform/terraform/config"
+)
+
+const (
+	// Problems with variables are errors.
+	variableValidationStrict = "strict"
+
+	// Problems with variables are logged as warnings (the default).
+	variableValidationWarn = "warn"
+
+	// Variables are not validated.
+	variableValidationOff = "off"
+)
+
+// Validate the driver's variables against the variables declared by the Terraform configuration.
+func (driver *Driver) validateVariables() error {
+	if driver.VariableValidation == variableValidationOff {
+		return nil
+	}
+
+	localConfigDir, err := driver.getConfigDir()
+	if err != nil {
+		return err
+	}
+
+	module, err := config.LoadModule(localConfigDir)
+	if err != nil {
+		// Not fatal; Terraform will report any genuine problems with the configuration.
+		log.Warnf("Unable to read variable declarations from Terraform configuration (variables will not be validated): %s", err.Error())
+
+		return nil
+	}
+
+	// Validate the variables under the names that will be passed to Terraform.
+	variables := make(terraform.ConfigVariables)
+	for variableName, variableValue := range driver.ConfigVariables {
+		variables[variableName] = variableValue
+	}
+	for variableName, reference := range driver.SecretVariables {
+		variables[variableName] = reference
+	}
+	variables = driver.applyVariableMap(variables)
+	secretReferences := driver.applyVariableMap(driver.getSecretVariables())
+
+	var problems []config.VariableProblem
+	for _, problem := range module.ValidateVariables(variables) {
+		if isIgnoredVariableProblem(problem, secretReferences) {
+			continue
+		}
+
+		problems = append(problems, problem)
+	}
+	if len(problems) == 0 {
+		log.Debugf("All %d Terraform variables match the configuration's declarations.", len(variables))
+
+		return nil
+	}
+
+	for _, problem := range problems {
+		if driver.VariableValidation == variableValidationStrict {
+			log.Errorf("%s.", problem.Message)
+		} else {
+			log.Warnf("%s.", problem.Message)
+		}
+	}
+	if driver.VariableValidation != variableValidationStrict {
+		return nil
+	}
+
+	return fmt.Errorf("Found %d problem(s) with the variables for the Terraform configuration (specify --terraform-variable-validation=warn to continue anyway)",
+		len(problems),
+	)
+}
+
+// Determine whether a problem with a variable should be ignored.
+func isIgnoredVariableProblem(problem config.VariableProblem, secretReferences terraform.ConfigVariables) bool {
+	switch problem.Kind {
+	case config.UnknownVariable:
+		// Configurations only need to declare the built-in variables they actually use.
+		return strings.HasPrefix(problem.Variable, builtInVariablePrefix)
+	case config.MissingVariable:
+		// Terraform also reads variables from TF_VAR_xxx environment variables.
+		_, ok := os.LookupEnv("TF_VAR_" + problem.Variable)
+
+		return ok
+	case config.VariableTypeMismatch:
+		// The values of secret references are not known until they are resolved.
+		_, isSecretReference := secretReferences[problem.Variable]
+
+		return isSecretReference
+	default:
+		return false
+	}
+}
+
+// Validate the value of the --terraform-variable-validation argument.
+func isValidVariableValidation(variableValidation string) bool {
+	switch variableValidation {
+	case variableValidationStrict, variableValidationWarn, variableValidationOff:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/tintoy/docker-machine-driver-terraform/terraform"
+	"github.com/tintoy/docker-machine-driver-terraform/terraform/config"
+)
+
+func TestIsIgnoredVariableProblem(t *testing.T) {
+	os.Setenv("TF_VAR_dm_test_password", "hunter2")
+	defer os.Unsetenv("TF_VAR_dm_test_password")
+
+	secretReferences := terraform.ConfigVariables{
+		"api_token": "env:API_TOKEN",
+	}
+
+	testCases := []struct {
+		problem         config.VariableProblem
+		expectedIgnored bool
+	}{
+		{config.VariableProblem{Kind: config.UnknownVariable, Variable: "dm_machine_name"}, true},
+		{config.VariableProblem{Kind: config.UnknownVariable, Variable: "regoin"}, false},
+		{config.VariableProblem{Kind: config.MissingVariable, Variable: "dm_test_password"}, true},
+		{config.VariableProblem{Kind: config.MissingVariable, Variable: "region"}, false},
+		{config.VariableProblem{Kind: config.VariableTypeMismatch, Variable: "api_token"}, true},
+		{config.VariableProblem{Kind: config.VariableTypeMismatch, Variable: "size"}, false},
+	}
+	for _, testCase := range testCases {
+		ignored := isIgnoredVariableProblem(testCase.problem, secretReferences)
+		if ignored != testCase.expectedIgnored {
+			t.Errorf("Expected problem (kind %d) for variable '%s' to be ignored: %t",
+				testCase.problem.Kind, testCase.problem.Variable, testCase.expectedIgnored,
+			)
+		}
+	}
+}
+
+func TestValidateVariablesPolicy(t *testing.T) {
+	configDir, err := ioutil.TempDir("", "variable-validation-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(configDir)
+
+	err = ioutil.WriteFile(path.Join(configDir, "main.tf"), []byte(`
+variable "region" {}
+
+variable "size" {
+  default = "small"
+}
+`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	validVariables := terraform.ConfigVariables{
+		"region":          "syd",
+		"dm_machine_name": "web1", // Built-in variables need not be declared
+	}
+	invalidVariables := terraform.ConfigVariables{
+		"regoin":          "syd",
+		"dm_machine_name": "web1",
+	}
+
+	testCases := []struct {
+		policy        string
+		variables     terraform.ConfigVariables
+		expectedValid bool
+	}{
+		{variableValidationStrict, validVariables, true},
+		{variableValidationStrict, invalidVariables, false},
+		{variableValidationWarn, invalidVariables, true},
+		{variableValidationOff, invalidVariables, true},
+		{"", invalidVariables, true}, // Only strict validation fails
+	}
+	for _, testCase := range testCases {
+		driver := &Driver{
+			ConfigDir:          configDir,
+			ConfigVariables:    testCase.variables,
+			VariableValidation: testCase.policy,
+		}
+
+		err = driver.validateVariables()
+		if testCase.expectedValid && err != nil {
+			t.Errorf("Unexpected error for policy '%s' and variables %v: %s", testCase.policy, testCase.variables, err.Error())
+		} else if !testCase.expectedValid && err == nil {
+			t.Errorf("Expected an error for policy '%s' and variables %v", testCase.policy, testCase.variables)
+		}
+	}
+}
+
+func TestVariableValidationDefaultsToWarn(t *testing.T) {
+	for _, flag := range (&Driver{}).GetCreateFlags() {
+		if flag.String() != "terraform-variable-validation" {
+			continue
+		}
+
+		if flag.Default() != variableValidationWarn {
+			t.Errorf("Expected --terraform-variable-validation to default to '%s' (got '%v')", variableValidationWarn, flag.Default())
+		}
+
+		return
+	}
+
+	t.Errorf("Expected a --terraform-variable-validation flag")
+}